@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"time"
 
@@ -12,13 +15,26 @@ import (
 )
 
 func Run(ctx context.Context) error {
-	if err := configs.LoadConfig(); err != nil {
+	if err := configs.LoadConfig(os.Args[1:]...); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
 		return err
 	}
+	if configs.PrintRequested() {
+		return configs.Print(os.Stdout)
+	}
 	config := configs.Global()
-	logger.InitLogger(configs.IsDevelopment())
+	profile := config.Environment.Settings()
+	if err := logger.InitLogger(logger.Options{
+		Level:           config.LogLevel(),
+		Console:         profile.ConsoleLogs,
+		StacktraceLevel: profile.StacktraceLevel,
+	}); err != nil {
+		return fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
 
-	logger.Debug(ctx, "configuration", logger.NewLogValue("config", config))
+	logger.Debug(ctx, "configuration", logger.NewLogValue("config", configs.Redacted()))
 
 	srv, err := dependency.Inject(ctx)
 	if err != nil {
@@ -0,0 +1,113 @@
+package publisher
+
+import (
+	"context"
+	"sync"
+
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/pkg/types"
+)
+
+// ChannelBus
+//
+// in-process publisher fanning events out to channel subscriptions. Publish blocks while
+// a subscription buffer is full, applying backpressure to the relay.
+type ChannelBus struct {
+	subscriptions map[*Subscription]struct{}
+	closing       chan struct{}
+	buffer        int
+	closed        bool
+	once          sync.Once
+	mu            sync.RWMutex
+}
+
+func NewChannelBus(buffer int) *ChannelBus {
+	return &ChannelBus{
+		subscriptions: make(map[*Subscription]struct{}),
+		closing:       make(chan struct{}),
+		buffer:        buffer,
+	}
+}
+
+type Subscription struct {
+	bus    *ChannelBus
+	events chan changes.Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Subscribe
+//
+// returns a subscription receiving every event published from now on. Its channel is
+// closed when the subscription or the bus is closed.
+func (b *ChannelBus) Subscribe() *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &Subscription{
+		bus:    b,
+		events: make(chan changes.Event, b.buffer),
+		done:   make(chan struct{}),
+	}
+	if b.closed {
+		close(s.events)
+		return s
+	}
+	b.subscriptions[s] = struct{}{}
+	return s
+}
+
+func (b *ChannelBus) Publish(ctx context.Context, events ...changes.Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return types.ErrPublisherClosed
+	}
+	for s := range b.subscriptions {
+	subscription:
+		for _, e := range events {
+			select {
+			case s.events <- e:
+			case <-s.done:
+				break subscription
+			case <-b.closing:
+				return types.ErrPublisherClosed
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+func (b *ChannelBus) Close() error {
+	b.once.Do(func() {
+		close(b.closing)
+	})
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for s := range b.subscriptions {
+		delete(b.subscriptions, s)
+		close(s.events)
+	}
+	return nil
+}
+
+func (s *Subscription) Events() <-chan changes.Event {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.bus.mu.Lock()
+		defer s.bus.mu.Unlock()
+		if _, ok := s.bus.subscriptions[s]; ok {
+			delete(s.bus.subscriptions, s)
+			close(s.events)
+		}
+	})
+}
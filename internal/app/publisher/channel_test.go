@@ -0,0 +1,40 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelBus(t *testing.T) {
+	ctx := context.Background()
+	bus := NewChannelBus(2)
+	first, second := bus.Subscribe(), bus.Subscribe()
+
+	require.NoError(t, bus.Publish(ctx, changes.Event{Sequence: 1}, changes.Event{Sequence: 2}))
+	for _, s := range []*Subscription{first, second} {
+		assert.Equal(t, uint64(1), (<-s.Events()).Sequence)
+		assert.Equal(t, uint64(2), (<-s.Events()).Sequence)
+	}
+
+	// a full buffer blocks until ctx is done, unless the subscription goes away
+	require.NoError(t, bus.Publish(ctx, changes.Event{Sequence: 3}, changes.Event{Sequence: 4}))
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, bus.Publish(timeout, changes.Event{Sequence: 5}), context.DeadlineExceeded)
+	second.Close()
+	assert.Equal(t, uint64(3), (<-first.Events()).Sequence)
+	assert.Equal(t, uint64(4), (<-first.Events()).Sequence)
+	require.NoError(t, bus.Publish(ctx, changes.Event{Sequence: 6}))
+
+	require.NoError(t, bus.Close())
+	assert.Equal(t, uint64(6), (<-first.Events()).Sequence)
+	_, open := <-first.Events()
+	assert.False(t, open)
+	assert.ErrorIs(t, bus.Publish(ctx, changes.Event{}), types.ErrPublisherClosed)
+}
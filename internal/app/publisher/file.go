@@ -0,0 +1,75 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"bucket_organizer/internal/app/repository/changes"
+)
+
+// FileSink
+//
+// appends events to an NDJSON file, synced before Publish returns.
+type FileSink struct {
+	path string
+	file *os.File
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open publish sink: %w", err)
+	}
+	return &FileSink{path: path, file: file}, nil
+}
+
+func (s *FileSink) Publish(ctx context.Context, events ...changes.Event) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := encoder.Encode(e); err != nil {
+			return fmt.Errorf("encode event: %w", err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("write publish sink: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("sync publish sink: %w", err)
+	}
+	return nil
+}
+
+// Check
+//
+// fails when the sink was removed or replaced since it was opened.
+func (s *FileSink) Check(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	opened, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat publish sink: %w", err)
+	}
+	current, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("stat publish sink: %w", err)
+	}
+	if !os.SameFile(opened, current) {
+		return errors.New("publish sink replaced since it was opened")
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,236 @@
+package publisher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/pkg/types"
+)
+
+const (
+	natsDefaultPort    = "4222"
+	natsDefaultTimeout = 10 * time.Second
+	natsClientName     = "bucket_organizer"
+)
+
+// NATSPublisher
+//
+// publishes events to a NATS server speaking the core text protocol, one message per event
+// on the subject returned by Subject. Every Publish ends with a PING, so a nil error means
+// the server processed all the messages. The connection is dialled lazily and re-dialled
+// after any failure. TLS is not supported.
+type NATSPublisher struct {
+	url     *url.URL
+	conn    net.Conn
+	reader  *bufio.Reader
+	subject string
+	timeout time.Duration
+	closed  bool
+	mu      sync.Mutex
+}
+
+// natsConnect
+//
+// the CONNECT options sent after the server INFO.
+type natsConnect struct {
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Version  string `json:"version"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+}
+
+// NewNATSPublisher
+//
+// rawURL has the form nats://[user:pass@|token@]host[:port].
+func NewNATSPublisher(rawURL, subject string, timeout time.Duration) (*NATSPublisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "nats" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid NATS url %q", rawURL)
+	}
+	if timeout <= 0 {
+		timeout = natsDefaultTimeout
+	}
+	return &NATSPublisher{url: u, subject: subject, timeout: timeout}, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, events ...changes.Event) error {
+	var buf bytes.Buffer
+	for _, e := range events {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("encode event: %w", err)
+		}
+		fmt.Fprintf(&buf, "PUB %s %d\r\n%s\r\n", Subject(p.subject, e), len(payload), payload)
+	}
+	buf.WriteString("PING\r\n")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return types.ErrPublisherClosed
+	}
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if err := p.roundTrip(ctx, buf.Bytes()); err != nil {
+		p.disconnect()
+		return err
+	}
+	return nil
+}
+
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.disconnect()
+	return nil
+}
+
+// Check
+//
+// pings the server, connecting first if needed.
+func (p *NATSPublisher) Check(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return types.ErrPublisherClosed
+	}
+	if p.conn == nil {
+		return p.connect(ctx)
+	}
+	if err := p.roundTrip(ctx, []byte("PING\r\n")); err != nil {
+		p.disconnect()
+		return err
+	}
+	return nil
+}
+
+// connect
+//
+// dials the server and performs the INFO/CONNECT handshake. Callers must hold p.mu.
+func (p *NATSPublisher) connect(ctx context.Context) error {
+	host := p.url.Host
+	if p.url.Port() == "" {
+		host = net.JoinHostPort(p.url.Hostname(), natsDefaultPort)
+	}
+	dialer := net.Dialer{Timeout: p.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return fmt.Errorf("connect to NATS: %w", err)
+	}
+	p.conn, p.reader = conn, bufio.NewReader(conn)
+
+	_ = conn.SetDeadline(time.Now().Add(p.timeout))
+	line, err := p.readLine()
+	if err == nil && !strings.HasPrefix(line, "INFO ") {
+		err = fmt.Errorf("unexpected greeting %q", line)
+	}
+	if err == nil {
+		var info struct {
+			TLSRequired bool `json:"tls_required"`
+		}
+		_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+		if info.TLSRequired {
+			err = errors.New("server requires TLS")
+		}
+	}
+	if err != nil {
+		p.disconnect()
+		return fmt.Errorf("connect to NATS: %w", err)
+	}
+
+	options := natsConnect{Name: natsClientName, Lang: "go", Version: "1.0.0"}
+	if p.url.User != nil {
+		if pass, ok := p.url.User.Password(); ok {
+			options.User, options.Pass = p.url.User.Username(), pass
+		} else {
+			options.Token = p.url.User.Username()
+		}
+	}
+	connect, _ := json.Marshal(options)
+	if err := p.roundTrip(ctx, []byte("CONNECT "+string(connect)+"\r\nPING\r\n")); err != nil {
+		p.disconnect()
+		return fmt.Errorf("connect to NATS: %w", err)
+	}
+	return nil
+}
+
+// roundTrip
+//
+// writes data, which must end with a PING, and waits for the matching PONG.
+// Callers must hold p.mu.
+func (p *NATSPublisher) roundTrip(ctx context.Context, data []byte) error {
+	deadline := time.Now().Add(p.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = p.conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		_ = p.conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if _, err := p.conn.Write(data); err != nil {
+		return p.contextError(ctx, err)
+	}
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return p.contextError(ctx, err)
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return p.contextError(ctx, err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		default:
+			// +OK and INFO updates
+		}
+	}
+}
+
+func (p *NATSPublisher) readLine() (string, error) {
+	line, err := p.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (p *NATSPublisher) contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("publish to NATS: %w", err)
+}
+
+// disconnect
+//
+// callers must hold p.mu.
+func (p *NATSPublisher) disconnect() {
+	if p.conn != nil {
+		_ = p.conn.Close()
+		p.conn, p.reader = nil, nil
+	}
+}
@@ -0,0 +1,181 @@
+package publisher
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/repository/changes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type natsMessage struct {
+	subject string
+	payload string
+}
+
+// natsStandIn
+//
+// a minimal NATS server: greets with INFO, answers PING and records PUB messages.
+// Connections are dropped once drop messages were received.
+type natsStandIn struct {
+	listener net.Listener
+	messages []natsMessage
+	connects []string
+	drop     int
+	mu       sync.Mutex
+}
+
+func newNATSStandIn(t *testing.T) *natsStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &natsStandIn{listener: listener}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *natsStandIn) url() string {
+	return "nats://token@" + s.listener.Addr().String()
+}
+
+func (s *natsStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	_, _ = fmt.Fprint(conn, "INFO {\"server_id\":\"stand-in\",\"max_payload\":1048576}\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT":
+			s.mu.Lock()
+			s.connects = append(s.connects, strings.TrimSpace(strings.TrimPrefix(line, "CONNECT")))
+			s.mu.Unlock()
+		case "PING":
+			_, _ = fmt.Fprint(conn, "PONG\r\n")
+		case "PUB":
+			var size int
+			_, _ = fmt.Sscan(fields[2], &size)
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			s.mu.Lock()
+			if s.drop > 0 {
+				s.drop--
+				s.mu.Unlock()
+				return
+			}
+			s.messages = append(s.messages, natsMessage{subject: fields[1], payload: string(payload[:size])})
+			s.mu.Unlock()
+		default:
+			_, _ = fmt.Fprint(conn, "-ERR 'Unknown Protocol Operation'\r\n")
+		}
+	}
+}
+
+func (s *natsStandIn) received() []natsMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]natsMessage{}, s.messages...)
+}
+
+func TestNATSPublisher(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server := newNATSStandIn(t)
+	p, err := NewNATSPublisher(server.url(), "buckets", time.Second)
+	require.NoError(t, err)
+	defer p.Close()
+
+	require.NoError(t, p.Publish(ctx,
+		changes.Event{Type: "object.created", BucketId: "photos", ObjectId: "1", Sequence: 1},
+		changes.Event{Type: "object.deleted", BucketId: "a.b", ObjectId: "2", Sequence: 2},
+	))
+	messages := server.received()
+	require.Len(t, messages, 2)
+	assert.Equal(t, "buckets.photos.object.created", messages[0].subject)
+	assert.Contains(t, messages[0].payload, `"objectId":"1"`)
+	assert.Equal(t, "buckets.a_b.object.deleted", messages[1].subject)
+	assert.Contains(t, server.connects[0], `"auth_token":"token"`)
+}
+
+func TestNATSPublisherReconnects(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server := newNATSStandIn(t)
+	p, err := NewNATSPublisher(server.url(), "buckets", time.Second)
+	require.NoError(t, err)
+	defer p.Close()
+
+	server.mu.Lock()
+	server.drop = 1
+	server.mu.Unlock()
+	event := changes.Event{Type: "object.created", BucketId: "b", Sequence: 1}
+	assert.Error(t, p.Publish(ctx, event))
+	require.NoError(t, p.Publish(ctx, event))
+	assert.Len(t, server.received(), 1)
+}
+
+func TestNATSPublisherServerError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = fmt.Fprint(conn, "INFO {}\r\n-ERR 'Authorization Violation'\r\n")
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	p, err := NewNATSPublisher("nats://"+listener.Addr().String(), "buckets", time.Second)
+	require.NoError(t, err)
+	defer p.Close()
+	err = p.Publish(context.Background(), changes.Event{Type: "object.created", BucketId: "b"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Authorization Violation")
+}
+
+func TestNATSPublisherCheck(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server := newNATSStandIn(t)
+	p, err := NewNATSPublisher(server.url(), "buckets", time.Second)
+	require.NoError(t, err)
+
+	require.NoError(t, p.Check(ctx))
+	require.NoError(t, p.Check(ctx))
+	assert.Len(t, server.connects, 1)
+
+	require.NoError(t, server.listener.Close())
+	unreachable, err := NewNATSPublisher(server.url(), "buckets", time.Second)
+	require.NoError(t, err)
+	assert.Error(t, unreachable.Check(ctx))
+
+	require.NoError(t, p.Close())
+	assert.Error(t, p.Check(ctx))
+}
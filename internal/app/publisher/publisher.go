@@ -0,0 +1,33 @@
+package publisher
+
+import (
+	"context"
+	"strings"
+
+	"bucket_organizer/internal/app/repository/changes"
+)
+
+// Publisher
+//
+// delivers committed change events to a broker or sink. Publish returns once the events
+// were accepted; after an error the caller publishes them again, so delivery is at least once.
+type Publisher interface {
+	Publish(ctx context.Context, events ...changes.Event) error
+	Close() error
+}
+
+// Subject
+//
+// returns "<prefix>.<bucketId>.<event type>", e.g. "buckets.photos.object.created".
+// Characters with a special meaning in subjects are replaced in the bucket id.
+func Subject(prefix string, e changes.Event) string {
+	bucketId := strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		default:
+			return r
+		}
+	}, e.BucketId)
+	return prefix + "." + bucketId + "." + e.Type
+}
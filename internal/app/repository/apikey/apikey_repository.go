@@ -0,0 +1,40 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"bucket_organizer/internal/pkg/auth"
+)
+
+type Repository interface {
+	Create(ctx context.Context, key Key) error
+	Get(ctx context.Context, keyId string) (Key, error)
+	// FindByHash returns the key whose secret hashes to hash, revoked or not.
+	FindByHash(ctx context.Context, hash string) (Key, error)
+	// List returns every key, oldest first.
+	List(ctx context.Context) ([]Key, error)
+	Update(ctx context.Context, key Key) error
+}
+
+// Key
+//
+// an API key. Only the SHA-256 Hash of the secret is stored; Hint holds its last
+// characters so that users can tell their keys apart.
+type Key struct {
+	CreatedAt   time.Time         `json:"createdAt"`
+	RotatedAt   *time.Time        `json:"rotatedAt,omitempty"`
+	RevokedAt   *time.Time        `json:"revokedAt,omitempty"`
+	Id          string            `json:"id"`
+	Name        string            `json:"name"`
+	Hash        string            `json:"hash"`
+	Hint        string            `json:"hint"`
+	Tenant      string            `json:"tenant,omitempty"`
+	Tenants     []string          `json:"tenants,omitempty"`
+	Roles       []string          `json:"roles,omitempty"`
+	Permissions []auth.Permission `json:"permissions"`
+}
+
+func (k *Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
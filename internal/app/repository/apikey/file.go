@@ -0,0 +1,85 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"bucket_organizer/internal/pkg/types"
+)
+
+// FileRepo
+//
+// an InMemoryRepo persisted as a JSON array, replaced atomically on every change. Keys are
+// few and rarely change, so rewriting the whole file keeps the format trivial to inspect.
+type FileRepo struct {
+	*InMemoryRepo
+	path string
+}
+
+func NewFileRepo(path string) (*FileRepo, error) {
+	repo := &FileRepo{InMemoryRepo: NewInMemoryRepo(), path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return repo, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read api keys: %w", err)
+	}
+	var keys []Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("read api keys: %w", err)
+	}
+	for _, k := range keys {
+		repo.put(k)
+	}
+	return repo, nil
+}
+
+func (r *FileRepo) Create(ctx context.Context, key Key) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.put(key)
+	return r.save()
+}
+
+func (r *FileRepo) Update(ctx context.Context, key Key) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[key.Id]; !ok {
+		return types.ErrNoAPIKeyFound
+	}
+	r.put(key)
+	return r.save()
+}
+
+// save
+//
+// callers must hold r.mu.
+func (r *FileRepo) save() error {
+	data, err := json.MarshalIndent(r.list(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("write api keys: %w", err)
+	}
+	tmp := r.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("write api keys: %w", err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write api keys: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("write api keys: %w", err)
+	}
+	return nil
+}
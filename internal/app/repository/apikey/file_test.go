@@ -0,0 +1,41 @@
+package apikey
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRepo(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	repo, err := NewFileRepo(path)
+	require.NoError(t, err)
+
+	key := Key{
+		CreatedAt:   time.Now().UTC(),
+		Id:          "k1",
+		Name:        "ci",
+		Hash:        "old",
+		Permissions: []auth.Permission{{Bucket: "logs", Actions: []auth.Action{auth.ActionRead}}},
+	}
+	require.NoError(t, repo.Create(ctx, key))
+	key.Hash = "new"
+	require.NoError(t, repo.Update(ctx, key))
+	assert.ErrorIs(t, repo.Update(ctx, Key{Id: "missing"}), types.ErrNoAPIKeyFound)
+
+	reopened, err := NewFileRepo(path)
+	require.NoError(t, err)
+	found, err := reopened.FindByHash(ctx, "new")
+	require.NoError(t, err)
+	assert.Equal(t, "ci", found.Name)
+	assert.Equal(t, key.Permissions, found.Permissions)
+	_, err = reopened.FindByHash(ctx, "old")
+	assert.ErrorIs(t, err, types.ErrNoAPIKeyFound)
+}
@@ -0,0 +1,93 @@
+package apikey
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"bucket_organizer/internal/pkg/types"
+)
+
+type InMemoryRepo struct {
+	keys   map[string]Key
+	hashes map[string]string
+	mu     sync.RWMutex
+}
+
+func NewInMemoryRepo() *InMemoryRepo {
+	return &InMemoryRepo{
+		keys:   make(map[string]Key),
+		hashes: make(map[string]string),
+	}
+}
+
+func (r *InMemoryRepo) Create(ctx context.Context, key Key) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.put(key)
+	return nil
+}
+
+func (r *InMemoryRepo) Get(ctx context.Context, keyId string) (Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[keyId]
+	if !ok {
+		return Key{}, types.ErrNoAPIKeyFound
+	}
+	return key, nil
+}
+
+func (r *InMemoryRepo) FindByHash(ctx context.Context, hash string) (Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[r.hashes[hash]]
+	if !ok {
+		return Key{}, types.ErrNoAPIKeyFound
+	}
+	return key, nil
+}
+
+func (r *InMemoryRepo) List(ctx context.Context) ([]Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.list(), nil
+}
+
+func (r *InMemoryRepo) Update(ctx context.Context, key Key) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[key.Id]; !ok {
+		return types.ErrNoAPIKeyFound
+	}
+	r.put(key)
+	return nil
+}
+
+// put
+//
+// stores key, replacing the hash index entry of its previous secret. Callers must hold r.mu.
+func (r *InMemoryRepo) put(key Key) {
+	if previous, ok := r.keys[key.Id]; ok {
+		delete(r.hashes, previous.Hash)
+	}
+	r.keys[key.Id] = key
+	r.hashes[key.Hash] = key.Id
+}
+
+// list
+//
+// callers must hold r.mu.
+func (r *InMemoryRepo) list() []Key {
+	keys := make([]Key, 0, len(r.keys))
+	for _, k := range r.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].Id < keys[j].Id
+	})
+	return keys
+}
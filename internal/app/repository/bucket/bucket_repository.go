@@ -1,9 +1,67 @@
 package bucket
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
-type Repository interface {
+// Objects
+//
+// object operations, available both on a Repository and inside a Tx.
+type Objects interface {
 	InsertObject(ctx context.Context, bucketId, object string) error
 	GetObject(ctx context.Context, bucketId, object string) (string, error)
 	RemoveObject(ctx context.Context, bucketId, object string) error
+
+	TrashObject(ctx context.Context, bucketId, object string, deletedAt time.Time) error
+	ListTrash(ctx context.Context, bucketId string) ([]TrashedObject, error)
+	RestoreObject(ctx context.Context, bucketId, object string) error
+	PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, error)
+
+	GetObjectLock(ctx context.Context, bucketId, object string) (Lock, error)
+	PutObjectRetention(ctx context.Context, bucketId, object string, retention Retention) error
+	PutObjectLegalHold(ctx context.Context, bucketId, object string, legalHold bool) error
+}
+
+// Repository
+//
+// buckets belong to the tenant of the context they are accessed with, see tenant.WithTenant;
+// the same bucket id names unrelated buckets in different tenants.
+type Repository interface {
+	Objects
+	// Begin starts a transaction reading from a snapshot of the committed state.
+	Begin(ctx context.Context) (Tx, error)
+}
+
+// Tx
+//
+// a snapshot isolated transaction: reads see the state committed when the transaction
+// began plus its own writes, and Commit fails with types.ErrTransactionConflict when another
+// transaction committed a write to any key of the write set in the meantime.
+// A Tx must not be used concurrently.
+type Tx interface {
+	Objects
+	ReadSet() []Key
+	WriteSet() []Key
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// Key
+//
+// identifies an entry touched by a transaction. Bucket entries have an empty ObjectId,
+// trashed objects have Trash set.
+type Key struct {
+	TenantId string
+	BucketId string
+	ObjectId string
+	Trash    bool
+}
+
+// TrashedObject
+//
+// an object removed while trash mode is enabled, kept until it is restored or purged.
+type TrashedObject struct {
+	DeletedAt time.Time
+	Id        string
 }
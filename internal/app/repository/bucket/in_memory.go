@@ -2,54 +2,301 @@ package bucket
 
 import (
 	"context"
+	"sort"
+	"sync"
+	"time"
 
+	"bucket_organizer/internal/pkg/metrics"
+	"bucket_organizer/internal/pkg/tracing"
 	"bucket_organizer/internal/pkg/types"
-	"bucket_organizer/pkg/logger"
 )
 
+// InMemoryRepo
+//
+// multi-version store: every committed write appends a version stamped with a logical
+// commit timestamp, so transactions can read the snapshot they began with.
+// Versions no longer visible to any active transaction are pruned on commit.
 type InMemoryRepo struct {
-	cache map[string]map[string]bool
+	hook     CommitHook
+	versions map[key][]version
+	active   map[*inMemoryTx]struct{}
+	counts   map[countKey]int
+	clock    uint64
+	mu       sync.RWMutex
 }
 
-func NewInMemoryRepo() *InMemoryRepo {
-	return &InMemoryRepo{
-		cache: make(map[string]map[string]bool),
+// Count
+//
+// the number of live buckets, objects and trashed objects of a tenant.
+type Count struct {
+	TenantId string
+	Buckets  int
+	Objects  int
+	Trashed  int
+}
+
+type countKey struct {
+	tenantId string
+	space    keyspace
+}
+
+type keyspace uint8
+
+const (
+	bucketSpace keyspace = iota
+	objectSpace
+	trashSpace
+)
+
+type key struct {
+	tenantId string
+	bucketId string
+	objectId string
+	space    keyspace
+}
+
+// in
+//
+// the key of the same object in space.
+func (k key) in(space keyspace) key {
+	k.space = space
+	return k
+}
+
+type value struct {
+	deletedAt time.Time
+	lock      Lock
+}
+
+type version struct {
+	value    *value // nil is a tombstone
+	commitTs uint64
+}
+
+func NewInMemoryRepo(opts ...Option) *InMemoryRepo {
+	r := &InMemoryRepo{
+		versions: make(map[key][]version),
+		active:   make(map[*inMemoryTx]struct{}),
+		counts:   make(map[countKey]int),
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *InMemoryRepo) Begin(ctx context.Context) (Tx, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tx := newInMemoryTx(r, false)
+	r.active[tx] = struct{}{}
+	return tx, nil
 }
 
 func (r *InMemoryRepo) InsertObject(ctx context.Context, bucketId, objectId string) error {
-	if r.cache[bucketId] == nil {
-		r.cache[bucketId] = make(map[string]bool)
-	}
-	r.cache[bucketId][objectId] = true
-	return nil
+	return r.update(ctx, "insert_object", func(tx *inMemoryTx) error {
+		return tx.InsertObject(ctx, bucketId, objectId)
+	})
 }
 
 func (r *InMemoryRepo) GetObject(ctx context.Context, bucketId, objectId string) (string, error) {
-	l, ok := r.cache[bucketId]
-	if !ok {
-		logger.Error(ctx, "bucket not found")
-		return "", types.ErrNoBucketFound
+	return view(ctx, r, "get_object", func(tx *inMemoryTx) (string, error) {
+		return tx.GetObject(ctx, bucketId, objectId)
+	})
+}
+
+func (r *InMemoryRepo) RemoveObject(ctx context.Context, bucketId, objectId string) error {
+	return r.update(ctx, "remove_object", func(tx *inMemoryTx) error {
+		return tx.RemoveObject(ctx, bucketId, objectId)
+	})
+}
+
+func (r *InMemoryRepo) TrashObject(ctx context.Context, bucketId, objectId string, deletedAt time.Time) error {
+	return r.update(ctx, "trash_object", func(tx *inMemoryTx) error {
+		return tx.TrashObject(ctx, bucketId, objectId, deletedAt)
+	})
+}
+
+func (r *InMemoryRepo) ListTrash(ctx context.Context, bucketId string) ([]TrashedObject, error) {
+	return view(ctx, r, "list_trash", func(tx *inMemoryTx) ([]TrashedObject, error) {
+		return tx.ListTrash(ctx, bucketId)
+	})
+}
+
+func (r *InMemoryRepo) RestoreObject(ctx context.Context, bucketId, objectId string) error {
+	return r.update(ctx, "restore_object", func(tx *inMemoryTx) error {
+		return tx.RestoreObject(ctx, bucketId, objectId)
+	})
+}
+
+func (r *InMemoryRepo) PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, error) {
+	purged := 0
+	err := r.update(ctx, "purge_trash", func(tx *inMemoryTx) error {
+		var err error
+		purged, err = tx.PurgeTrash(ctx, deletedBefore)
+		return err
+	})
+	return purged, err
+}
+
+func (r *InMemoryRepo) GetObjectLock(ctx context.Context, bucketId, objectId string) (Lock, error) {
+	return view(ctx, r, "get_object_lock", func(tx *inMemoryTx) (Lock, error) {
+		return tx.GetObjectLock(ctx, bucketId, objectId)
+	})
+}
+
+func (r *InMemoryRepo) PutObjectRetention(ctx context.Context, bucketId, objectId string, retention Retention) error {
+	return r.update(ctx, "put_object_retention", func(tx *inMemoryTx) error {
+		return tx.PutObjectRetention(ctx, bucketId, objectId, retention)
+	})
+}
+
+func (r *InMemoryRepo) PutObjectLegalHold(ctx context.Context, bucketId, objectId string, legalHold bool) error {
+	return r.update(ctx, "put_object_legal_hold", func(tx *inMemoryTx) error {
+		return tx.PutObjectLegalHold(ctx, bucketId, objectId, legalHold)
+	})
+}
+
+// Counts
+//
+// the live entries of every tenant, maintained on commit rather than counted.
+func (r *InMemoryRepo) Counts() []Count {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	byTenant := make(map[string]*Count)
+	for k, n := range r.counts {
+		c, ok := byTenant[k.tenantId]
+		if !ok {
+			c = &Count{TenantId: k.tenantId}
+			byTenant[k.tenantId] = c
+		}
+		switch k.space {
+		case bucketSpace:
+			c.Buckets = n
+		case objectSpace:
+			c.Objects = n
+		case trashSpace:
+			c.Trashed = n
+		}
 	}
-	if _, ok := l[objectId]; ok {
-		logger.Debug(ctx, "found object", logger.NewLogValue("object", objectId))
-		return objectId, nil
+	counts := make([]Count, 0, len(byTenant))
+	for _, c := range byTenant {
+		counts = append(counts, *c)
 	}
-	logger.Error(ctx, "object not found")
-	return "", types.ErrNoObjectFound
+	sort.Slice(counts, func(i, j int) bool { return counts[i].TenantId < counts[j].TenantId })
+	return counts
 }
 
-func (r *InMemoryRepo) RemoveObject(ctx context.Context, bucketId, objectId string) error {
-	l, ok := r.cache[bucketId]
-	if !ok {
-		logger.Error(ctx, "bucket not found")
-		return types.ErrNoBucketFound
-	}
-	if _, ok := l[objectId]; ok {
-		logger.Debug(ctx, "found object, deleting...", logger.NewLogValue("object", objectId))
-		delete(l, objectId)
+// update
+//
+// runs fn in a transaction committed while holding the write lock, so single operations
+// never conflict with each other.
+func (r *InMemoryRepo) update(ctx context.Context, operation string, fn func(tx *inMemoryTx) error) error {
+	defer metrics.RepositoryOperations.Since(time.Now(), "bucket", operation)
+	ctx, span := startSpan(ctx, operation)
+	defer span.End()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tx := newInMemoryTx(r, true)
+	if err := fn(tx); err != nil {
+		return span.Fail(err)
+	}
+	return span.Fail(r.commit(ctx, tx))
+}
+
+// view
+//
+// runs fn in a read-only transaction holding the read lock.
+func view[T any](ctx context.Context, r *InMemoryRepo, operation string, fn func(tx *inMemoryTx) (T, error)) (T, error) {
+	defer metrics.RepositoryOperations.Since(time.Now(), "bucket", operation)
+	_, span := startSpan(ctx, operation)
+	defer span.End()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, err := fn(newInMemoryTx(r, true))
+	return v, span.Fail(err)
+}
+
+// startSpan
+//
+// starts the span of a repository operation, named like its metrics.
+func startSpan(ctx context.Context, operation string) (context.Context, *tracing.Span) {
+	return tracing.StartKind(ctx, tracing.KindClient, "bucket."+operation,
+		tracing.String("db.system", "in_memory"), tracing.String("db.operation", operation))
+}
+
+// visible
+//
+// returns the value of k as of ts, nil if it did not exist. Callers must hold r.mu.
+func (r *InMemoryRepo) visible(k key, ts uint64) *value {
+	vs := r.versions[k]
+	for i := len(vs) - 1; i >= 0; i-- {
+		if vs[i].commitTs <= ts {
+			return vs[i].value
+		}
+	}
+	return nil
+}
+
+// commit
+//
+// applies the write set of tx using first-committer-wins, after the commit hook accepted it.
+// Callers must hold r.mu for writing.
+func (r *InMemoryRepo) commit(ctx context.Context, tx *inMemoryTx) error {
+	delete(r.active, tx)
+	for k := range tx.writes {
+		if vs := r.versions[k]; len(vs) > 0 && vs[len(vs)-1].commitTs > tx.startTs {
+			return types.ErrTransactionConflict
+		}
+	}
+	if len(tx.writes) == 0 {
 		return nil
 	}
-	logger.Error(ctx, "object not found")
-	return types.ErrNoObjectFound
+	if r.hook != nil {
+		if mutations := r.mutations(tx.writes); len(mutations) > 0 {
+			if err := r.hook(ctx, mutations); err != nil {
+				return err
+			}
+		}
+	}
+	for k, v := range tx.writes {
+		switch previous := r.visible(k, r.clock); {
+		case previous == nil && v != nil:
+			r.counts[countKey{k.tenantId, k.space}]++
+		case previous != nil && v == nil:
+			r.counts[countKey{k.tenantId, k.space}]--
+		}
+	}
+	r.clock++
+	for k, v := range tx.writes {
+		r.versions[k] = append(r.versions[k], version{value: v, commitTs: r.clock})
+	}
+	r.vacuum(tx.writes)
+	return nil
+}
+
+// vacuum
+//
+// drops the versions of keys that no active transaction can read anymore.
+// Callers must hold r.mu for writing.
+func (r *InMemoryRepo) vacuum(keys map[key]*value) {
+	horizon := r.clock
+	for tx := range r.active {
+		horizon = min(horizon, tx.startTs)
+	}
+	for k := range keys {
+		vs := r.versions[k]
+		oldest := 0
+		for i, v := range vs {
+			if v.commitTs <= horizon {
+				oldest = i
+			}
+		}
+		vs = vs[oldest:]
+		if len(vs) == 1 && vs[0].value == nil && vs[0].commitTs <= horizon {
+			delete(r.versions, k)
+			continue
+		}
+		r.versions[k] = vs
+	}
 }
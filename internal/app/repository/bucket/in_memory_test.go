@@ -0,0 +1,109 @@
+package bucket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryRepoTrash(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+
+	deletedAt := time.Now()
+	require.NoError(t, repo.TrashObject(ctx, "bucket", "object", deletedAt))
+	_, err := repo.GetObject(ctx, "bucket", "object")
+	assert.ErrorIs(t, err, types.ErrNoObjectFound)
+
+	trashed, err := repo.ListTrash(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Equal(t, []TrashedObject{{Id: "object", DeletedAt: deletedAt}}, trashed)
+
+	require.NoError(t, repo.RestoreObject(ctx, "bucket", "object"))
+	o, err := repo.GetObject(ctx, "bucket", "object")
+	require.NoError(t, err)
+	assert.Equal(t, "object", o)
+
+	assert.ErrorIs(t, repo.RestoreObject(ctx, "bucket", "object"), types.ErrNoObjectFound)
+}
+
+func TestInMemoryRepoRestoreConflict(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+	require.NoError(t, repo.TrashObject(ctx, "bucket", "object", time.Now()))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+
+	assert.ErrorIs(t, repo.RestoreObject(ctx, "bucket", "object"), types.ErrObjectAlreadyExists)
+}
+
+func TestInMemoryRepoPurgeTrash(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepo()
+	now := time.Now()
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "old"))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "recent"))
+	require.NoError(t, repo.TrashObject(ctx, "bucket", "old", now.Add(-2*time.Hour)))
+	require.NoError(t, repo.TrashObject(ctx, "bucket", "recent", now))
+
+	purged, err := repo.PurgeTrash(ctx, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	trashed, err := repo.ListTrash(ctx, "bucket")
+	require.NoError(t, err)
+	require.Len(t, trashed, 1)
+	assert.Equal(t, "recent", trashed[0].Id)
+}
+
+func TestInMemoryRepoIsolatesTenants(t *testing.T) {
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+	repo := NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(acme, "bucket", "object"))
+
+	_, err := repo.GetObject(globex, "bucket", "object")
+	assert.ErrorIs(t, err, types.ErrNoBucketFound)
+	require.NoError(t, repo.InsertObject(globex, "bucket", "other"))
+	_, err = repo.GetObject(acme, "bucket", "other")
+	assert.ErrorIs(t, err, types.ErrNoObjectFound)
+
+	require.NoError(t, repo.TrashObject(acme, "bucket", "object", time.Now().Add(-time.Hour)))
+	trashed, err := repo.ListTrash(globex, "bucket")
+	require.NoError(t, err)
+	assert.Empty(t, trashed)
+	// purging spans every tenant
+	purged, err := repo.PurgeTrash(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+}
+
+func TestInMemoryRepoCounts(t *testing.T) {
+	acme := tenant.WithTenant(context.Background(), "acme")
+	ctx := context.Background()
+	repo := NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "a"))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "a"))
+	require.NoError(t, repo.InsertObject(ctx, "other", "b"))
+	require.NoError(t, repo.TrashObject(ctx, "other", "b", time.Now()))
+	require.NoError(t, repo.InsertObject(acme, "bucket", "c"))
+	require.NoError(t, repo.RemoveObject(acme, "bucket", "c"))
+
+	// a failed transaction changes nothing
+	tx, err := repo.Begin(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.InsertObject(ctx, "bucket", "d"))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "d"))
+	assert.ErrorIs(t, tx.Commit(ctx), types.ErrTransactionConflict)
+
+	assert.Equal(t, []Count{
+		{TenantId: "acme", Buckets: 1},
+		{TenantId: tenant.Default, Buckets: 2, Objects: 2, Trashed: 1},
+	}, repo.Counts())
+}
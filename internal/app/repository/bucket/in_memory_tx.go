@@ -0,0 +1,335 @@
+package bucket
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"bucket_organizer/internal/pkg/metrics"
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+)
+
+type inMemoryTx struct {
+	repo    *InMemoryRepo
+	writes  map[key]*value
+	reads   map[key]struct{}
+	startTs uint64
+	// locked is set when the caller already holds repo.mu for the whole transaction.
+	locked bool
+	done   bool
+}
+
+// newInMemoryTx
+//
+// callers must hold r.mu.
+func newInMemoryTx(r *InMemoryRepo, locked bool) *inMemoryTx {
+	return &inMemoryTx{
+		repo:    r,
+		writes:  make(map[key]*value),
+		reads:   make(map[key]struct{}),
+		startTs: r.clock,
+		locked:  locked,
+	}
+}
+
+func (t *inMemoryTx) InsertObject(ctx context.Context, bucketId, objectId string) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	if _, ok := t.get(bucketKey(ctx, bucketId)); !ok {
+		t.put(bucketKey(ctx, bucketId), &value{})
+	}
+	current, ok := t.get(objectKey(ctx, bucketId, objectId))
+	if !ok {
+		t.put(objectKey(ctx, bucketId, objectId), &value{})
+		return nil
+	}
+	if err := current.lock.CheckMutation(time.Now(), GovernanceBypassed(ctx)); err != nil {
+		logger.Error(ctx, "object is locked, refusing overwrite")
+		return err
+	}
+	t.put(objectKey(ctx, bucketId, objectId), &value{lock: current.lock})
+	return nil
+}
+
+func (t *inMemoryTx) GetObject(ctx context.Context, bucketId, objectId string) (string, error) {
+	if _, err := t.lookup(ctx, bucketId, objectId); err != nil {
+		return "", err
+	}
+	logger.Debug(ctx, "found object", logger.NewLogValue("object", objectId))
+	return objectId, nil
+}
+
+func (t *inMemoryTx) RemoveObject(ctx context.Context, bucketId, objectId string) error {
+	current, err := t.lookup(ctx, bucketId, objectId)
+	if err != nil {
+		return err
+	}
+	if err := current.lock.CheckMutation(time.Now(), GovernanceBypassed(ctx)); err != nil {
+		logger.Error(ctx, "object is locked, refusing delete")
+		return err
+	}
+	logger.Debug(ctx, "found object, deleting...", logger.NewLogValue("object", objectId))
+	t.put(objectKey(ctx, bucketId, objectId), nil)
+	return nil
+}
+
+func (t *inMemoryTx) TrashObject(ctx context.Context, bucketId, objectId string, deletedAt time.Time) error {
+	current, err := t.lookup(ctx, bucketId, objectId)
+	if err != nil {
+		return err
+	}
+	if err := current.lock.CheckMutation(deletedAt, GovernanceBypassed(ctx)); err != nil {
+		logger.Error(ctx, "object is locked, refusing delete")
+		return err
+	}
+	logger.Debug(ctx, "found object, moving to trash...", logger.NewLogValue("object", objectId))
+	t.put(objectKey(ctx, bucketId, objectId), nil)
+	t.put(trashKey(ctx, bucketId, objectId), &value{deletedAt: deletedAt})
+	return nil
+}
+
+func (t *inMemoryTx) ListTrash(ctx context.Context, bucketId string) ([]TrashedObject, error) {
+	if err := t.usable(); err != nil {
+		return nil, err
+	}
+	if _, ok := t.get(bucketKey(ctx, bucketId)); !ok {
+		logger.Error(ctx, "bucket not found")
+		return nil, types.ErrNoBucketFound
+	}
+	trash := t.scan(trashSpace, tenant.FromContext(ctx), bucketId)
+	objects := make([]TrashedObject, 0, len(trash))
+	for k, v := range trash {
+		objects = append(objects, TrashedObject{Id: k.objectId, DeletedAt: v.deletedAt})
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].DeletedAt.Before(objects[j].DeletedAt)
+	})
+	return objects, nil
+}
+
+func (t *inMemoryTx) RestoreObject(ctx context.Context, bucketId, objectId string) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	if _, ok := t.get(bucketKey(ctx, bucketId)); !ok {
+		logger.Error(ctx, "bucket not found")
+		return types.ErrNoBucketFound
+	}
+	if _, ok := t.get(trashKey(ctx, bucketId, objectId)); !ok {
+		logger.Error(ctx, "object not found in trash")
+		return types.ErrNoObjectFound
+	}
+	if _, ok := t.get(objectKey(ctx, bucketId, objectId)); ok {
+		logger.Error(ctx, "object already exists")
+		return types.ErrObjectAlreadyExists
+	}
+	logger.Debug(ctx, "restoring object from trash", logger.NewLogValue("object", objectId))
+	t.put(trashKey(ctx, bucketId, objectId), nil)
+	t.put(objectKey(ctx, bucketId, objectId), &value{})
+	return nil
+}
+
+func (t *inMemoryTx) PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, error) {
+	if err := t.usable(); err != nil {
+		return 0, err
+	}
+	purged := 0
+	for k, v := range t.scan(trashSpace, "", "") {
+		if v.deletedAt.Before(deletedBefore) {
+			t.put(k, nil)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (t *inMemoryTx) GetObjectLock(ctx context.Context, bucketId, objectId string) (Lock, error) {
+	current, err := t.lookup(ctx, bucketId, objectId)
+	if err != nil {
+		return Lock{}, err
+	}
+	return current.lock, nil
+}
+
+func (t *inMemoryTx) PutObjectRetention(ctx context.Context, bucketId, objectId string, retention Retention) error {
+	current, err := t.lookup(ctx, bucketId, objectId)
+	if err != nil {
+		return err
+	}
+	if err := current.lock.CheckRetentionChange(retention, time.Now(), GovernanceBypassed(ctx)); err != nil {
+		logger.Error(ctx, "object retention cannot be shortened")
+		return err
+	}
+	lock := current.lock
+	lock.Retention = retention
+	t.put(objectKey(ctx, bucketId, objectId), &value{lock: lock})
+	return nil
+}
+
+func (t *inMemoryTx) PutObjectLegalHold(ctx context.Context, bucketId, objectId string, legalHold bool) error {
+	current, err := t.lookup(ctx, bucketId, objectId)
+	if err != nil {
+		return err
+	}
+	lock := current.lock
+	lock.LegalHold = legalHold
+	t.put(objectKey(ctx, bucketId, objectId), &value{lock: lock})
+	return nil
+}
+
+func (t *inMemoryTx) ReadSet() []Key {
+	return exportKeys(t.reads)
+}
+
+func (t *inMemoryTx) WriteSet() []Key {
+	return exportKeys(t.writes)
+}
+
+func (t *inMemoryTx) Commit(ctx context.Context) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	t.done = true
+	defer metrics.RepositoryOperations.Since(time.Now(), "bucket", "commit")
+	ctx, span := startSpan(ctx, "commit")
+	defer span.End()
+	t.repo.mu.Lock()
+	defer t.repo.mu.Unlock()
+	if err := t.repo.commit(ctx, t); err != nil {
+		logger.Error(ctx, "transaction commit failed", err)
+		return span.Fail(err)
+	}
+	return nil
+}
+
+func (t *inMemoryTx) Rollback(ctx context.Context) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	t.done = true
+	t.repo.mu.Lock()
+	defer t.repo.mu.Unlock()
+	delete(t.repo.active, t)
+	return nil
+}
+
+func (t *inMemoryTx) usable() error {
+	if t.done {
+		return types.ErrTransactionClosed
+	}
+	return nil
+}
+
+// lookup
+//
+// returns the live object, reporting a missing bucket or object.
+func (t *inMemoryTx) lookup(ctx context.Context, bucketId, objectId string) (*value, error) {
+	if err := t.usable(); err != nil {
+		return nil, err
+	}
+	if _, ok := t.get(bucketKey(ctx, bucketId)); !ok {
+		logger.Error(ctx, "bucket not found")
+		return nil, types.ErrNoBucketFound
+	}
+	v, ok := t.get(objectKey(ctx, bucketId, objectId))
+	if !ok {
+		logger.Error(ctx, "object not found")
+		return nil, types.ErrNoObjectFound
+	}
+	return v, nil
+}
+
+func (t *inMemoryTx) get(k key) (*value, bool) {
+	t.reads[k] = struct{}{}
+	if v, ok := t.writes[k]; ok {
+		return v, v != nil
+	}
+	if !t.locked {
+		t.repo.mu.RLock()
+		defer t.repo.mu.RUnlock()
+	}
+	v := t.repo.visible(k, t.startTs)
+	return v, v != nil
+}
+
+func (t *inMemoryTx) put(k key, v *value) {
+	t.writes[k] = v
+}
+
+// scan
+//
+// returns the entries of space visible to the transaction, restricted to tenantId and
+// bucketId unless empty.
+func (t *inMemoryTx) scan(space keyspace, tenantId, bucketId string) map[key]*value {
+	matches := func(k key) bool {
+		return k.space == space && (tenantId == "" || k.tenantId == tenantId) && (bucketId == "" || k.bucketId == bucketId)
+	}
+	entries := make(map[key]*value)
+	func() {
+		if !t.locked {
+			t.repo.mu.RLock()
+			defer t.repo.mu.RUnlock()
+		}
+		for k := range t.repo.versions {
+			if !matches(k) {
+				continue
+			}
+			if v := t.repo.visible(k, t.startTs); v != nil {
+				entries[k] = v
+			}
+		}
+	}()
+	for k, v := range t.writes {
+		if !matches(k) {
+			continue
+		}
+		if v == nil {
+			delete(entries, k)
+			continue
+		}
+		entries[k] = v
+	}
+	for k := range entries {
+		t.reads[k] = struct{}{}
+	}
+	return entries
+}
+
+// bucketKey
+//
+// the key of bucketId in the tenant of ctx, like objectKey and trashKey.
+func bucketKey(ctx context.Context, bucketId string) key {
+	return key{space: bucketSpace, tenantId: tenant.FromContext(ctx), bucketId: bucketId}
+}
+
+func objectKey(ctx context.Context, bucketId, objectId string) key {
+	return key{space: objectSpace, tenantId: tenant.FromContext(ctx), bucketId: bucketId, objectId: objectId}
+}
+
+func trashKey(ctx context.Context, bucketId, objectId string) key {
+	return key{space: trashSpace, tenantId: tenant.FromContext(ctx), bucketId: bucketId, objectId: objectId}
+}
+
+func exportKeys[V any](keys map[key]V) []Key {
+	exported := make([]Key, 0, len(keys))
+	for k := range keys {
+		exported = append(exported, Key{TenantId: k.tenantId, BucketId: k.bucketId, ObjectId: k.objectId, Trash: k.space == trashSpace})
+	}
+	sort.Slice(exported, func(i, j int) bool {
+		a, b := exported[i], exported[j]
+		if a.TenantId != b.TenantId {
+			return a.TenantId < b.TenantId
+		}
+		if a.BucketId != b.BucketId {
+			return a.BucketId < b.BucketId
+		}
+		if a.ObjectId != b.ObjectId {
+			return a.ObjectId < b.ObjectId
+		}
+		return !a.Trash && b.Trash
+	})
+	return exported
+}
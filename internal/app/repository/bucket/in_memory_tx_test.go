@@ -0,0 +1,125 @@
+package bucket
+
+import (
+	"context"
+	"testing"
+
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryTxWriteWriteConflict(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+
+	first, err := repo.Begin(ctx)
+	require.NoError(t, err)
+	second, err := repo.Begin(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, first.RemoveObject(ctx, "bucket", "object"))
+	require.NoError(t, second.PutObjectLegalHold(ctx, "bucket", "object", true))
+
+	require.NoError(t, first.Commit(ctx))
+	assert.ErrorIs(t, second.Commit(ctx), types.ErrTransactionConflict)
+
+	_, err = repo.GetObject(ctx, "bucket", "object")
+	assert.ErrorIs(t, err, types.ErrNoObjectFound)
+}
+
+func TestInMemoryTxConflictWithSingleOperation(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+
+	tx, err := repo.Begin(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.RemoveObject(ctx, "bucket", "object"))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+
+	assert.ErrorIs(t, tx.Commit(ctx), types.ErrTransactionConflict)
+}
+
+func TestInMemoryTxDisjointWritesCommit(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "a"))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "b"))
+
+	first, err := repo.Begin(ctx)
+	require.NoError(t, err)
+	second, err := repo.Begin(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, first.RemoveObject(ctx, "bucket", "a"))
+	require.NoError(t, second.RemoveObject(ctx, "bucket", "b"))
+	require.NoError(t, first.Commit(ctx))
+	require.NoError(t, second.Commit(ctx))
+
+	_, err = repo.GetObject(ctx, "bucket", "a")
+	assert.ErrorIs(t, err, types.ErrNoObjectFound)
+	_, err = repo.GetObject(ctx, "bucket", "b")
+	assert.ErrorIs(t, err, types.ErrNoObjectFound)
+}
+
+func TestInMemoryTxSnapshotIsolation(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+
+	tx, err := repo.Begin(ctx)
+	require.NoError(t, err)
+	require.NoError(t, repo.RemoveObject(ctx, "bucket", "object"))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "new"))
+
+	o, err := tx.GetObject(ctx, "bucket", "object")
+	require.NoError(t, err)
+	assert.Equal(t, "object", o)
+	_, err = tx.GetObject(ctx, "bucket", "new")
+	assert.ErrorIs(t, err, types.ErrNoObjectFound)
+
+	require.NoError(t, tx.InsertObject(ctx, "bucket", "mine"))
+	_, err = tx.GetObject(ctx, "bucket", "mine")
+	assert.NoError(t, err)
+	_, err = repo.GetObject(ctx, "bucket", "mine")
+	assert.ErrorIs(t, err, types.ErrNoObjectFound)
+
+	assert.Equal(t, []Key{{TenantId: tenant.Default, BucketId: "bucket", ObjectId: "mine"}}, tx.WriteSet())
+	assert.Contains(t, tx.ReadSet(), Key{TenantId: tenant.Default, BucketId: "bucket", ObjectId: "object"})
+	require.NoError(t, tx.Commit(ctx))
+}
+
+func TestInMemoryTxRollback(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+
+	tx, err := repo.Begin(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.RemoveObject(ctx, "bucket", "object"))
+	require.NoError(t, tx.Rollback(ctx))
+
+	_, err = repo.GetObject(ctx, "bucket", "object")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, tx.Commit(ctx), types.ErrTransactionClosed)
+	assert.ErrorIs(t, tx.InsertObject(ctx, "bucket", "other"), types.ErrTransactionClosed)
+}
+
+func TestInMemoryRepoVacuum(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+
+	tx, err := repo.Begin(ctx)
+	require.NoError(t, err)
+	require.NoError(t, repo.RemoveObject(ctx, "bucket", "object"))
+	assert.Len(t, repo.versions[objectKey(ctx, "bucket", "object")], 2, "versions visible to the open transaction are kept")
+
+	require.NoError(t, tx.Rollback(ctx))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+	require.NoError(t, repo.RemoveObject(ctx, "bucket", "object"))
+	assert.NotContains(t, repo.versions, objectKey(ctx, "bucket", "object"))
+}
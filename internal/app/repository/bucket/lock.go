@@ -0,0 +1,98 @@
+package bucket
+
+import (
+	"context"
+	"time"
+
+	"bucket_organizer/internal/pkg/types"
+)
+
+type RetentionMode string
+
+const (
+	RetentionGovernance RetentionMode = "GOVERNANCE"
+	RetentionCompliance RetentionMode = "COMPLIANCE"
+)
+
+// Retention
+//
+// a write-once-read-many retention period. In governance mode it can be bypassed
+// (see WithGovernanceBypass), in compliance mode it can only be extended.
+type Retention struct {
+	RetainUntil time.Time
+	Mode        RetentionMode
+}
+
+// Lock
+//
+// object lock state. Every Repository implementation must consult CheckMutation before
+// removing, trashing or overwriting an object and CheckRetentionChange before replacing
+// its retention, so that all backends enforce object lock the same way.
+type Lock struct {
+	Retention Retention
+	LegalHold bool
+}
+
+type governanceBypassKey struct{}
+
+// WithGovernanceBypass
+//
+// marks ctx as allowed to bypass governance mode retention.
+func WithGovernanceBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, governanceBypassKey{}, true)
+}
+
+func GovernanceBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(governanceBypassKey{}).(bool)
+	return bypass
+}
+
+func (r Retention) Active(now time.Time) bool {
+	return r.Mode != "" && r.RetainUntil.After(now)
+}
+
+func (r Retention) Validate() error {
+	switch r.Mode {
+	case RetentionGovernance, RetentionCompliance:
+	case "":
+		if !r.RetainUntil.IsZero() {
+			return types.NewValidationError(types.InvalidParam{Name: "mode", Reason: "mode is required with retainUntil"})
+		}
+	default:
+		return types.NewValidationError(types.InvalidParam{Name: "mode", Reason: "must be GOVERNANCE or COMPLIANCE"})
+	}
+	return nil
+}
+
+func (l Lock) CheckMutation(now time.Time, bypassGovernance bool) error {
+	if l.LegalHold {
+		return types.ErrObjectLocked
+	}
+	if !l.Retention.Active(now) {
+		return nil
+	}
+	if l.Retention.Mode == RetentionGovernance && bypassGovernance {
+		return nil
+	}
+	return types.ErrObjectLocked
+}
+
+// CheckRetentionChange
+//
+// an active retention can always be extended; shortening, removing or weakening it is
+// refused in compliance mode and requires a bypass in governance mode.
+func (l Lock) CheckRetentionChange(next Retention, now time.Time, bypassGovernance bool) error {
+	current := l.Retention
+	if !current.Active(now) {
+		return nil
+	}
+	extends := !next.RetainUntil.Before(current.RetainUntil) &&
+		(next.Mode == RetentionCompliance || next.Mode == current.Mode)
+	if extends {
+		return nil
+	}
+	if current.Mode == RetentionGovernance && bypassGovernance {
+		return nil
+	}
+	return types.ErrObjectLocked
+}
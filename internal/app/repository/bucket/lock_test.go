@@ -0,0 +1,70 @@
+package bucket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockCheckMutation(t *testing.T) {
+	now := time.Now()
+	future := now.Add(time.Hour)
+	tests := []struct {
+		name    string
+		lock    Lock
+		bypass  bool
+		wantErr bool
+	}{
+		{name: "No lock", lock: Lock{}},
+		{name: "Legal hold", lock: Lock{LegalHold: true}, bypass: true, wantErr: true},
+		{name: "Expired retention", lock: Lock{Retention: Retention{Mode: RetentionCompliance, RetainUntil: now.Add(-time.Hour)}}},
+		{name: "Governance", lock: Lock{Retention: Retention{Mode: RetentionGovernance, RetainUntil: future}}, wantErr: true},
+		{name: "Governance bypass", lock: Lock{Retention: Retention{Mode: RetentionGovernance, RetainUntil: future}}, bypass: true},
+		{name: "Compliance bypass", lock: Lock{Retention: Retention{Mode: RetentionCompliance, RetainUntil: future}}, bypass: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.lock.CheckMutation(now, tt.bypass)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, types.ErrObjectLocked)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestLockCheckRetentionChange(t *testing.T) {
+	now := time.Now()
+	compliance := Lock{Retention: Retention{Mode: RetentionCompliance, RetainUntil: now.Add(time.Hour)}}
+	governance := Lock{Retention: Retention{Mode: RetentionGovernance, RetainUntil: now.Add(time.Hour)}}
+
+	assert.NoError(t, compliance.CheckRetentionChange(Retention{Mode: RetentionCompliance, RetainUntil: now.Add(2 * time.Hour)}, now, false))
+	assert.ErrorIs(t, compliance.CheckRetentionChange(Retention{Mode: RetentionCompliance, RetainUntil: now}, now, true), types.ErrObjectLocked)
+	assert.ErrorIs(t, compliance.CheckRetentionChange(Retention{Mode: RetentionGovernance, RetainUntil: now.Add(2 * time.Hour)}, now, true), types.ErrObjectLocked)
+	assert.NoError(t, governance.CheckRetentionChange(Retention{Mode: RetentionCompliance, RetainUntil: now.Add(time.Hour)}, now, false))
+	assert.ErrorIs(t, governance.CheckRetentionChange(Retention{}, now, false), types.ErrObjectLocked)
+	assert.NoError(t, governance.CheckRetentionChange(Retention{}, now, true))
+}
+
+func TestInMemoryRepoObjectLock(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+	require.NoError(t, repo.PutObjectRetention(ctx, "bucket", "object", Retention{Mode: RetentionGovernance, RetainUntil: time.Now().Add(time.Hour)}))
+
+	assert.ErrorIs(t, repo.InsertObject(ctx, "bucket", "object"), types.ErrObjectLocked)
+	assert.ErrorIs(t, repo.RemoveObject(ctx, "bucket", "object"), types.ErrObjectLocked)
+	assert.ErrorIs(t, repo.TrashObject(ctx, "bucket", "object", time.Now()), types.ErrObjectLocked)
+
+	require.NoError(t, repo.PutObjectLegalHold(ctx, "bucket", "object", true))
+	assert.ErrorIs(t, repo.RemoveObject(WithGovernanceBypass(ctx), "bucket", "object"), types.ErrObjectLocked)
+
+	require.NoError(t, repo.PutObjectLegalHold(ctx, "bucket", "object", false))
+	assert.NoError(t, repo.RemoveObject(WithGovernanceBypass(ctx), "bucket", "object"))
+}
@@ -0,0 +1,117 @@
+package bucket
+
+import (
+	"context"
+	"sort"
+)
+
+type MutationType string
+
+const (
+	MutationBucketCreated     MutationType = "bucket.created"
+	MutationObjectCreated     MutationType = "object.created"
+	MutationObjectUpdated     MutationType = "object.updated"
+	MutationObjectDeleted     MutationType = "object.deleted"
+	MutationObjectTrashed     MutationType = "object.trashed"
+	MutationObjectRestored    MutationType = "object.restored"
+	MutationObjectPurged      MutationType = "object.purged"
+	MutationObjectLockChanged MutationType = "object.lock_changed"
+)
+
+// Mutation
+//
+// a committed change, reported to the CommitHook.
+type Mutation struct {
+	Type     MutationType
+	TenantId string
+	BucketId string
+	ObjectId string
+}
+
+// CommitHook
+//
+// called with the mutations of every commit before they become visible, in commit order.
+// Returning an error aborts the commit, which makes the hook a transactional outbox.
+type CommitHook func(ctx context.Context, mutations []Mutation) error
+
+type Option func(r *InMemoryRepo)
+
+func WithCommitHook(hook CommitHook) Option {
+	return func(r *InMemoryRepo) {
+		r.hook = hook
+	}
+}
+
+// mutations
+//
+// describes the difference between the committed state and the write set.
+// Callers must hold r.mu.
+func (r *InMemoryRepo) mutations(writes map[key]*value) []Mutation {
+	mutations := make([]Mutation, 0, len(writes))
+	for k, after := range writes {
+		before := r.visible(k, r.clock)
+		m := Mutation{TenantId: k.tenantId, BucketId: k.bucketId, ObjectId: k.objectId}
+		switch k.space {
+		case bucketSpace:
+			if before != nil || after == nil {
+				continue
+			}
+			m.Type = MutationBucketCreated
+		case objectSpace:
+			switch {
+			case before == nil && after == nil:
+				continue
+			case before == nil:
+				if restored(writes, k) {
+					m.Type = MutationObjectRestored
+				} else {
+					m.Type = MutationObjectCreated
+				}
+			case after == nil:
+				if trashed(writes, k) {
+					m.Type = MutationObjectTrashed
+				} else {
+					m.Type = MutationObjectDeleted
+				}
+			case before.lock != after.lock:
+				m.Type = MutationObjectLockChanged
+			default:
+				m.Type = MutationObjectUpdated
+			}
+		case trashSpace:
+			// moving in and out of the trash is reported on the object key
+			if before == nil || after != nil {
+				continue
+			}
+			if v, ok := writes[k.in(objectSpace)]; ok && v != nil {
+				continue
+			}
+			m.Type = MutationObjectPurged
+		}
+		mutations = append(mutations, m)
+	}
+	sort.Slice(mutations, func(i, j int) bool {
+		a, b := mutations[i], mutations[j]
+		if a.TenantId != b.TenantId {
+			return a.TenantId < b.TenantId
+		}
+		if a.BucketId != b.BucketId {
+			return a.BucketId < b.BucketId
+		}
+		if a.ObjectId != b.ObjectId {
+			return a.ObjectId < b.ObjectId
+		}
+		return a.Type < b.Type
+	})
+	return mutations
+}
+
+func trashed(writes map[key]*value, k key) bool {
+	v, ok := writes[k.in(trashSpace)]
+	return ok && v != nil
+}
+
+func restored(writes map[key]*value, k key) bool {
+	v, ok := writes[k.in(trashSpace)]
+	return ok && v == nil
+}
@@ -0,0 +1,52 @@
+package bucket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/pkg/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryRepoCommitHookMutations(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "acme")
+	var commits [][]Mutation
+	repo := NewInMemoryRepo(WithCommitHook(func(ctx context.Context, mutations []Mutation) error {
+		commits = append(commits, mutations)
+		return nil
+	}))
+
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "object"))
+	require.NoError(t, repo.PutObjectLegalHold(ctx, "bucket", "object", true))
+	require.NoError(t, repo.PutObjectLegalHold(ctx, "bucket", "object", false))
+	require.NoError(t, repo.TrashObject(ctx, "bucket", "object", time.Now()))
+	require.NoError(t, repo.RestoreObject(ctx, "bucket", "object"))
+	require.NoError(t, repo.TrashObject(ctx, "bucket", "object", time.Now().Add(-time.Hour)))
+	_, err := repo.PurgeTrash(ctx, time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]Mutation{
+		{{Type: MutationBucketCreated, TenantId: "acme", BucketId: "bucket"}, {Type: MutationObjectCreated, TenantId: "acme", BucketId: "bucket", ObjectId: "object"}},
+		{{Type: MutationObjectUpdated, TenantId: "acme", BucketId: "bucket", ObjectId: "object"}},
+		{{Type: MutationObjectLockChanged, TenantId: "acme", BucketId: "bucket", ObjectId: "object"}},
+		{{Type: MutationObjectLockChanged, TenantId: "acme", BucketId: "bucket", ObjectId: "object"}},
+		{{Type: MutationObjectTrashed, TenantId: "acme", BucketId: "bucket", ObjectId: "object"}},
+		{{Type: MutationObjectRestored, TenantId: "acme", BucketId: "bucket", ObjectId: "object"}},
+		{{Type: MutationObjectTrashed, TenantId: "acme", BucketId: "bucket", ObjectId: "object"}},
+		{{Type: MutationObjectPurged, TenantId: "acme", BucketId: "bucket", ObjectId: "object"}},
+	}, commits)
+}
+
+func TestInMemoryRepoCommitHookAbortsCommit(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepo(WithCommitHook(func(ctx context.Context, mutations []Mutation) error {
+		return assert.AnError
+	}))
+
+	assert.ErrorIs(t, repo.InsertObject(ctx, "bucket", "object"), assert.AnError)
+	_, err := repo.GetObject(ctx, "bucket", "object")
+	assert.Error(t, err)
+}
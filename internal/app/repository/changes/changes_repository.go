@@ -0,0 +1,27 @@
+package changes
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	// Append stores events in order, assigning them consecutive sequence numbers.
+	Append(ctx context.Context, events ...Event) ([]Event, error)
+	// List returns up to limit events with a sequence greater than since, optionally
+	// restricted to tenantId and bucketId.
+	List(ctx context.Context, since uint64, tenantId, bucketId string, limit int) ([]Event, error)
+	LastSequence(ctx context.Context) (uint64, error)
+	// Purge drops the events with a sequence up to through, but for the last one, which
+	// numbers the next events, and returns how many were dropped.
+	Purge(ctx context.Context, through uint64) (int, error)
+}
+
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	TenantId string    `json:"tenantId"`
+	BucketId string    `json:"bucketId"`
+	ObjectId string    `json:"objectId,omitempty"`
+	Sequence uint64    `json:"sequence"`
+}
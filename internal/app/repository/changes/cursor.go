@@ -0,0 +1,96 @@
+package changes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Cursor
+//
+// the position of a consumer in the change log: the sequence of the last event it processed.
+type Cursor interface {
+	// Load returns the stored position, ok is false when none was stored yet.
+	Load(ctx context.Context) (sequence uint64, ok bool, err error)
+	Store(ctx context.Context, sequence uint64) error
+}
+
+type InMemoryCursor struct {
+	sequence uint64
+	stored   bool
+	mu       sync.Mutex
+}
+
+func NewInMemoryCursor() *InMemoryCursor {
+	return &InMemoryCursor{}
+}
+
+func (c *InMemoryCursor) Load(ctx context.Context) (uint64, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sequence, c.stored, nil
+}
+
+func (c *InMemoryCursor) Store(ctx context.Context, sequence uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sequence, c.stored = sequence, true
+	return nil
+}
+
+// FileCursor
+//
+// keeps the position in a file, replaced atomically on every Store.
+type FileCursor struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileCursor(path string) *FileCursor {
+	return &FileCursor{path: path}
+}
+
+func (c *FileCursor) Load(ctx context.Context) (uint64, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("read cursor: %w", err)
+	}
+	sequence, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("read cursor: %w", err)
+	}
+	return sequence, true, nil
+}
+
+func (c *FileCursor) Store(ctx context.Context, sequence uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tmp := c.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("write cursor: %w", err)
+	}
+	_, err = file.WriteString(strconv.FormatUint(sequence, 10) + "\n")
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write cursor: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("write cursor: %w", err)
+	}
+	return nil
+}
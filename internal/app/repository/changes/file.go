@@ -0,0 +1,187 @@
+package changes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"bucket_organizer/internal/pkg/metrics"
+)
+
+// FileRepo
+//
+// durable change log: events are appended to an NDJSON file and synced before Append
+// returns. The file is replayed on open and served from memory. Purged events stay in the
+// file until they outnumber the others, then the file is rewritten without them.
+type FileRepo struct {
+	*InMemoryRepo
+	path string
+	file *os.File
+	// purged counts the events still in the file but no longer in memory
+	purged int
+}
+
+func NewFileRepo(path string) (*FileRepo, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open change log: %w", err)
+	}
+	r := &FileRepo{
+		InMemoryRepo: NewInMemoryRepo(),
+		path:         path,
+		file:         file,
+	}
+	if err := r.replay(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// replay
+//
+// loads the events stored in the file. A torn last line, left by a crash in the middle of
+// a write, is truncated away.
+func (r *FileRepo) replay() error {
+	data, err := io.ReadAll(r.file)
+	if err != nil {
+		return fmt.Errorf("read change log: %w", err)
+	}
+	offset := 0
+	for offset < len(data) {
+		end := bytes.IndexByte(data[offset:], '\n')
+		if end < 0 {
+			return r.truncate(offset)
+		}
+		line := data[offset : offset+end]
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e Event
+			if err := json.Unmarshal(line, &e); err != nil {
+				return fmt.Errorf("read change log at offset %d: %w", offset, err)
+			}
+			r.events = append(r.events, e)
+		}
+		offset += end + 1
+	}
+	return nil
+}
+
+func (r *FileRepo) truncate(size int) error {
+	if err := r.file.Truncate(int64(size)); err != nil {
+		return fmt.Errorf("truncate change log: %w", err)
+	}
+	return nil
+}
+
+func (r *FileRepo) Append(ctx context.Context, events ...Event) ([]Event, error) {
+	defer metrics.RepositoryOperations.Since(time.Now(), "changes", "append")
+	_, span := startSpan(ctx, "file", "append")
+	defer span.End()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next := r.last() + 1
+	numbered := make([]Event, len(events))
+	for i, e := range events {
+		e.Sequence = next + uint64(i)
+		numbered[i] = e
+	}
+	if err := writeEvents(r.file, numbered...); err != nil {
+		return nil, span.Fail(err)
+	}
+	return r.append(events), nil
+}
+
+func writeEvents(file *os.File, events ...Event) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := encoder.Encode(e); err != nil {
+			return fmt.Errorf("encode change: %w", err)
+		}
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("write change log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("sync change log: %w", err)
+	}
+	return nil
+}
+
+func (r *FileRepo) Purge(ctx context.Context, through uint64) (int, error) {
+	defer metrics.RepositoryOperations.Since(time.Now(), "changes", "purge")
+	_, span := startSpan(ctx, "file", "purge")
+	defer span.End()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	purged := r.purge(through)
+	r.purged += purged
+	if purged == 0 || r.purged < len(r.events) {
+		return purged, nil
+	}
+	if err := r.compact(); err != nil {
+		return purged, span.Fail(err)
+	}
+	return purged, nil
+}
+
+// compact
+//
+// atomically replaces the file with the events in memory and opens it for appending.
+// Callers must hold r.mu for writing.
+func (r *FileRepo) compact() error {
+	tmp := r.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("compact change log: %w", err)
+	}
+	if err := writeEvents(file, r.events...); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("compact change log: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("compact change log: %w", err)
+	}
+	_ = r.file.Close()
+	r.file, err = os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open change log: %w", err)
+	}
+	r.purged = 0
+	return nil
+}
+
+// Check
+//
+// fails when the change log was removed or replaced since it was last opened: the events
+// would then be appended to a file that is gone.
+func (r *FileRepo) Check(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	opened, err := r.file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat change log: %w", err)
+	}
+	current, err := os.Stat(r.path)
+	if err != nil {
+		return fmt.Errorf("stat change log: %w", err)
+	}
+	if !os.SameFile(opened, current) {
+		return errors.New("change log replaced since it was opened")
+	}
+	return nil
+}
+
+func (r *FileRepo) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
@@ -0,0 +1,115 @@
+package changes
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRepoSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "changes.ndjson")
+
+	repo, err := NewFileRepo(path)
+	require.NoError(t, err)
+	appended, err := repo.Append(ctx, Event{Type: "object.created", BucketId: "a", ObjectId: "1"}, Event{Type: "object.created", BucketId: "b", ObjectId: "2"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), appended[0].Sequence)
+	assert.Equal(t, uint64(2), appended[1].Sequence)
+	require.NoError(t, repo.Close())
+
+	repo, err = NewFileRepo(path)
+	require.NoError(t, err)
+	defer repo.Close()
+	last, err := repo.LastSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), last)
+
+	appended, err = repo.Append(ctx, Event{Type: "object.deleted", BucketId: "a", ObjectId: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), appended[0].Sequence)
+
+	events, err := repo.List(ctx, 1, "", "a", 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "object.deleted", events[0].Type)
+}
+
+func TestFileRepoTruncatesTornWrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "changes.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(`{"sequence":1,"type":"object.created","bucketId":"a"}`+"\n"+`{"sequence":2,"ty`), 0o600))
+
+	repo, err := NewFileRepo(path)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	appended, err := repo.Append(ctx, Event{Type: "object.created", BucketId: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), appended[0].Sequence)
+
+	events, err := repo.List(ctx, 0, "", "", 0)
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+}
+
+func TestFileRepoCheck(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "changes.ndjson")
+	repo, err := NewFileRepo(path)
+	require.NoError(t, err)
+	defer repo.Close()
+	require.NoError(t, repo.Check(ctx))
+
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, repo.Check(ctx))
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	assert.EqualError(t, repo.Check(ctx), "change log replaced since it was opened")
+}
+
+func TestFileRepoPurge(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "changes.ndjson")
+	repo, err := NewFileRepo(path)
+	require.NoError(t, err)
+	for range 4 {
+		_, err = repo.Append(ctx, Event{Type: "object.created", BucketId: "a"})
+		require.NoError(t, err)
+	}
+
+	// the purged events stay in the file until they outnumber the others
+	purged, err := repo.Purge(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(data), "\n"))
+
+	// the last event is kept, numbering the next ones
+	purged, err = repo.Purge(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), "\n"))
+	require.NoError(t, repo.Check(ctx))
+
+	appended, err := repo.Append(ctx, Event{Type: "object.deleted", BucketId: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), appended[0].Sequence)
+	require.NoError(t, repo.Close())
+
+	repo, err = NewFileRepo(path)
+	require.NoError(t, err)
+	defer repo.Close()
+	events, err := repo.List(ctx, 0, "", "", 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, uint64(4), events[0].Sequence)
+	assert.Equal(t, uint64(5), events[1].Sequence)
+}
@@ -0,0 +1,121 @@
+package changes
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"bucket_organizer/internal/pkg/metrics"
+	"bucket_organizer/internal/pkg/tracing"
+)
+
+type InMemoryRepo struct {
+	events []Event
+	mu     sync.RWMutex
+}
+
+func NewInMemoryRepo() *InMemoryRepo {
+	return &InMemoryRepo{
+		events: make([]Event, 0),
+	}
+}
+
+func (r *InMemoryRepo) Append(ctx context.Context, events ...Event) ([]Event, error) {
+	defer metrics.RepositoryOperations.Since(time.Now(), "changes", "append")
+	_, span := startSpan(ctx, "in_memory", "append")
+	defer span.End()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.append(events), nil
+}
+
+// append
+//
+// callers must hold r.mu for writing.
+func (r *InMemoryRepo) append(events []Event) []Event {
+	next := r.last() + 1
+	appended := make([]Event, 0, len(events))
+	for _, e := range events {
+		e.Sequence = next
+		next++
+		appended = append(appended, e)
+	}
+	r.events = append(r.events, appended...)
+	return appended
+}
+
+func (r *InMemoryRepo) List(ctx context.Context, since uint64, tenantId, bucketId string, limit int) ([]Event, error) {
+	defer metrics.RepositoryOperations.Since(time.Now(), "changes", "list")
+	// polled by the background workers, so only traced within a request
+	_, span := tracing.StartChild(ctx, tracing.KindClient, "changes.list",
+		tracing.String("db.system", "in_memory"), tracing.String("db.operation", "list"))
+	defer span.End()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	start := sort.Search(len(r.events), func(i int) bool {
+		return r.events[i].Sequence > since
+	})
+	events := make([]Event, 0)
+	for _, e := range r.events[start:] {
+		if limit > 0 && len(events) == limit {
+			break
+		}
+		if tenantId != "" && e.TenantId != tenantId {
+			continue
+		}
+		if bucketId != "" && e.BucketId != bucketId {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func (r *InMemoryRepo) LastSequence(ctx context.Context) (uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.last(), nil
+}
+
+func (r *InMemoryRepo) Purge(ctx context.Context, through uint64) (int, error) {
+	defer metrics.RepositoryOperations.Since(time.Now(), "changes", "purge")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.purge(through), nil
+}
+
+// purge
+//
+// callers must hold r.mu for writing.
+func (r *InMemoryRepo) purge(through uint64) int {
+	n := sort.Search(len(r.events), func(i int) bool {
+		return r.events[i].Sequence > through
+	})
+	n = min(n, len(r.events)-1)
+	if n <= 0 {
+		return 0
+	}
+	// the purged events are released when append next grows the slice
+	r.events = r.events[n:]
+	return n
+}
+
+// last
+//
+// callers must hold r.mu.
+func (r *InMemoryRepo) last() uint64 {
+	if len(r.events) == 0 {
+		return 0
+	}
+	return r.events[len(r.events)-1].Sequence
+}
+
+// startSpan
+//
+// starts the span of a repository operation, named like its metrics; system is the storage
+// serving it.
+func startSpan(ctx context.Context, system, operation string) (context.Context, *tracing.Span) {
+	return tracing.StartKind(ctx, tracing.KindClient, "changes."+operation,
+		tracing.String("db.system", system), tracing.String("db.operation", operation))
+}
@@ -0,0 +1,35 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type Repository interface {
+	// Reserve returns the record stored for key, or claims key for a new request and
+	// returns nil when none exists or the stored one has expired.
+	Reserve(ctx context.Context, key, requestHash string, expiresAt time.Time) (*Record, error)
+	// Complete stores the response of a reserved key.
+	Complete(ctx context.Context, record Record) error
+	// Release drops a reservation so the request can be retried.
+	Release(ctx context.Context, key string) error
+	PurgeExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+// Record
+//
+// the first response produced for an idempotency key. Status is zero while the request
+// holding the reservation is still in flight.
+type Record struct {
+	ExpiresAt   time.Time
+	Header      http.Header
+	Key         string
+	RequestHash string
+	Body        []byte
+	Status      int
+}
+
+func (r *Record) Completed() bool {
+	return r.Status != 0
+}
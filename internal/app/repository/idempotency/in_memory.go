@@ -0,0 +1,59 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type InMemoryRepo struct {
+	records map[string]Record
+	mu      sync.Mutex
+}
+
+func NewInMemoryRepo() *InMemoryRepo {
+	return &InMemoryRepo{
+		records: make(map[string]Record),
+	}
+}
+
+func (r *InMemoryRepo) Reserve(ctx context.Context, key, requestHash string, expiresAt time.Time) (*Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if record, ok := r.records[key]; ok && record.ExpiresAt.After(time.Now()) {
+		return &record, nil
+	}
+	r.records[key] = Record{
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   expiresAt,
+	}
+	return nil, nil
+}
+
+func (r *InMemoryRepo) Complete(ctx context.Context, record Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[record.Key] = record
+	return nil
+}
+
+func (r *InMemoryRepo) Release(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.records, key)
+	return nil
+}
+
+func (r *InMemoryRepo) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	purged := 0
+	for key, record := range r.records {
+		if !record.ExpiresAt.After(now) {
+			delete(r.records, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
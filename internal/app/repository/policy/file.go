@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/tenant"
+)
+
+// FileRepo
+//
+// an InMemoryRepo persisted as a single JSON document, replaced atomically on every change.
+type FileRepo struct {
+	*InMemoryRepo
+	path string
+}
+
+// document
+//
+// the policies and roles of every tenant, by tenant id.
+type document map[string]*tenantPolicies
+
+func NewFileRepo(path string) (*FileRepo, error) {
+	repo := &FileRepo{InMemoryRepo: NewInMemoryRepo(), path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return repo, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read policies: %w", err)
+	}
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("read policies: %w", err)
+	}
+	for tenantId, t := range doc {
+		ctx := tenant.WithTenant(context.Background(), tenantId)
+		for bucketId, policy := range t.Policies {
+			_ = repo.putPolicy(ctx, bucketId, policy)
+		}
+		for _, role := range t.Roles {
+			_ = repo.putRole(ctx, role)
+		}
+	}
+	return repo, nil
+}
+
+func (r *FileRepo) PutPolicy(ctx context.Context, bucketId string, policy auth.Policy) error {
+	return r.update(func() error { return r.InMemoryRepo.putPolicy(ctx, bucketId, policy) })
+}
+
+func (r *FileRepo) DeletePolicy(ctx context.Context, bucketId string) error {
+	return r.update(func() error { return r.InMemoryRepo.deletePolicy(ctx, bucketId) })
+}
+
+func (r *FileRepo) PutRole(ctx context.Context, role Role) error {
+	return r.update(func() error { return r.InMemoryRepo.putRole(ctx, role) })
+}
+
+func (r *FileRepo) DeleteRole(ctx context.Context, name string) error {
+	return r.update(func() error { return r.InMemoryRepo.deleteRole(ctx, name) })
+}
+
+// update
+//
+// applies change and saves the document, under the repository lock.
+func (r *FileRepo) update(change func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := change(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(document(r.tenants), "", "  ")
+	if err != nil {
+		return fmt.Errorf("write policies: %w", err)
+	}
+	tmp := r.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("write policies: %w", err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write policies: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("write policies: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package policy
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRepo(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "policies.json")
+	repo, err := NewFileRepo(path)
+	require.NoError(t, err)
+
+	policy := auth.Policy{Statements: []auth.Statement{{
+		Effect: auth.EffectAllow, Principals: []string{"*"}, Actions: []auth.Action{auth.ActionRead}, Resources: []string{"logs"},
+	}}}
+	require.NoError(t, repo.PutPolicy(ctx, "logs", policy))
+	require.NoError(t, repo.PutRole(ctx, Role{Name: "writers", Permissions: []auth.Permission{{Bucket: "*", Actions: []auth.Action{auth.ActionWrite}}}}))
+	require.NoError(t, repo.PutRole(ctx, Role{Name: "auditors"}))
+	require.NoError(t, repo.DeleteRole(ctx, "auditors"))
+	assert.ErrorIs(t, repo.DeleteRole(ctx, "auditors"), types.ErrNoRoleFound)
+
+	reopened, err := NewFileRepo(path)
+	require.NoError(t, err)
+	found, err := reopened.GetPolicy(ctx, "logs")
+	require.NoError(t, err)
+	assert.Equal(t, policy, found)
+	roles, err := reopened.ListRoles(ctx)
+	require.NoError(t, err)
+	require.Len(t, roles, 1)
+	assert.Equal(t, "writers", roles[0].Name)
+
+	require.NoError(t, reopened.DeletePolicy(ctx, "logs"))
+	_, err = reopened.GetPolicy(ctx, "logs")
+	assert.ErrorIs(t, err, types.ErrNoPolicyFound)
+}
+
+func TestFileRepoIsolatesTenants(t *testing.T) {
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+	path := filepath.Join(t.TempDir(), "policies.json")
+	repo, err := NewFileRepo(path)
+	require.NoError(t, err)
+	require.NoError(t, repo.PutRole(acme, Role{Name: "readers"}))
+	require.NoError(t, repo.PutPolicy(acme, "logs", auth.Policy{}))
+
+	reopened, err := NewFileRepo(path)
+	require.NoError(t, err)
+	_, err = reopened.GetRole(acme, "readers")
+	require.NoError(t, err)
+	_, err = reopened.GetRole(globex, "readers")
+	assert.ErrorIs(t, err, types.ErrNoRoleFound)
+	_, err = reopened.GetPolicy(globex, "logs")
+	assert.ErrorIs(t, err, types.ErrNoPolicyFound)
+	assert.ErrorIs(t, reopened.DeletePolicy(globex, "logs"), types.ErrNoPolicyFound)
+}
@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+)
+
+type InMemoryRepo struct {
+	tenants map[string]*tenantPolicies
+	mu      sync.RWMutex
+}
+
+// tenantPolicies
+//
+// the bucket policies and roles of a tenant.
+type tenantPolicies struct {
+	Policies map[string]auth.Policy `json:"policies"`
+	Roles    map[string]Role        `json:"roles"`
+}
+
+func NewInMemoryRepo() *InMemoryRepo {
+	return &InMemoryRepo{
+		tenants: make(map[string]*tenantPolicies),
+	}
+}
+
+func (r *InMemoryRepo) GetPolicy(ctx context.Context, bucketId string) (auth.Policy, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	policy, ok := r.tenant(ctx).Policies[bucketId]
+	if !ok {
+		return auth.Policy{}, types.ErrNoPolicyFound
+	}
+	return policy, nil
+}
+
+func (r *InMemoryRepo) PutPolicy(ctx context.Context, bucketId string, policy auth.Policy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.putPolicy(ctx, bucketId, policy)
+}
+
+func (r *InMemoryRepo) DeletePolicy(ctx context.Context, bucketId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deletePolicy(ctx, bucketId)
+}
+
+func (r *InMemoryRepo) ListRoles(ctx context.Context) ([]Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t := r.tenant(ctx)
+	roles := make([]Role, 0, len(t.Roles))
+	for _, role := range t.Roles {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r *InMemoryRepo) GetRole(ctx context.Context, name string) (Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	role, ok := r.tenant(ctx).Roles[name]
+	if !ok {
+		return Role{}, types.ErrNoRoleFound
+	}
+	return role, nil
+}
+
+func (r *InMemoryRepo) PutRole(ctx context.Context, role Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.putRole(ctx, role)
+}
+
+func (r *InMemoryRepo) DeleteRole(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deleteRole(ctx, name)
+}
+
+// tenant
+//
+// the policies of the tenant of ctx, empty when it has none. Callers must hold r.mu.
+func (r *InMemoryRepo) tenant(ctx context.Context) *tenantPolicies {
+	if t, ok := r.tenants[tenant.FromContext(ctx)]; ok {
+		return t
+	}
+	return &tenantPolicies{}
+}
+
+// putPolicy, deletePolicy, putRole and deleteRole
+//
+// callers must hold r.mu for writing.
+func (r *InMemoryRepo) putPolicy(ctx context.Context, bucketId string, policy auth.Policy) error {
+	r.writableTenant(ctx).Policies[bucketId] = policy
+	return nil
+}
+
+func (r *InMemoryRepo) deletePolicy(ctx context.Context, bucketId string) error {
+	t := r.tenant(ctx)
+	if _, ok := t.Policies[bucketId]; !ok {
+		return types.ErrNoPolicyFound
+	}
+	delete(t.Policies, bucketId)
+	return nil
+}
+
+func (r *InMemoryRepo) putRole(ctx context.Context, role Role) error {
+	r.writableTenant(ctx).Roles[role.Name] = role
+	return nil
+}
+
+func (r *InMemoryRepo) deleteRole(ctx context.Context, name string) error {
+	t := r.tenant(ctx)
+	if _, ok := t.Roles[name]; !ok {
+		return types.ErrNoRoleFound
+	}
+	delete(t.Roles, name)
+	return nil
+}
+
+func (r *InMemoryRepo) writableTenant(ctx context.Context) *tenantPolicies {
+	id := tenant.FromContext(ctx)
+	t, ok := r.tenants[id]
+	if !ok {
+		t = &tenantPolicies{Policies: make(map[string]auth.Policy), Roles: make(map[string]Role)}
+		r.tenants[id] = t
+	}
+	return t
+}
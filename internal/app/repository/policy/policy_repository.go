@@ -0,0 +1,30 @@
+package policy
+
+import (
+	"context"
+
+	"bucket_organizer/internal/pkg/auth"
+)
+
+// Repository
+//
+// bucket policies and roles belong to the tenant of ctx, see tenant.WithTenant.
+type Repository interface {
+	GetPolicy(ctx context.Context, bucketId string) (auth.Policy, error)
+	PutPolicy(ctx context.Context, bucketId string, policy auth.Policy) error
+	DeletePolicy(ctx context.Context, bucketId string) error
+
+	// ListRoles returns every role of the tenant, sorted by name.
+	ListRoles(ctx context.Context) ([]Role, error)
+	GetRole(ctx context.Context, name string) (Role, error)
+	PutRole(ctx context.Context, role Role) error
+	DeleteRole(ctx context.Context, name string) error
+}
+
+// Role
+//
+// a named group of permissions, granted to the principals holding it.
+type Role struct {
+	Name        string            `json:"name"`
+	Permissions []auth.Permission `json:"permissions"`
+}
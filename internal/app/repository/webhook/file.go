@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"bucket_organizer/internal/pkg/metrics"
+	"bucket_organizer/internal/pkg/types"
+)
+
+// FileRepo
+//
+// durable webhook store and delivery outbox: every change is appended to an NDJSON journal
+// and synced before returning. The journal is replayed on open and then compacted to the
+// records rebuilding the current state.
+type FileRepo struct {
+	*InMemoryRepo
+	path string
+	file *os.File
+}
+
+func NewFileRepo(path string) (*FileRepo, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open webhook journal: %w", err)
+	}
+	r := &FileRepo{
+		InMemoryRepo: NewInMemoryRepo(),
+		path:         path,
+	}
+	err = r.replay(file)
+	_ = file.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.compact(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// replay
+//
+// applies the journal records. A torn last line, left by a crash in the middle of a write,
+// is ignored and dropped by the compaction.
+func (r *FileRepo) replay(file io.Reader) error {
+	reader := bufio.NewReader(file)
+	for offset := 0; ; {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read webhook journal: %w", err)
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec record
+			if err := json.Unmarshal(line, &rec); err != nil {
+				return fmt.Errorf("read webhook journal at offset %d: %w", offset, err)
+			}
+			r.apply(rec)
+		}
+		offset += len(line)
+	}
+}
+
+// compact
+//
+// atomically replaces the journal with a snapshot of the state and opens it for appending.
+func (r *FileRepo) compact() error {
+	tmp := r.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("compact webhook journal: %w", err)
+	}
+	if err := writeRecords(file, r.snapshot()...); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("compact webhook journal: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("compact webhook journal: %w", err)
+	}
+	r.file, err = os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open webhook journal: %w", err)
+	}
+	return nil
+}
+
+// Check
+//
+// fails when the journal was removed or replaced since it was last opened.
+func (r *FileRepo) Check(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	opened, err := r.file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat webhook journal: %w", err)
+	}
+	current, err := os.Stat(r.path)
+	if err != nil {
+		return fmt.Errorf("stat webhook journal: %w", err)
+	}
+	if !os.SameFile(opened, current) {
+		return errors.New("webhook journal replaced since it was opened")
+	}
+	return nil
+}
+
+func (r *FileRepo) CreateWebhook(ctx context.Context, webhook Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.write(record{Webhook: &webhook})
+}
+
+func (r *FileRepo) DeleteWebhook(ctx context.Context, bucketId, webhookId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook, err := r.get(ctx, bucketId, webhookId)
+	if err != nil {
+		return err
+	}
+	return r.write(record{Deleted: &webhook})
+}
+
+func (r *FileRepo) Enqueue(ctx context.Context, cursor uint64, deliveries ...Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.write(record{Cursor: &cursor, Deliveries: deliveries})
+}
+
+func (r *FileRepo) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.deliveries[delivery.Id]; !ok {
+		return types.ErrNoDeliveryFound
+	}
+	return r.write(record{Deliveries: []Delivery{delivery}})
+}
+
+func (r *FileRepo) PurgeDeliveries(ctx context.Context, finishedBefore time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	purged := 0
+	for _, d := range r.deliveries {
+		if d.Finished() && d.LastAttemptAt.Before(finishedBefore) {
+			purged++
+		}
+	}
+	if purged == 0 {
+		return 0, nil
+	}
+	if err := r.write(record{PurgedBefore: &finishedBefore}); err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+func (r *FileRepo) Close() error {
+	return r.file.Close()
+}
+
+// write
+//
+// journals rec, then applies it. Callers must hold r.mu for writing.
+func (r *FileRepo) write(rec record) error {
+	defer metrics.RepositoryOperations.Since(time.Now(), "webhook", "journal")
+	if err := writeRecords(r.file, rec); err != nil {
+		return err
+	}
+	r.apply(rec)
+	return nil
+}
+
+func writeRecords(file *os.File, records ...record) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, rec := range records {
+		if err := encoder.Encode(rec); err != nil {
+			return fmt.Errorf("encode webhook record: %w", err)
+		}
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("write webhook journal: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("sync webhook journal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRepoOutboxSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "webhooks.ndjson")
+	now := time.Now().UTC()
+
+	repo, err := NewFileRepo(path)
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateWebhook(ctx, Webhook{Id: "w1", BucketId: "b", Url: "http://example.com"}))
+	require.NoError(t, repo.CreateWebhook(ctx, Webhook{Id: "w2", BucketId: "b", Url: "http://example.com"}))
+	require.NoError(t, repo.Enqueue(ctx, 7,
+		Delivery{Id: "d1", WebhookId: "w1", Status: DeliveryPending, NextAttemptAt: now, Event: changes.Event{BucketId: "b", Sequence: 6}},
+		Delivery{Id: "d2", WebhookId: "w1", Status: DeliveryPending, NextAttemptAt: now, Event: changes.Event{BucketId: "b", Sequence: 7}},
+		Delivery{Id: "d3", WebhookId: "w2", Status: DeliveryPending, NextAttemptAt: now, Event: changes.Event{BucketId: "b", Sequence: 7}},
+	))
+	require.NoError(t, repo.UpdateDelivery(ctx, Delivery{Id: "d1", WebhookId: "w1", Status: DeliveryDelivered, Attempts: 1, LastAttemptAt: now}))
+	require.NoError(t, repo.DeleteWebhook(ctx, "b", "w2"))
+	require.NoError(t, repo.Close())
+
+	repo, err = NewFileRepo(path)
+	require.NoError(t, err)
+	defer repo.Close()
+	cursor, err := repo.Cursor(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), cursor)
+
+	_, err = repo.GetWebhook(ctx, "b", "w2")
+	assert.ErrorIs(t, err, types.ErrNoWebhookFound)
+	due, err := repo.DueDeliveries(ctx, now, 0)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "d2", due[0].Id)
+
+	purged, err := repo.PurgeDeliveries(ctx, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	deliveries, err := repo.ListDeliveries(ctx, "w1", 0)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+}
+
+func TestFileRepoIgnoresTornWrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "webhooks.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(`{"webhook":{"id":"w1","bucketId":"b"}}`+"\n"+`{"cursor":`), 0o600))
+
+	repo, err := NewFileRepo(path)
+	require.NoError(t, err)
+	require.NoError(t, repo.Enqueue(ctx, 3))
+	require.NoError(t, repo.Close())
+
+	repo, err = NewFileRepo(path)
+	require.NoError(t, err)
+	defer repo.Close()
+	_, err = repo.GetWebhook(ctx, "b", "w1")
+	require.NoError(t, err)
+	cursor, err := repo.Cursor(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), cursor)
+}
@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+)
+
+type InMemoryRepo struct {
+	webhooks   map[string]Webhook
+	deliveries map[string]Delivery
+	cursor     uint64
+	mu         sync.RWMutex
+}
+
+func NewInMemoryRepo() *InMemoryRepo {
+	return &InMemoryRepo{
+		webhooks:   make(map[string]Webhook),
+		deliveries: make(map[string]Delivery),
+	}
+}
+
+// record
+//
+// a single change to the repository state; the unit written to the FileRepo journal.
+type record struct {
+	Webhook      *Webhook   `json:"webhook,omitempty"`
+	Deleted      *Webhook   `json:"deleted,omitempty"`
+	Cursor       *uint64    `json:"cursor,omitempty"`
+	PurgedBefore *time.Time `json:"purgedBefore,omitempty"`
+	Deliveries   []Delivery `json:"deliveries,omitempty"`
+}
+
+func (r *InMemoryRepo) CreateWebhook(ctx context.Context, webhook Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.apply(record{Webhook: &webhook})
+	return nil
+}
+
+func (r *InMemoryRepo) ListWebhooks(ctx context.Context, bucketId string) ([]Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tenantId := tenant.FromContext(ctx)
+	webhooks := make([]Webhook, 0)
+	for _, w := range r.webhooks {
+		if w.TenantId == tenantId && (bucketId == "" || w.BucketId == bucketId) {
+			webhooks = append(webhooks, w)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+		}
+		return webhooks[i].Id < webhooks[j].Id
+	})
+	return webhooks, nil
+}
+
+func (r *InMemoryRepo) GetWebhook(ctx context.Context, bucketId, webhookId string) (Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.get(ctx, bucketId, webhookId)
+}
+
+func (r *InMemoryRepo) DeleteWebhook(ctx context.Context, bucketId, webhookId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook, err := r.get(ctx, bucketId, webhookId)
+	if err != nil {
+		return err
+	}
+	r.apply(record{Deleted: &webhook})
+	return nil
+}
+
+func (r *InMemoryRepo) Cursor(ctx context.Context) (uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cursor, nil
+}
+
+func (r *InMemoryRepo) Enqueue(ctx context.Context, cursor uint64, deliveries ...Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.apply(record{Cursor: &cursor, Deliveries: deliveries})
+	return nil
+}
+
+func (r *InMemoryRepo) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	due := make([]Delivery, 0)
+	for _, d := range r.deliveries {
+		if !d.Finished() && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].Event.Sequence < due[j].Event.Sequence
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (r *InMemoryRepo) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.deliveries[delivery.Id]; !ok {
+		return types.ErrNoDeliveryFound
+	}
+	r.apply(record{Deliveries: []Delivery{delivery}})
+	return nil
+}
+
+func (r *InMemoryRepo) ListDeliveries(ctx context.Context, webhookId string, limit int) ([]Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	deliveries := make([]Delivery, 0)
+	for _, d := range r.deliveries {
+		if d.WebhookId == webhookId {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Event.Sequence > deliveries[j].Event.Sequence
+	})
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (r *InMemoryRepo) PurgeDeliveries(ctx context.Context, finishedBefore time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.apply(record{PurgedBefore: &finishedBefore}), nil
+}
+
+// apply
+//
+// applies rec to the state, returning the number of purged deliveries.
+// Callers must hold r.mu for writing.
+func (r *InMemoryRepo) apply(rec record) int {
+	if rec.Webhook != nil {
+		webhook := *rec.Webhook
+		if webhook.TenantId == "" {
+			// recorded before tenants existed
+			webhook.TenantId = tenant.Default
+		}
+		r.webhooks[webhook.Id] = webhook
+	}
+	if rec.Deleted != nil {
+		delete(r.webhooks, rec.Deleted.Id)
+		for id, d := range r.deliveries {
+			if d.WebhookId == rec.Deleted.Id {
+				delete(r.deliveries, id)
+			}
+		}
+	}
+	if rec.Cursor != nil {
+		r.cursor = max(r.cursor, *rec.Cursor)
+	}
+	for _, d := range rec.Deliveries {
+		r.deliveries[d.Id] = d
+	}
+	purged := 0
+	if rec.PurgedBefore != nil {
+		for id, d := range r.deliveries {
+			if d.Finished() && d.LastAttemptAt.Before(*rec.PurgedBefore) {
+				delete(r.deliveries, id)
+				purged++
+			}
+		}
+	}
+	return purged
+}
+
+// get
+//
+// callers must hold r.mu.
+func (r *InMemoryRepo) get(ctx context.Context, bucketId, webhookId string) (Webhook, error) {
+	webhook, ok := r.webhooks[webhookId]
+	if !ok || webhook.TenantId != tenant.FromContext(ctx) || webhook.BucketId != bucketId {
+		return Webhook{}, types.ErrNoWebhookFound
+	}
+	return webhook, nil
+}
+
+// snapshot
+//
+// returns the records rebuilding the current state. Callers must hold r.mu.
+func (r *InMemoryRepo) snapshot() []record {
+	records := make([]record, 0, len(r.webhooks)+1)
+	for _, w := range r.webhooks {
+		records = append(records, record{Webhook: &w})
+	}
+	deliveries := make([]Delivery, 0, len(r.deliveries))
+	for _, d := range r.deliveries {
+		deliveries = append(deliveries, d)
+	}
+	cursor := r.cursor
+	return append(records, record{Cursor: &cursor, Deliveries: deliveries})
+}
@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"bucket_organizer/internal/app/repository/changes"
+)
+
+// Repository
+//
+// webhooks are listed, read and deleted within the tenant of ctx; the outbox spans every tenant.
+type Repository interface {
+	CreateWebhook(ctx context.Context, webhook Webhook) error
+	// ListWebhooks returns the webhooks of bucketId, or of every bucket when empty.
+	ListWebhooks(ctx context.Context, bucketId string) ([]Webhook, error)
+	GetWebhook(ctx context.Context, bucketId, webhookId string) (Webhook, error)
+	// DeleteWebhook removes the webhook together with its deliveries.
+	DeleteWebhook(ctx context.Context, bucketId, webhookId string) error
+
+	// Cursor returns the sequence of the last change dispatched to the outbox.
+	Cursor(ctx context.Context) (uint64, error)
+	// Enqueue stores deliveries and advances the cursor, atomically.
+	Enqueue(ctx context.Context, cursor uint64, deliveries ...Delivery) error
+	// DueDeliveries returns up to limit pending deliveries to attempt at now, oldest first.
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery Delivery) error
+	// ListDeliveries returns up to limit deliveries of webhookId, most recent first.
+	ListDeliveries(ctx context.Context, webhookId string, limit int) ([]Delivery, error)
+	// PurgeDeliveries drops the finished deliveries last attempted before finishedBefore.
+	PurgeDeliveries(ctx context.Context, finishedBefore time.Time) (int, error)
+}
+
+// Webhook
+//
+// a subscription delivering the changes of a bucket to Url. Empty EventTypes match every
+// change type; Since is the last change recorded before the webhook was created.
+type Webhook struct {
+	CreatedAt  time.Time `json:"createdAt"`
+	Id         string    `json:"id"`
+	TenantId   string    `json:"tenantId"`
+	BucketId   string    `json:"bucketId"`
+	Url        string    `json:"url"`
+	Prefix     string    `json:"prefix"`
+	Secret     string    `json:"secret"`
+	EventTypes []string  `json:"eventTypes"`
+	Since      uint64    `json:"since"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// Delivery
+//
+// an outbox entry: one change to deliver to one webhook, with the outcome of its attempts.
+type Delivery struct {
+	CreatedAt      time.Time      `json:"createdAt"`
+	NextAttemptAt  time.Time      `json:"nextAttemptAt"`
+	LastAttemptAt  time.Time      `json:"lastAttemptAt"`
+	Event          changes.Event  `json:"event"`
+	Id             string         `json:"id"`
+	WebhookId      string         `json:"webhookId"`
+	Status         DeliveryStatus `json:"status"`
+	LastError      string         `json:"lastError,omitempty"`
+	Attempts       int            `json:"attempts"`
+	ResponseStatus int            `json:"responseStatus,omitempty"`
+}
+
+func (d *Delivery) Finished() bool {
+	return d.Status != DeliveryPending
+}
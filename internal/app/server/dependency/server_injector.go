@@ -2,20 +2,302 @@ package dependency
 
 import (
 	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
 
+	"bucket_organizer/internal/app/publisher"
+	"bucket_organizer/internal/app/repository/apikey"
 	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/app/repository/idempotency"
+	"bucket_organizer/internal/app/repository/policy"
+	"bucket_organizer/internal/app/repository/webhook"
 	"bucket_organizer/internal/app/server"
 	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/health"
+	"bucket_organizer/internal/pkg/metrics"
+	"bucket_organizer/internal/pkg/tracing"
 	"bucket_organizer/pkg/logger"
 )
 
 func Inject(ctx context.Context) (*server.Server, error) {
 	logger.Debug(ctx, "injecting dependencies")
+	config := configs.Global()
 
-	bucketRepository := bucket.NewInMemoryRepo()
-	bucketService := services.NewBucketService(bucketRepository)
+	changesRepository, closeChanges, err := newChangesRepository(config.Changes)
+	if err != nil {
+		return nil, err
+	}
+	changeService := services.NewChangeService(changesRepository, config.Changes)
+	watchService := services.NewWatchService(changeService, config.Watch)
 
-	appServices := services.NewServices(bucketService)
+	bucketRepository := bucket.NewInMemoryRepo(bucket.WithCommitHook(changeService.Record))
+	bucketService := services.NewBucketService(bucketRepository, config.Trash)
+	batchService := services.NewBatchService(bucketService, config.Batch)
 
-	return server.NewServer(appServices, nil)
+	idempotencyRepository := idempotency.NewInMemoryRepo()
+	idempotencyService := services.NewIdempotencyService(idempotencyRepository, config.Idempotency)
+
+	webhookRepository, closeWebhooks, err := newWebhookRepository(config.Webhooks, config.Changes)
+	if err != nil {
+		_ = closeChanges()
+		return nil, err
+	}
+	webhookService := services.NewWebhookService(webhookRepository, changeService, config.Webhooks)
+
+	apiKeyRepository, err := newAPIKeyRepository(config.Auth)
+	if err != nil {
+		_ = errors.Join(closeWebhooks(), closeChanges())
+		return nil, err
+	}
+	apiKeyService := services.NewAPIKeyService(apiKeyRepository, config.Auth)
+	policyRepository, err := newPolicyRepository(config.Auth)
+	if err != nil {
+		_ = errors.Join(closeWebhooks(), closeChanges())
+		return nil, err
+	}
+	policyService := services.NewPolicyService(policyRepository, apiKeyService)
+	var tokenService *services.TokenService
+	if config.Auth.JWT.JWKS != "" {
+		tokenService = services.NewTokenService(config.Auth.JWT)
+	}
+	if !config.Auth.Enabled {
+		logger.Info(ctx, "authentication disabled, the administration endpoints are not served and the others are open")
+	} else if config.Auth.SigningSecret == "" {
+		logger.Info(ctx, "AUTH_SIGNING_SECRET not set, SigV4 signatures will not survive a restart")
+	}
+
+	rateLimitService, err := services.NewRateLimitService(config.RateLimit)
+	if err != nil {
+		_ = errors.Join(closeWebhooks(), closeChanges())
+		return nil, err
+	}
+
+	admissionService := services.NewAdmissionService(config.Admission)
+	expvar.Publish("admission", expvar.Func(func() any { return admissionService.Stats() }))
+	registerMetrics(bucketRepository, admissionService)
+
+	tracer, err := newTracer(config.Tracing, config.ServiceName)
+	if err != nil {
+		_ = errors.Join(closeWebhooks(), closeChanges())
+		return nil, err
+	}
+	tracing.SetDefault(tracer)
+
+	eventPublisher, err := newPublisher(config.Publish, config.Changes)
+	if err != nil {
+		_ = errors.Join(closeWebhooks(), closeChanges())
+		return nil, err
+	}
+
+	healthService := services.NewHealthService(config.Health)
+	registerChecks(healthService, map[string]any{
+		"changes":   changesRepository,
+		"webhooks":  webhookRepository,
+		"publisher": eventPublisher,
+	})
+
+	logLevelService := services.NewLogLevelService(config.LogLevel())
+
+	// the live settings, see configs.Config
+	configs.Subscribe(func(c *configs.Config) {
+		if err := logLevelService.Configure(ctx, c.LogLevel()); err != nil {
+			logger.Error(ctx, "log level not reconfigured", err)
+		}
+		bucketService.Configure(c.Trash)
+		if err := rateLimitService.Configure(ctx, c.RateLimit); err != nil {
+			logger.Error(ctx, "rate limits not reconfigured", err)
+		}
+	})
+	configService := services.NewConfigService(time.Duration(config.ReloadInterval) * time.Second)
+
+	appServices := services.NewServices(bucketService, batchService, idempotencyService, changeService, watchService, webhookService, apiKeyService, tokenService, policyService, rateLimitService, admissionService, healthService, logLevelService)
+
+	runners := []func(context.Context){
+		healthService.Worker("trash-purger", bucketService.RunTrashPurger),
+		healthService.Worker("idempotency-purger", idempotencyService.RunPurger),
+		healthService.Worker("webhook-dispatcher", webhookService.RunDispatcher),
+		healthService.Worker("webhook-deliverer", webhookService.RunDeliverer),
+		healthService.Worker("rate-limit-reloader", rateLimitService.RunReloader),
+		healthService.Worker("span-exporter", tracer.RunExporter),
+		healthService.Worker("config-watcher", configService.RunWatcher),
+	}
+	closePublisher := func() error { return nil }
+	if eventPublisher != nil {
+		publishService := services.NewPublishService(eventPublisher, changeService, newPublishCursor(config.Publish))
+		publishService.OnCaughtUp(healthService.Starting("publish relay"))
+		runners = append(runners, healthService.Worker("publish-relay", publishService.RunRelay))
+		closePublisher = eventPublisher.Close
+	}
+
+	workersCtx, stopWorkers := context.WithCancel(context.WithoutCancel(ctx))
+	var workers sync.WaitGroup
+	for _, run := range runners {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workersCtx)
+		}()
+	}
+
+	return server.NewServer(appServices, func(ctx context.Context) error {
+		stopWorkers()
+		workers.Wait()
+		// the tracer last, exporting the spans of the other closers too
+		return errors.Join(closePublisher(), closeWebhooks(), closeChanges(), tracer.Shutdown(ctx))
+	})
+}
+
+// newChangesRepository
+//
+// uses the durable file change log when configured, the in-memory one otherwise.
+func newChangesRepository(config configs.Changes) (changes.Repository, func() error, error) {
+	if config.File == "" {
+		return changes.NewInMemoryRepo(), func() error { return nil }, nil
+	}
+	repo, err := changes.NewFileRepo(config.File)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, repo.Close, nil
+}
+
+// newWebhookRepository
+//
+// uses the durable webhook outbox when configured, the in-memory one otherwise. The outbox
+// cursor points into the change log, so it is only meaningful when the change log is durable too.
+func newWebhookRepository(config configs.Webhooks, changesConfig configs.Changes) (webhook.Repository, func() error, error) {
+	if config.File == "" {
+		return webhook.NewInMemoryRepo(), func() error { return nil }, nil
+	}
+	if changesConfig.File == "" {
+		return nil, nil, errors.New("WEBHOOKS_FILE requires CHANGES_FILE")
+	}
+	repo, err := webhook.NewFileRepo(config.File)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, repo.Close, nil
+}
+
+// newPublisher
+//
+// returns the publisher selected by config.Driver, nil when publishing is disabled.
+func newPublisher(config configs.Publish, changesConfig configs.Changes) (publisher.Publisher, error) {
+	if config.CursorFile != "" && changesConfig.File == "" {
+		return nil, errors.New("PUBLISH_CURSOR_FILE requires CHANGES_FILE")
+	}
+	subject := config.Subject
+	if subject == "" {
+		subject = "buckets"
+	}
+	switch config.Driver {
+	case "":
+		return nil, nil
+	case "channel":
+		return publisher.NewChannelBus(0), nil
+	case "nats":
+		return publisher.NewNATSPublisher(config.Url.Value(), subject, time.Duration(config.Timeout)*time.Second)
+	case "file":
+		return publisher.NewFileSink(config.File)
+	default:
+		return nil, fmt.Errorf("unknown PUBLISH_DRIVER %q", config.Driver)
+	}
+}
+
+func newPublishCursor(config configs.Publish) changes.Cursor {
+	if config.CursorFile == "" {
+		return changes.NewInMemoryCursor()
+	}
+	return changes.NewFileCursor(config.CursorFile)
+}
+
+// newTracer
+//
+// exports the spans to the configured OTLP collector; without one, spans only propagate the
+// trace context and correlate the log lines.
+func newTracer(config configs.Tracing, serviceName string) (*tracing.Tracer, error) {
+	if config.OTLPEndpoint == "" {
+		return tracing.NewTracer(nil, config.SampleRatio), nil
+	}
+	timeout := time.Duration(config.OTLPTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	exporter, err := tracing.NewOTLPExporter(config.OTLPEndpoint, serviceName, config.OTLPHeaders, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return tracing.NewTracer(exporter, config.SampleRatio), nil
+}
+
+// registerChecks
+//
+// registers the readiness checks of the components able to check their dependencies,
+// e.g. the file backed repositories and the NATS publisher.
+func registerChecks(hs *services.HealthService, components map[string]any) {
+	for name, component := range components {
+		if checker, ok := component.(health.Checker); ok {
+			hs.Register(health.Readiness, name, checker.Check)
+		}
+	}
+}
+
+// newAPIKeyRepository
+//
+// uses the durable key file when configured, the in-memory one otherwise.
+func newAPIKeyRepository(config configs.Auth) (apikey.Repository, error) {
+	if config.KeysFile == "" {
+		return apikey.NewInMemoryRepo(), nil
+	}
+	return apikey.NewFileRepo(config.KeysFile)
+}
+
+// newPolicyRepository
+//
+// uses the durable policy file when configured, the in-memory one otherwise.
+func newPolicyRepository(config configs.Auth) (policy.Repository, error) {
+	if config.PoliciesFile == "" {
+		return policy.NewInMemoryRepo(), nil
+	}
+	return policy.NewFileRepo(config.PoliciesFile)
+}
+
+// registerMetrics
+//
+// exposes the state kept by the repositories and services. Objects have no payload in this
+// store, so there are no stored bytes to report.
+func registerMetrics(buckets *bucket.InMemoryRepo, admission *services.AdmissionService) {
+	count := func(field func(c bucket.Count) int) func(emit func(float64, ...string)) {
+		return func(emit func(float64, ...string)) {
+			for _, c := range buckets.Counts() {
+				emit(float64(field(c)), c.TenantId)
+			}
+		}
+	}
+	metrics.Default.MustRegister(
+		metrics.NewFunc(metrics.KindGauge, "buckets", "Buckets, by tenant.", []string{"tenant"},
+			count(func(c bucket.Count) int { return c.Buckets })),
+		metrics.NewFunc(metrics.KindGauge, "objects", "Live objects, by tenant.", []string{"tenant"},
+			count(func(c bucket.Count) int { return c.Objects })),
+		metrics.NewFunc(metrics.KindGauge, "objects_trashed", "Objects in the trash, by tenant.", []string{"tenant"},
+			count(func(c bucket.Count) int { return c.Trashed })),
+		metrics.NewFunc(metrics.KindGauge, "admission_limit", "Adaptive limit of the requests in flight.", nil, func(emit func(float64, ...string)) {
+			emit(admission.Stats().Limit)
+		}),
+		metrics.NewFunc(metrics.KindGauge, "admission_in_flight", "Admitted requests being served.", nil, func(emit func(float64, ...string)) {
+			emit(float64(admission.Stats().InFlight))
+		}),
+		metrics.NewFunc(metrics.KindCounter, "admission_requests_total", "Requests admitted or shed, by priority.", []string{"priority", "outcome"}, func(emit func(float64, ...string)) {
+			stats := admission.Stats()
+			for _, priority := range []string{"debug", "read", "write"} {
+				emit(float64(stats.Admitted[priority]), priority, "admitted")
+				emit(float64(stats.Rejected[priority]), priority, "rejected")
+			}
+		}),
+	)
 }
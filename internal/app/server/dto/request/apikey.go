@@ -0,0 +1,38 @@
+package request
+
+import "bucket_organizer/internal/pkg/auth"
+
+// APIKeyRequest
+//
+// Permissions may be omitted when Roles grant them. Tenant defaults to the tenant of the
+// request; Tenants grants access to other tenants.
+type APIKeyRequest struct {
+	Name        string            `json:"name"`
+	Tenant      string            `json:"tenant"`
+	Tenants     []string          `json:"tenants"`
+	Roles       []string          `json:"roles"`
+	Permissions []auth.Permission `json:"permissions"`
+}
+
+// PresignRequest
+//
+// Method is GET or PUT; ExpiresIn is expressed in seconds.
+type PresignRequest struct {
+	Method    string `json:"method"`
+	ExpiresIn int    `json:"expiresIn"`
+}
+
+type RoleRequest struct {
+	Permissions []auth.Permission `json:"permissions"`
+}
+
+// SimulateRequest
+//
+// Resource is "<bucket>" or "<bucket>/<object>"; Principal defaults to the caller.
+type SimulateRequest struct {
+	Principal *auth.Principal `json:"principal"`
+	Action    auth.Action     `json:"action"`
+	Resource  string          `json:"resource"`
+	SourceIp  string          `json:"sourceIp"`
+	Prefix    string          `json:"prefix"`
+}
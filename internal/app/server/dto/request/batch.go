@@ -0,0 +1,21 @@
+package request
+
+const (
+	BatchOpPut    = "put"
+	BatchOpGet    = "get"
+	BatchOpDelete = "delete"
+	BatchOpCopy   = "copy"
+)
+
+type BatchOperation struct {
+	Op                  string `json:"op"`
+	BucketId            string `json:"bucketId"`
+	ObjectId            string `json:"objectId"`
+	DestinationBucketId string `json:"destinationBucketId,omitempty"`
+	DestinationObjectId string `json:"destinationObjectId,omitempty"`
+}
+
+type BatchRequest struct {
+	Operations    []BatchOperation `json:"operations"`
+	Transactional bool             `json:"transactional"`
+}
@@ -0,0 +1,12 @@
+package request
+
+import "time"
+
+type RetentionRequest struct {
+	RetainUntil time.Time `json:"retainUntil"`
+	Mode        string    `json:"mode"`
+}
+
+type LegalHoldRequest struct {
+	LegalHold bool `json:"legalHold"`
+}
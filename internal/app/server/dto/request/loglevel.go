@@ -0,0 +1,12 @@
+package request
+
+// LogLevelRequest
+//
+// Component is a package name, e.g. "services", or an import path; empty sets the global
+// level. An empty Level drops the level of Component, which follows the global level again.
+// RevertAfter (seconds) restores the previous level, 0 keeps the new one.
+type LogLevelRequest struct {
+	Component   string `json:"component"`
+	Level       string `json:"level"`
+	RevertAfter int    `json:"revertAfter"`
+}
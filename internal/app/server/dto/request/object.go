@@ -0,0 +1,10 @@
+package request
+
+type MoveObjectRequest struct {
+	DestinationBucketId string `json:"destinationBucketId"`
+}
+
+type SwapObjectsRequest struct {
+	BucketId string `json:"bucketId"`
+	ObjectId string `json:"objectId"`
+}
@@ -0,0 +1,18 @@
+package request
+
+const (
+	WatchActionSubscribe   = "subscribe"
+	WatchActionUnsubscribe = "unsubscribe"
+)
+
+// WatchMessage
+//
+// sent by WebSocket watch clients. Id names the subscription and is echoed in every
+// message about it; Since resumes after the given sequence.
+type WatchMessage struct {
+	Since    *uint64 `json:"since,omitempty"`
+	Action   string  `json:"action"`
+	Id       string  `json:"id"`
+	BucketId string  `json:"bucketId"`
+	Prefix   string  `json:"prefix"`
+}
@@ -0,0 +1,11 @@
+package request
+
+// WebhookRequest
+//
+// registers a webhook; a signing secret is generated when Secret is empty.
+type WebhookRequest struct {
+	Url        string   `json:"url"`
+	Prefix     string   `json:"prefix"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"eventTypes"`
+}
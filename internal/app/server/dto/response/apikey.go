@@ -0,0 +1,39 @@
+package response
+
+import (
+	"time"
+
+	"bucket_organizer/internal/pkg/auth"
+)
+
+// APIKeyResponse
+//
+// The secrets, Key and SecretAccessKey, are only returned when the key is created or
+// rotated. Id doubles as the access key id of SigV4 signatures.
+type APIKeyResponse struct {
+	CreatedAt       time.Time         `json:"createdAt"`
+	RotatedAt       *time.Time        `json:"rotatedAt,omitempty"`
+	RevokedAt       *time.Time        `json:"revokedAt,omitempty"`
+	Id              string            `json:"id"`
+	Name            string            `json:"name"`
+	Hint            string            `json:"hint"`
+	Key             string            `json:"key,omitempty"`
+	SecretAccessKey string            `json:"secretAccessKey,omitempty"`
+	Tenant          string            `json:"tenant"`
+	Tenants         []string          `json:"tenants,omitempty"`
+	Roles           []string          `json:"roles,omitempty"`
+	Permissions     []auth.Permission `json:"permissions"`
+}
+
+type PresignResponse struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+	Method    string    `json:"method"`
+	Url       string    `json:"url"`
+}
+
+type SimulateResponse struct {
+	Allowed   bool           `json:"allowed"`
+	Effect    auth.Effect    `json:"effect"`
+	Reasons   []string       `json:"reasons"`
+	Principal auth.Principal `json:"principal"`
+}
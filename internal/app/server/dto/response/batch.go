@@ -0,0 +1,15 @@
+package response
+
+import "bucket_organizer/internal/pkg/types"
+
+type BatchResult struct {
+	Object  *ObjectResponse       `json:"object,omitempty"`
+	Problem *types.ProblemDetails `json:"problem,omitempty"`
+	Op      string                `json:"op"`
+	Index   int                   `json:"index"`
+	Status  int                   `json:"status"`
+}
+
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
@@ -0,0 +1,9 @@
+package response
+
+import "bucket_organizer/internal/app/repository/changes"
+
+type ChangesResponse struct {
+	Events []changes.Event `json:"events"`
+	// LastSequence is the value to pass as "since" to continue following the feed.
+	LastSequence uint64 `json:"lastSequence"`
+}
@@ -0,0 +1,14 @@
+package response
+
+import "time"
+
+type RetentionResponse struct {
+	RetainUntil *time.Time `json:"retainUntil,omitempty"`
+	Mode        string     `json:"mode,omitempty"`
+}
+
+type ObjectLockResponse struct {
+	Retention RetentionResponse `json:"retention"`
+	Id        string            `json:"id"`
+	LegalHold bool              `json:"legalHold"`
+}
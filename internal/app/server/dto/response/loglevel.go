@@ -0,0 +1,18 @@
+package response
+
+import "time"
+
+// LogLevelResponse
+//
+// Level applies to the components without a level of their own; RevertAt is set while a
+// temporary level is in effect.
+type LogLevelResponse struct {
+	Level      string                       `json:"level"`
+	RevertAt   *time.Time                   `json:"revertAt,omitempty"`
+	Components map[string]ComponentLogLevel `json:"components"`
+}
+
+type ComponentLogLevel struct {
+	Level    string     `json:"level"`
+	RevertAt *time.Time `json:"revertAt,omitempty"`
+}
@@ -0,0 +1,9 @@
+package response
+
+import "time"
+
+type TrashedObjectResponse struct {
+	DeletedAt time.Time `json:"deletedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Id        string    `json:"id"`
+}
@@ -0,0 +1,42 @@
+package response
+
+import (
+	"time"
+
+	"bucket_organizer/internal/pkg/types"
+)
+
+const (
+	WatchMessageEvent        = "event"
+	WatchMessageSubscribed   = "subscribed"
+	WatchMessageUnsubscribed = "unsubscribed"
+	WatchMessageOverflow     = "overflow"
+	WatchMessageError        = "error"
+)
+
+type WatchEvent struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Type     string    `json:"type"`
+	BucketId string    `json:"bucketId"`
+	ObjectId string    `json:"objectId"`
+	Sequence uint64    `json:"sequence"`
+}
+
+// WatchOverflow
+//
+// ends a stream whose consumer fell behind; reconnect resuming from ResumeFrom.
+type WatchOverflow struct {
+	ResumeFrom uint64 `json:"resumeFrom"`
+}
+
+// WatchMessage
+//
+// sent to WebSocket watch clients; Id is the subscription the message refers to.
+type WatchMessage struct {
+	Event      *WatchEvent           `json:"event,omitempty"`
+	Problem    *types.ProblemDetails `json:"problem,omitempty"`
+	ResumeFrom *uint64               `json:"resumeFrom,omitempty"`
+	Type       string                `json:"type"`
+	Id         string                `json:"id,omitempty"`
+}
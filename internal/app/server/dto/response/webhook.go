@@ -0,0 +1,41 @@
+package response
+
+import (
+	"time"
+
+	"bucket_organizer/internal/app/repository/changes"
+)
+
+// WebhookResponse
+//
+// Secret is only returned when the webhook is created.
+type WebhookResponse struct {
+	CreatedAt  time.Time `json:"createdAt"`
+	Id         string    `json:"id"`
+	BucketId   string    `json:"bucketId"`
+	Url        string    `json:"url"`
+	Prefix     string    `json:"prefix"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"eventTypes"`
+}
+
+type WebhookDeliveryResponse struct {
+	CreatedAt      time.Time     `json:"createdAt"`
+	NextAttemptAt  *time.Time    `json:"nextAttemptAt,omitempty"`
+	LastAttemptAt  *time.Time    `json:"lastAttemptAt,omitempty"`
+	Event          changes.Event `json:"event"`
+	Id             string        `json:"id"`
+	Status         string        `json:"status"`
+	LastError      string        `json:"lastError,omitempty"`
+	Attempts       int           `json:"attempts"`
+	ResponseStatus int           `json:"responseStatus,omitempty"`
+}
+
+// WebhookPayload
+//
+// the body POSTed to webhook receivers.
+type WebhookPayload struct {
+	DeliveryId string        `json:"deliveryId"`
+	WebhookId  string        `json:"webhookId"`
+	Event      changes.Event `json:"event"`
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+)
+
+// CreateAPIKey
+//
+// POST /admin/keys ; the response carries the secret, which is not retrievable afterwards.
+func CreateAPIKey(ks *services.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req, err := httputils.Decode[request.APIKeyRequest](r)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while decoding api key")
+			return
+		}
+		key, err := ks.Create(ctx, req)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while creating api key")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusCreated, key)
+	}
+}
+
+func ListAPIKeys(ks *services.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		keys, err := ks.List(ctx)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while listing api keys")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, keys)
+	}
+}
+
+// RotateAPIKey
+//
+// POST /admin/keys/{keyId}/rotate ; returns the new secret.
+func RotateAPIKey(ks *services.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		key, err := ks.Rotate(ctx, r.PathValue("keyId"))
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while rotating api key")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, key)
+	}
+}
+
+func RevokeAPIKey(ks *services.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		key, err := ks.Revoke(ctx, r.PathValue("keyId"))
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while revoking api key")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, key)
+	}
+}
+
+// PresignObject
+//
+// POST /objects/{bucketId}/{objectId}/presign ; returns a URL letting anyone GET or PUT the
+// object until it expires, with the permissions of the caller's API key.
+func PresignObject(ks *services.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		bucketId := r.PathValue("bucketId")
+		objectId := r.PathValue("objectId")
+		req, err := httputils.Decode[request.PresignRequest](r)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while decoding presign")
+			return
+		}
+		method := strings.ToUpper(req.Method)
+		action, ok := presignActions[method]
+		if !ok {
+			types.SetErrorInRequestContext(r, types.NewValidationError(types.InvalidParam{Name: "method", Reason: "must be GET or PUT"}), "error while decoding presign")
+			return
+		}
+		if err := auth.AuthorizeRequest(ctx, auth.Request{Action: action, BucketId: bucketId, ObjectId: objectId}); err != nil {
+			types.SetErrorInRequestContext(r, err, "error while authorizing presign")
+			return
+		}
+		u := &url.URL{Scheme: requestScheme(r), Host: r.Host, Path: "/objects/" + bucketId + "/" + objectId}
+		// presigned URLs carry no headers: the signed query names a tenant other than the key's
+		if principal, ok := auth.PrincipalFromContext(ctx); ok && tenant.FromContext(ctx) != principal.HomeTenant() {
+			u.RawQuery = url.Values{"tenant": {tenant.FromContext(ctx)}}.Encode()
+		}
+		presigned, err := ks.Presign(ctx, method, u, time.Duration(req.ExpiresIn)*time.Second)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while presigning object")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, presigned)
+	}
+}
+
+var presignActions = map[string]auth.Action{
+	http.MethodGet: auth.ActionRead,
+	http.MethodPut: auth.ActionWrite,
+}
+
+// requestScheme
+//
+// the scheme the client used, as seen through a TLS terminating proxy.
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/dto/response"
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/types"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// Batch
+//
+// accepts either a JSON request.BatchRequest document or, with Content-Type application/x-ndjson,
+// a stream of operations (one per line) answered by a stream of results as they complete.
+// In NDJSON mode the transactional flag is read from the "transactional" query parameter.
+func Batch(bs *services.BatchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == ndjsonContentType {
+			batchStream(bs, w, r)
+			return
+		}
+		batchDocument(bs, w, r)
+	}
+}
+
+func batchDocument(bs *services.BatchService, w http.ResponseWriter, r *http.Request) {
+	ctx := mutationContext(r)
+	req, err := httputils.Decode[request.BatchRequest](r)
+	if err != nil {
+		types.SetErrorInRequestContext(r, err, "error while decoding batch")
+		return
+	}
+	if len(req.Operations) > bs.MaxOperations() {
+		err := types.NewValidationError(types.InvalidParam{Name: "operations", Reason: fmt.Sprintf("at most %d operations are allowed", bs.MaxOperations())})
+		types.SetErrorInRequestContext(r, err, "error while validating batch")
+		return
+	}
+	ops := make([]services.BatchOperation, 0, len(req.Operations))
+	for i, op := range req.Operations {
+		ops = append(ops, services.BatchOperation{Index: i, BatchOperation: op})
+	}
+
+	results := make([]response.BatchResult, len(ops))
+	if req.Transactional {
+		for _, result := range bs.ExecuteAtomically(ctx, ops) {
+			results[result.Index] = newBatchResult(r, result)
+		}
+	} else {
+		queue := make(chan services.BatchOperation)
+		go func() {
+			defer close(queue)
+			for _, op := range ops {
+				queue <- op
+			}
+		}()
+		bs.Execute(ctx, queue, func(result services.BatchResult) {
+			results[result.Index] = newBatchResult(r, result)
+		})
+	}
+	_ = httputils.Respond(w, r, http.StatusOK, response.BatchResponse{Results: results})
+}
+
+func batchStream(bs *services.BatchService, w http.ResponseWriter, r *http.Request) {
+	ctx := mutationContext(r)
+	transactional, _ := strconv.ParseBool(r.URL.Query().Get("transactional"))
+	decoder := json.NewDecoder(r.Body)
+	next := func(index int) (services.BatchOperation, error) {
+		var op request.BatchOperation
+		if err := decoder.Decode(&op); err != nil {
+			if errors.Is(err, io.EOF) {
+				return services.BatchOperation{}, err
+			}
+			return services.BatchOperation{}, types.NewValidationError(types.InvalidParam{Name: "body", Reason: err.Error()})
+		}
+		if index >= bs.MaxOperations() {
+			return services.BatchOperation{}, types.NewValidationError(types.InvalidParam{Name: "operations", Reason: fmt.Sprintf("at most %d operations are allowed", bs.MaxOperations())})
+		}
+		return services.BatchOperation{Index: index, BatchOperation: op}, nil
+	}
+
+	encoder := json.NewEncoder(w)
+	rc := http.NewResponseController(w)
+	emit := func(result services.BatchResult) {
+		_ = encoder.Encode(newBatchResult(r, result))
+		_ = rc.Flush()
+	}
+
+	if transactional {
+		ops := make([]services.BatchOperation, 0)
+		for {
+			op, err := next(len(ops))
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				types.SetErrorInRequestContext(r, err, "error while decoding batch")
+				return
+			}
+			ops = append(ops, op)
+		}
+		httputils.WriteHeaderAndContextWithType(w, http.StatusOK, r, ndjsonContentType)
+		for _, result := range bs.ExecuteAtomically(ctx, ops) {
+			emit(result)
+		}
+		return
+	}
+
+	// results are written while operations are still read: without full duplex, HTTP/1.1
+	// would drain the request body on the first flush, blocking the clients waiting for
+	// results before sending more; HTTP/2 is full duplex already
+	if err := rc.EnableFullDuplex(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		types.SetErrorInRequestContext(r, err, "error while streaming batch")
+		return
+	}
+	httputils.WriteHeaderAndContextWithType(w, http.StatusOK, r, ndjsonContentType)
+	queue := make(chan services.BatchOperation)
+	var decodeErr services.BatchResult
+	go func() {
+		defer close(queue)
+		for index := 0; ; index++ {
+			op, err := next(index)
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				decodeErr = services.BatchResult{Index: index, Err: err}
+				return
+			}
+			queue <- op
+		}
+	}()
+	bs.Execute(ctx, queue, emit)
+	if decodeErr.Err != nil {
+		emit(decodeErr)
+	}
+}
+
+func newBatchResult(r *http.Request, result services.BatchResult) response.BatchResult {
+	br := response.BatchResult{
+		Index:  result.Index,
+		Op:     result.Op,
+		Object: result.Object,
+		Status: batchStatus(result.Op),
+	}
+	if result.Err != nil {
+		pd := types.NewProblemDetailsFromError(r, result.Err)
+		pd.Instance = fmt.Sprintf("%s#/operations/%d", pd.Instance, result.Index)
+		br.Status = pd.Status
+		br.Problem = &pd
+	}
+	return br
+}
+
+func batchStatus(op string) int {
+	switch op {
+	case request.BatchOpPut, request.BatchOpCopy:
+		return http.StatusCreated
+	default:
+		return http.StatusOK
+	}
+}
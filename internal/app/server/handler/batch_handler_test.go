@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/dto/response"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchStreamInterleaves(t *testing.T) {
+	bs := services.NewBatchService(services.NewBucketService(bucket.NewInMemoryRepo(), configs.Trash{}), configs.Batch{Concurrency: 2, MaxOperations: 10})
+	server := httptest.NewServer(Batch(bs))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	body, ops := io.Pipe()
+	// a deadlocked handler waits for the body: end it so that the test fails instead
+	context.AfterFunc(ctx, func() { _ = ops.CloseWithError(ctx.Err()) })
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", ndjsonContentType)
+	responses := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		responses <- resp
+	}()
+
+	// every operation is sent once the result of the previous one is read
+	send := func(objectId string) {
+		require.NoError(t, json.NewEncoder(ops).Encode(request.BatchOperation{Op: request.BatchOpPut, BucketId: "logs", ObjectId: objectId}))
+	}
+	send("a")
+	resp := <-responses
+	require.NotNil(t, resp)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	results := bufio.NewScanner(resp.Body)
+	var result response.BatchResult
+	for i := range 2 {
+		require.True(t, results.Scan(), "result %d", i)
+		require.NoError(t, json.Unmarshal(results.Bytes(), &result))
+		assert.Equal(t, i, result.Index)
+		assert.Equal(t, http.StatusCreated, result.Status)
+		if i == 0 {
+			send("b")
+		}
+	}
+	require.NoError(t, ops.Close())
+	assert.False(t, results.Scan())
+}
@@ -1,16 +1,45 @@
 package handler
 
 import (
+	"context"
 	"net/http"
+	"strconv"
 
+	"bucket_organizer/internal/app/server/dto/request"
 	"bucket_organizer/internal/app/server/httputils"
 	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/auth"
 	"bucket_organizer/internal/pkg/types"
 )
 
+const bypassGovernanceHeader = "X-Bypass-Governance-Retention"
+
+// mutationContext
+//
+// returns the request context, allowing governance retention bypass when requested by a
+// principal holding the admin action on the bucket (on every bucket for batches) and on the
+// other buckets the request mutates.
+func mutationContext(r *http.Request, otherBucketIds ...string) context.Context {
+	ctx := r.Context()
+	bucketId := r.PathValue("bucketId")
+	if bucketId == "" {
+		bucketId = auth.AllBuckets
+	}
+	bypass, _ := strconv.ParseBool(r.Header.Get(bypassGovernanceHeader))
+	if !bypass {
+		return ctx
+	}
+	for _, id := range append([]string{bucketId}, otherBucketIds...) {
+		if auth.Authorize(ctx, id, auth.ActionAdmin) != nil {
+			return ctx
+		}
+	}
+	return services.WithGovernanceBypass(ctx)
+}
+
 func UploadObject(bs *services.BucketService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
+		ctx := mutationContext(r)
 		bucketId := r.PathValue("bucketId")
 		objectId := r.PathValue("objectId")
 		object, err := bs.InsertObject(ctx, bucketId, objectId)
@@ -38,7 +67,7 @@ func GetObject(bs *services.BucketService) http.HandlerFunc {
 
 func DeleteObject(bs *services.BucketService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
+		ctx := mutationContext(r)
 		bucketId := r.PathValue("bucketId")
 		objectId := r.PathValue("objectId")
 		if err := bs.RemoveObject(ctx, bucketId, objectId); err != nil {
@@ -48,3 +77,150 @@ func DeleteObject(bs *services.BucketService) http.HandlerFunc {
 		_ = httputils.Respond(w, r, http.StatusOK, "")
 	}
 }
+
+func ListTrash(bs *services.BucketService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		bucketId := r.PathValue("bucketId")
+		objects, err := bs.ListTrash(ctx, bucketId)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while listing trash")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, objects)
+	}
+}
+
+func RestoreObject(bs *services.BucketService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		bucketId := r.PathValue("bucketId")
+		objectId := r.PathValue("objectId")
+		object, err := bs.RestoreObject(ctx, bucketId, objectId)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while restoring object")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, object)
+	}
+}
+
+func GetObjectLock(bs *services.BucketService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		bucketId := r.PathValue("bucketId")
+		objectId := r.PathValue("objectId")
+		lock, err := bs.GetObjectLock(ctx, bucketId, objectId)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while getting object lock")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, lock)
+	}
+}
+
+func PutObjectRetention(bs *services.BucketService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := mutationContext(r)
+		bucketId := r.PathValue("bucketId")
+		objectId := r.PathValue("objectId")
+		req, err := httputils.Decode[request.RetentionRequest](r)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while decoding retention")
+			return
+		}
+		lock, err := bs.PutObjectRetention(ctx, bucketId, objectId, req)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while setting object retention")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, lock)
+	}
+}
+
+// PutObjectLegalHold
+//
+// placing a hold takes the write action, releasing it the admin action on the bucket: a
+// hold a writer could release would not stop the writer from removing the object.
+func PutObjectLegalHold(bs *services.BucketService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		bucketId := r.PathValue("bucketId")
+		objectId := r.PathValue("objectId")
+		req, err := httputils.Decode[request.LegalHoldRequest](r)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while decoding legal hold")
+			return
+		}
+		if !req.LegalHold {
+			if err := auth.Authorize(ctx, bucketId, auth.ActionAdmin); err != nil {
+				types.SetErrorInRequestContext(r, err, "error while authorizing legal hold release")
+				return
+			}
+		}
+		lock, err := bs.PutObjectLegalHold(ctx, bucketId, objectId, req.LegalHold)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while setting object legal hold")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, lock)
+	}
+}
+
+func MoveObject(bs *services.BucketService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketId := r.PathValue("bucketId")
+		objectId := r.PathValue("objectId")
+		req, err := httputils.Decode[request.MoveObjectRequest](r)
+		if err == nil && req.DestinationBucketId == "" {
+			err = types.NewValidationError(types.InvalidParam{Name: "destinationBucketId", Reason: "required"})
+		}
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while decoding move")
+			return
+		}
+		ctx := mutationContext(r, req.DestinationBucketId)
+		destination := auth.Request{Action: auth.ActionWrite, BucketId: req.DestinationBucketId, ObjectId: objectId}
+		if err := auth.AuthorizeRequest(ctx, destination); err != nil {
+			types.SetErrorInRequestContext(r, err, "error while authorizing move")
+			return
+		}
+		object, err := bs.MoveObject(ctx, bucketId, objectId, req.DestinationBucketId)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while moving object")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, object)
+	}
+}
+
+func SwapObjects(bs *services.BucketService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketId := r.PathValue("bucketId")
+		objectId := r.PathValue("objectId")
+		req, err := httputils.Decode[request.SwapObjectsRequest](r)
+		if err == nil && (req.BucketId == "" || req.ObjectId == "") {
+			err = types.NewValidationError(types.InvalidParam{Name: "bucketId, objectId", Reason: "required"})
+		}
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while decoding swap")
+			return
+		}
+		ctx := mutationContext(r, req.BucketId)
+		other := auth.Request{Action: auth.ActionWrite, BucketId: req.BucketId, ObjectId: req.ObjectId}
+		err = auth.AuthorizeRequest(ctx, other)
+		if err == nil {
+			other.Action = auth.ActionDelete
+			err = auth.AuthorizeRequest(ctx, other)
+		}
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while authorizing swap")
+			return
+		}
+		if err := bs.SwapObjects(ctx, bucketId, objectId, req.BucketId, req.ObjectId); err != nil {
+			types.SetErrorInRequestContext(r, err, "error while swapping objects")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, "")
+	}
+}
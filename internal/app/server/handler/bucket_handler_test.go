@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/repository/apikey"
+	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/middleware"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutObjectLegalHold(t *testing.T) {
+	ctx := context.Background()
+	repo := bucket.NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(ctx, "logs", "a"))
+	bs := services.NewBucketService(repo, configs.Trash{})
+	ks := services.NewAPIKeyService(apikey.NewInMemoryRepo(), configs.Auth{})
+	writer, err := ks.Create(ctx, request.APIKeyRequest{
+		Name:        "writer",
+		Permissions: []auth.Permission{{Bucket: "logs", Actions: []auth.Action{auth.ActionWrite, auth.ActionDelete}}},
+	})
+	require.NoError(t, err)
+	admin, err := ks.Create(ctx, request.APIKeyRequest{
+		Name:        "admin",
+		Permissions: []auth.Permission{{Bucket: "logs", Actions: []auth.Action{auth.ActionAdmin}}},
+	})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	authn := middleware.Authentication(nil, middleware.APIKeyAuthenticator(ks))
+	write := middleware.Authorize(auth.ActionWrite, middleware.PathBucket)
+	mux.Handle("PUT /objects/{bucketId}/{objectId}/legal-hold", middleware.ErrorResponder(authn(write(PutObjectLegalHold(bs)))))
+	send := func(key, body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/objects/logs/a/legal-hold", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send(writer.Key, `{"legalHold":true}`))
+	// a writer releasing the hold could then remove the object
+	assert.Equal(t, http.StatusForbidden, send(writer.Key, `{"legalHold":false}`))
+	lock, err := bs.GetObjectLock(ctx, "logs", "a")
+	require.NoError(t, err)
+	assert.True(t, lock.LegalHold)
+
+	assert.Equal(t, http.StatusOK, send(admin.Key, `{"legalHold":false}`))
+}
+
+func TestMoveObjectGovernanceBypass(t *testing.T) {
+	ctx := context.Background()
+	repo := bucket.NewInMemoryRepo()
+	bs := services.NewBucketService(repo, configs.Trash{})
+	ks := services.NewAPIKeyService(apikey.NewInMemoryRepo(), configs.Auth{})
+	// admin on the source bucket, a mere writer on the destination
+	sourceAdmin, err := ks.Create(ctx, request.APIKeyRequest{
+		Name: "source-admin",
+		Permissions: []auth.Permission{
+			{Bucket: "logs", Actions: []auth.Action{auth.ActionAdmin}},
+			{Bucket: "archive", Actions: []auth.Action{auth.ActionWrite}},
+		},
+	})
+	require.NoError(t, err)
+	admin, err := ks.Create(ctx, request.APIKeyRequest{
+		Name:        "admin",
+		Permissions: []auth.Permission{{Bucket: "*", Actions: []auth.Action{auth.ActionAdmin}}},
+	})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	authn := middleware.Authentication(nil, middleware.APIKeyAuthenticator(ks))
+	remove := middleware.Authorize(auth.ActionDelete, middleware.PathBucket)
+	mux.Handle("POST /objects/{bucketId}/{objectId}/move", middleware.ErrorResponder(authn(remove(MoveObject(bs)))))
+	move := func(key string) int {
+		require.NoError(t, repo.InsertObject(ctx, "logs", "a"))
+		req := httptest.NewRequest(http.MethodPost, "/objects/logs/a/move", strings.NewReader(`{"destinationBucketId":"archive"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.APIKeyHeader, key)
+		req.Header.Set(bypassGovernanceHeader, "true")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.NoError(t, repo.InsertObject(ctx, "archive", "a"))
+	retention := bucket.Retention{Mode: bucket.RetentionGovernance, RetainUntil: time.Now().Add(time.Hour)}
+	require.NoError(t, repo.PutObjectRetention(ctx, "archive", "a", retention))
+
+	// the retention of the destination is bypassed by its admins only
+	assert.Equal(t, http.StatusConflict, move(sourceAdmin.Key))
+	assert.Equal(t, http.StatusOK, move(admin.Key))
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+)
+
+// ListChanges
+//
+// GET /changes?since=&bucket=&limit=&wait= ; wait (e.g. "20s") enables long polling.
+func ListChanges(cs *services.ChangeService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := r.URL.Query()
+		params := make([]types.InvalidParam, 0)
+		since, err := parseUintParam(query.Get("since"))
+		if err != nil {
+			params = append(params, types.InvalidParam{Name: "since", Reason: "must be a sequence number"})
+		}
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil && query.Get("limit") != "" {
+			params = append(params, types.InvalidParam{Name: "limit", Reason: "must be an integer"})
+		}
+		wait, err := parseWaitParam(query.Get("wait"))
+		if err != nil {
+			params = append(params, types.InvalidParam{Name: "wait", Reason: "must be a duration such as 30s"})
+		}
+		if len(params) > 0 {
+			types.SetErrorInRequestContext(r, types.NewValidationError(params...), "error while parsing changes query")
+			return
+		}
+
+		if wait > 0 {
+			_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(cs.MaxWait() + 5*time.Second))
+		}
+		changes, err := cs.List(ctx, since, tenant.FromContext(ctx), query.Get("bucket"), limit, wait)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while listing changes")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, changes)
+	}
+}
+
+func parseUintParam(value string) (uint64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// parseWaitParam
+//
+// accepts a Go duration or a number of seconds.
+func parseWaitParam(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/health"
+)
+
+// Healthz
+//
+// GET /healthz ; answers as long as the process serves HTTP, without checking anything else.
+func Healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = httputils.Respond(w, r, http.StatusOK, health.Report{Status: health.StatusPass, Checks: map[string]health.Result{}})
+	}
+}
+
+// Livez
+//
+// GET /livez ; the liveness checks, 503 when any fails.
+func Livez(hs *services.HealthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondHealth(w, r, hs.Live(r.Context()))
+	}
+}
+
+// Readyz
+//
+// GET /readyz ; the readiness checks, 503 when any fails, during startup and from the moment
+// shutdown begins.
+func Readyz(hs *services.HealthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondHealth(w, r, hs.Ready(r.Context()))
+	}
+}
+
+func respondHealth(w http.ResponseWriter, r *http.Request, report health.Report) {
+	status := http.StatusOK
+	if report.Status != health.StatusPass {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	_ = httputils.Respond(w, r, status, report)
+}
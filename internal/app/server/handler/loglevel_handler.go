@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/types"
+)
+
+// GetLogLevel
+//
+// GET /admin/log-level ; the global log level and the levels of the components.
+func GetLogLevel(ls *services.LogLevelService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = httputils.Respond(w, r, http.StatusOK, ls.Levels())
+	}
+}
+
+// PutLogLevel
+//
+// PUT /admin/log-level ; sets the global log level or the level of a component, see
+// request.LogLevelRequest.
+func PutLogLevel(ls *services.LogLevelService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req, err := httputils.Decode[request.LogLevelRequest](r)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while decoding log level")
+			return
+		}
+		levels, err := ls.SetLevel(ctx, req)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while setting log level")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, levels)
+	}
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"net/http"
+
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/pkg/metrics"
+	"bucket_organizer/internal/pkg/types"
+)
+
+// Metrics
+//
+// GET /metrics ; the metrics of reg in the Prometheus text format.
+func Metrics(reg *metrics.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httputils.WriteHeaderAndContextWithType(w, http.StatusOK, r, "text/plain; version=0.0.4; charset=utf-8")
+		if err := reg.Write(w); err != nil {
+			types.SetErrorInRequestContext(r, err, "error while writing metrics")
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"net/http"
+
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/types"
+)
+
+func GetBucketPolicy(ps *services.PolicyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		policy, err := ps.GetPolicy(ctx, r.PathValue("bucketId"))
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while getting bucket policy")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, policy)
+	}
+}
+
+// PutBucketPolicy
+//
+// PUT /buckets/{bucketId}/policy ; replaces the whole policy of the bucket.
+func PutBucketPolicy(ps *services.PolicyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req, err := httputils.Decode[auth.Policy](r)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while decoding bucket policy")
+			return
+		}
+		policy, err := ps.PutPolicy(ctx, r.PathValue("bucketId"), req)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while putting bucket policy")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, policy)
+	}
+}
+
+func DeleteBucketPolicy(ps *services.PolicyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if err := ps.DeletePolicy(ctx, r.PathValue("bucketId")); err != nil {
+			types.SetErrorInRequestContext(r, err, "error while deleting bucket policy")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, "")
+	}
+}
+
+func ListRoles(ps *services.PolicyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		roles, err := ps.ListRoles(ctx)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while listing roles")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, roles)
+	}
+}
+
+// PutRole
+//
+// PUT /admin/roles/{role} ; creates the role or replaces its permissions.
+func PutRole(ps *services.PolicyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req, err := httputils.Decode[request.RoleRequest](r)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while decoding role")
+			return
+		}
+		role, err := ps.PutRole(ctx, r.PathValue("role"), req)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while putting role")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, role)
+	}
+}
+
+func DeleteRole(ps *services.PolicyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if err := ps.DeleteRole(ctx, r.PathValue("role")); err != nil {
+			types.SetErrorInRequestContext(r, err, "error while deleting role")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, "")
+	}
+}
+
+// SimulatePolicy
+//
+// POST /policy/simulate ; explains whether a principal may perform an action on a resource.
+func SimulatePolicy(ps *services.PolicyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req, err := httputils.Decode[request.SimulateRequest](r)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while decoding simulation")
+			return
+		}
+		resp, err := ps.Simulate(ctx, req)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while simulating policy")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,17 @@
+package handler
+
+import (
+	"net/http"
+
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/app/services"
+)
+
+// GetRateLimits
+//
+// GET /admin/rate-limits ; the limits in effect, as last reloaded.
+func GetRateLimits(rs *services.RateLimitService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = httputils.Respond(w, r, http.StatusOK, rs.Limits())
+	}
+}
@@ -0,0 +1,337 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/dto/response"
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+const (
+	eventStreamContentType = "text/event-stream"
+	lastEventIdHeader      = "Last-Event-ID"
+	maxWatchSubscriptions  = 16
+	maxWatchMessageSize    = 4096
+)
+
+// WatchEvents
+//
+// GET /watch/{bucketId}?prefix=&since= ; streams object changes as server-sent events.
+// Resumes after the Last-Event-ID header (or since) when present. A consumer that falls
+// behind receives an "overflow" event and should reconnect from its resumeFrom sequence.
+func WatchEvents(ws *services.WatchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		since, resume, err := parseResumeParam(r)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while parsing watch query")
+			return
+		}
+		filter := services.WatchFilter{TenantId: tenant.FromContext(ctx), BucketId: r.PathValue("bucketId"), Prefix: r.URL.Query().Get("prefix")}
+		watcher, err := ws.Watch(ctx, filter, since, resume)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while starting watch")
+			return
+		}
+		defer watcher.Close()
+
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Time{})
+		w.Header().Set("Cache-Control", "no-cache")
+		httputils.WriteHeaderAndContextWithType(w, http.StatusOK, r, eventStreamContentType)
+		_ = rc.Flush()
+
+		for {
+			nextCtx, cancel := context.WithTimeout(ctx, ws.Heartbeat())
+			event, err := watcher.Next(nextCtx)
+			cancel()
+			switch {
+			case err == nil:
+				err = writeServerSentEvent(w, fmt.Sprint(event.Sequence), services.WatchKind(event.Type), newWatchEvent(event))
+			case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+				_, err = fmt.Fprint(w, ": ping\n\n")
+			case errors.Is(err, types.ErrWatchOverflow):
+				logger.Info(ctx, "watch consumer too slow, closing stream")
+				_ = writeServerSentEvent(w, "", response.WatchMessageOverflow, response.WatchOverflow{ResumeFrom: watcher.LastSequence()})
+				_ = rc.Flush()
+				return
+			case errors.Is(err, types.ErrWatchClosed):
+				_ = writeServerSentEvent(w, "", "shutdown", struct{}{})
+				_ = rc.Flush()
+				return
+			default:
+				return
+			}
+			if err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// WatchSocket
+//
+// GET /watch ; WebSocket variant of WatchEvents carrying several subscriptions, managed
+// with request.WatchMessage and answered with response.WatchMessage.
+func WatchSocket(ws *services.WatchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		_ = rc.SetReadDeadline(time.Time{})
+		_ = rc.SetWriteDeadline(time.Time{})
+		// the origins allowed by the CORS policy, which does not apply to websockets; the
+		// patterns of the library match the "*" and "scheme://host" origins alike
+		conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: configs.Global().CorsOrigins()})
+		if err != nil {
+			logger.Error(r.Context(), "error while accepting websocket", err)
+			return
+		}
+		httputils.SetStatusCode(r, http.StatusSwitchingProtocols)
+		conn.SetReadLimit(maxWatchMessageSize)
+
+		session := &watchSession{
+			service:       ws,
+			conn:          conn,
+			r:             r,
+			subscriptions: make(map[string]*watchSubscription),
+		}
+		session.serve(r.Context())
+	}
+}
+
+type watchSession struct {
+	service       *services.WatchService
+	conn          *websocket.Conn
+	r             *http.Request
+	subscriptions map[string]*watchSubscription
+	wg            sync.WaitGroup
+	mu            sync.Mutex
+}
+
+type watchSubscription struct {
+	cancel context.CancelFunc
+}
+
+func (s *watchSession) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		s.wg.Wait()
+		_ = s.conn.CloseNow()
+	}()
+	go s.keepAlive(ctx)
+
+	for {
+		var msg request.WatchMessage
+		if err := wsjson.Read(ctx, s.conn, &msg); err != nil {
+			if websocket.CloseStatus(err) == -1 && ctx.Err() == nil {
+				logger.Debug(ctx, "watch connection closed", logger.NewLogValue("reason", err.Error()))
+			}
+			return
+		}
+		switch msg.Action {
+		case request.WatchActionSubscribe:
+			s.subscribe(ctx, msg)
+		case request.WatchActionUnsubscribe:
+			s.unsubscribe(ctx, msg)
+		default:
+			s.fail(ctx, msg.Id, types.NewValidationError(types.InvalidParam{Name: "action", Reason: "must be subscribe or unsubscribe"}))
+		}
+	}
+}
+
+// keepAlive
+//
+// pings the client while idle and closes the connection when the service shuts down.
+func (s *watchSession) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(s.service.Heartbeat())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, s.service.Heartbeat())
+			err := s.conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				_ = s.conn.CloseNow()
+				return
+			}
+		case <-s.service.Closing():
+			_ = s.conn.Close(websocket.StatusGoingAway, types.ErrWatchClosed.Error())
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *watchSession) subscribe(ctx context.Context, msg request.WatchMessage) {
+	params := make([]types.InvalidParam, 0)
+	if msg.Id == "" {
+		params = append(params, types.InvalidParam{Name: "id", Reason: "must not be empty"})
+	}
+	if msg.BucketId == "" {
+		params = append(params, types.InvalidParam{Name: "bucketId", Reason: "must not be empty"})
+	}
+	s.mu.Lock()
+	_, exists := s.subscriptions[msg.Id]
+	count := len(s.subscriptions)
+	s.mu.Unlock()
+	if exists {
+		params = append(params, types.InvalidParam{Name: "id", Reason: "subscription already exists"})
+	}
+	if count >= maxWatchSubscriptions {
+		params = append(params, types.InvalidParam{Name: "id", Reason: fmt.Sprintf("at most %d subscriptions are allowed", maxWatchSubscriptions)})
+	}
+	if len(params) > 0 {
+		s.fail(ctx, msg.Id, types.NewValidationError(params...))
+		return
+	}
+
+	if err := auth.AuthorizeRequest(s.r.Context(), auth.Request{Action: auth.ActionRead, BucketId: msg.BucketId, Prefix: msg.Prefix}); err != nil {
+		s.fail(ctx, msg.Id, err)
+		return
+	}
+
+	var since uint64
+	if msg.Since != nil {
+		since = *msg.Since
+	}
+	filter := services.WatchFilter{TenantId: tenant.FromContext(s.r.Context()), BucketId: msg.BucketId, Prefix: msg.Prefix}
+	watcher, err := s.service.Watch(ctx, filter, since, msg.Since != nil)
+	if err != nil {
+		s.fail(ctx, msg.Id, err)
+		return
+	}
+	subCtx, cancel := context.WithCancel(ctx)
+	sub := &watchSubscription{cancel: cancel}
+	s.mu.Lock()
+	s.subscriptions[msg.Id] = sub
+	s.mu.Unlock()
+	_ = s.send(ctx, response.WatchMessage{Type: response.WatchMessageSubscribed, Id: msg.Id})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer watcher.Close()
+		defer s.remove(msg.Id, sub)
+		s.stream(subCtx, msg.Id, watcher)
+	}()
+}
+
+func (s *watchSession) stream(ctx context.Context, id string, watcher *services.Watcher) {
+	for {
+		event, err := watcher.Next(ctx)
+		switch {
+		case err == nil:
+			watchEvent := newWatchEvent(event)
+			if s.send(ctx, response.WatchMessage{Type: response.WatchMessageEvent, Id: id, Event: &watchEvent}) != nil {
+				return
+			}
+		case errors.Is(err, types.ErrWatchOverflow):
+			logger.Info(ctx, "watch consumer too slow, dropping subscription", logger.NewLogValue("subscription", id))
+			resumeFrom := watcher.LastSequence()
+			_ = s.send(ctx, response.WatchMessage{Type: response.WatchMessageOverflow, Id: id, ResumeFrom: &resumeFrom})
+			return
+		default:
+			// closed by unsubscribe, the client or the service shutting down
+			return
+		}
+	}
+}
+
+func (s *watchSession) unsubscribe(ctx context.Context, msg request.WatchMessage) {
+	s.mu.Lock()
+	sub, ok := s.subscriptions[msg.Id]
+	s.mu.Unlock()
+	if !ok {
+		s.fail(ctx, msg.Id, types.NewValidationError(types.InvalidParam{Name: "id", Reason: "no such subscription"}))
+		return
+	}
+	s.remove(msg.Id, sub)
+	_ = s.send(ctx, response.WatchMessage{Type: response.WatchMessageUnsubscribed, Id: msg.Id})
+}
+
+// remove
+//
+// ends sub, unless id was already reused by a newer subscription.
+func (s *watchSession) remove(id string, sub *watchSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub.cancel()
+	if s.subscriptions[id] == sub {
+		delete(s.subscriptions, id)
+	}
+}
+
+func (s *watchSession) fail(ctx context.Context, id string, err error) {
+	pd := types.NewProblemDetailsFromError(s.r, err)
+	_ = s.send(ctx, response.WatchMessage{Type: response.WatchMessageError, Id: id, Problem: &pd})
+}
+
+func (s *watchSession) send(ctx context.Context, msg response.WatchMessage) error {
+	writeCtx, cancel := context.WithTimeout(ctx, s.service.Heartbeat())
+	defer cancel()
+	return wsjson.Write(writeCtx, s.conn, msg)
+}
+
+// parseResumeParam
+//
+// reads the sequence to resume after from the Last-Event-ID header or the since parameter.
+func parseResumeParam(r *http.Request) (uint64, bool, error) {
+	name, value := lastEventIdHeader, r.Header.Get(lastEventIdHeader)
+	if value == "" {
+		name, value = "since", r.URL.Query().Get("since")
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+	since, err := parseUintParam(value)
+	if err != nil {
+		return 0, false, types.NewValidationError(types.InvalidParam{Name: name, Reason: "must be a sequence number"})
+	}
+	return since, true, nil
+}
+
+func writeServerSentEvent(w http.ResponseWriter, id, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
+
+func newWatchEvent(event changes.Event) response.WatchEvent {
+	return response.WatchEvent{
+		Time:     event.Time,
+		Kind:     services.WatchKind(event.Type),
+		Type:     event.Type,
+		BucketId: event.BucketId,
+		ObjectId: event.ObjectId,
+		Sequence: event.Sequence,
+	}
+}
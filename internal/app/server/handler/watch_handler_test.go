@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/configs"
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchSocketOrigins(t *testing.T) {
+	t.Setenv("ENV", "test")
+	t.Setenv("SERVER_CORS_ORIGINS", "https://app.example.com")
+	require.NoError(t, configs.LoadConfig())
+	ws := services.NewWatchService(services.NewChangeService(changes.NewInMemoryRepo(), configs.Changes{}), configs.Watch{})
+	server := httptest.NewServer(WatchSocket(ws))
+	defer server.Close()
+
+	dial := func(origin string) (int, error) {
+		header := http.Header{}
+		header.Set("Origin", origin)
+		conn, resp, err := websocket.Dial(context.Background(), "ws"+server.URL[len("http"):], &websocket.DialOptions{HTTPHeader: header})
+		if conn != nil {
+			_ = conn.CloseNow()
+		}
+		return resp.StatusCode, err
+	}
+
+	status, err := dial("https://app.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, status)
+	status, err = dial("https://evil.example.com")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/types"
+)
+
+func CreateWebhook(ws *services.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		bucketId := r.PathValue("bucketId")
+		req, err := httputils.Decode[request.WebhookRequest](r)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while decoding webhook")
+			return
+		}
+		webhook, err := ws.Create(ctx, bucketId, req)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while creating webhook")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusCreated, webhook)
+	}
+}
+
+func ListWebhooks(ws *services.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		bucketId := r.PathValue("bucketId")
+		webhooks, err := ws.List(ctx, bucketId)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while listing webhooks")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, webhooks)
+	}
+}
+
+func DeleteWebhook(ws *services.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		bucketId := r.PathValue("bucketId")
+		webhookId := r.PathValue("webhookId")
+		if err := ws.Delete(ctx, bucketId, webhookId); err != nil {
+			types.SetErrorInRequestContext(r, err, "error while deleting webhook")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, "")
+	}
+}
+
+// ListWebhookDeliveries
+//
+// GET /buckets/{bucketId}/webhooks/{webhookId}/deliveries?limit= ; most recent first.
+func ListWebhookDeliveries(ws *services.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		bucketId := r.PathValue("bucketId")
+		webhookId := r.PathValue("webhookId")
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil && r.URL.Query().Get("limit") != "" {
+			types.SetErrorInRequestContext(r, types.NewValidationError(types.InvalidParam{Name: "limit", Reason: "must be an integer"}), "error while parsing deliveries query")
+			return
+		}
+		deliveries, err := ws.Deliveries(ctx, bucketId, webhookId, limit)
+		if err != nil {
+			types.SetErrorInRequestContext(r, err, "error while listing webhook deliveries")
+			return
+		}
+		_ = httputils.Respond(w, r, http.StatusOK, deliveries)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+
+	"bucket_organizer/internal/pkg/types"
 )
 
 type AppContext string
@@ -13,11 +15,26 @@ type AppContext string
 var StatusCode = AppContext("statusCode")
 
 func WriteHeaderAndContext(w http.ResponseWriter, statusCode int, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	WriteHeaderAndContextWithType(w, statusCode, r, "application/json; charset=utf-8")
+}
+
+// WriteHeaderAndContextWithType
+//
+// like WriteHeaderAndContext, for responses that are not a single JSON document (e.g. streams).
+func WriteHeaderAndContextWithType(w http.ResponseWriter, statusCode int, r *http.Request, contentType string) {
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("X-Content-Type-Options", "nosniff")
+	SetStatusCode(r, statusCode)
+	w.WriteHeader(statusCode)
+}
+
+// SetStatusCode
+//
+// records the response status for the logging middleware, for responses not written
+// through WriteHeaderAndContext (e.g. protocol upgrades).
+func SetStatusCode(r *http.Request, statusCode int) {
 	ctx := context.WithValue(r.Context(), StatusCode, statusCode)
 	*r = *r.WithContext(ctx)
-	w.WriteHeader(statusCode)
 }
 
 func Respond[T any](w http.ResponseWriter, r *http.Request, status int, v T) error {
@@ -29,3 +46,14 @@ func Respond[T any](w http.ResponseWriter, r *http.Request, status int, v T) err
 	}
 	return nil
 }
+
+// Decode
+//
+// decodes the JSON request body into T, reporting malformed bodies as a validation error.
+func Decode[T any](r *http.Request) (T, error) {
+	var v T
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		return v, types.NewValidationError(types.InvalidParam{Name: "body", Reason: err.Error()})
+	}
+	return v, nil
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/admission"
+)
+
+const admissionErrorMessage = "error while admitting request"
+
+// Admission
+//
+// sheds the requests beyond the concurrency limit with 503 before any work is done on them,
+// debug traffic first, then reads, then writes. Streaming and long polling routes must not
+// use it: their duration says nothing about the load of the server.
+func Admission(as *services.AdmissionService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			done, err := as.Admit(r.Context(), priority(r))
+			if err != nil {
+				w.Header().Set(RetryAfterHeader, "1")
+				respondError(w, r, err, admissionErrorMessage)
+				return
+			}
+			defer done()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func priority(r *http.Request) admission.Priority {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/debug/"):
+		return admission.PriorityDebug
+	case r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions:
+		return admission.PriorityRead
+	default:
+		return admission.PriorityWrite
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/configs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmission(t *testing.T) {
+	as := services.NewAdmissionService(configs.Admission{Enabled: true, InitialLimit: 2, MinLimit: 2, MaxLimit: 2})
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := Admission(as)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	send := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	go send(http.MethodPut, "/objects/logs/a")
+	<-started
+	// debug traffic may only fill half of the limit, reads 90% of it and writes all of it
+	assert.Equal(t, http.StatusServiceUnavailable, send(http.MethodGet, "/debug/vars").Code)
+	go send(http.MethodPut, "/objects/logs/b")
+	<-started
+	shed := send(http.MethodGet, "/objects/logs/a")
+	assert.Equal(t, http.StatusServiceUnavailable, shed.Code)
+	assert.Equal(t, "1", shed.Header().Get(RetryAfterHeader))
+	assert.Contains(t, shed.Body.String(), `"status":503`)
+	assert.Equal(t, http.StatusServiceUnavailable, send(http.MethodPut, "/objects/logs/c").Code)
+	close(release)
+
+	stats := as.Stats()
+	assert.Equal(t, uint64(1), stats.Rejected["read"])
+	assert.Equal(t, uint64(1), stats.Rejected["debug"])
+
+	disabled := Admission(services.NewAdmissionService(configs.Admission{}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	disabled.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/objects/logs/a", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+)
+
+const (
+	APIKeyHeader         = "X-API-Key"
+	authErrorMessage     = "error while authenticating request"
+	authzErrorMessage    = "error while authorizing request"
+	bearerPrefix         = "Bearer "
+	wwwAuthenticateRealm = `Bearer realm="bucket_organizer"`
+)
+
+// Authenticator
+//
+// resolves the credentials of a request to a principal. It returns nil, nil when r carries
+// no credentials it understands, so that the next Authenticator can try.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*auth.Principal, error)
+}
+
+// Authentication
+//
+// stores the principal of the first Authenticator recognising the request in its context,
+// along with the evaluator deciding its authorizations (the principal permissions alone
+// when nil); requests no Authenticator recognises are rejected with 401.
+func Authentication(evaluator auth.Evaluator, authenticators ...Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, a := range authenticators {
+				principal, err := a.Authenticate(r)
+				if err != nil {
+					unauthorized(w, r, err)
+					return
+				}
+				if principal != nil {
+					// in place, like the status code and the error, so that Logging sees it
+					ctx := context.WithValue(auth.WithPrincipal(r.Context(), principal), logger.Principal, principal.Id)
+					if evaluator != nil {
+						ctx = auth.WithEvaluator(ctx, evaluator)
+					}
+					if addr, ok := sourceIp(r); ok {
+						ctx = auth.WithSourceIp(ctx, addr)
+					}
+					*r = *r.WithContext(ctx)
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			unauthorized(w, r, types.ErrUnauthenticated)
+		})
+	}
+}
+
+// Authorize
+//
+// rejects with 403 the requests whose principal may not perform action on the bucket
+// returned by bucket, and on the objectId path value or the prefix query parameter when
+// present. Requests without a principal pass, as with authentication disabled.
+func Authorize(action auth.Action, bucket func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err := auth.AuthorizeRequest(r.Context(), auth.Request{
+				Action:   action,
+				BucketId: bucket(r),
+				ObjectId: r.PathValue("objectId"),
+				Prefix:   r.URL.Query().Get("prefix"),
+			})
+			if err != nil {
+				respondError(w, r, err, authzErrorMessage)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PathBucket
+//
+// the bucketId path value.
+func PathBucket(r *http.Request) string {
+	return r.PathValue("bucketId")
+}
+
+// QueryBucket
+//
+// the bucket query parameter, every bucket when absent.
+func QueryBucket(r *http.Request) string {
+	if bucketId := r.URL.Query().Get("bucket"); bucketId != "" {
+		return bucketId
+	}
+	return auth.AllBuckets
+}
+
+// AnyBucket
+//
+// for global endpoints, which require a permission on every bucket.
+func AnyBucket(r *http.Request) string {
+	return auth.AllBuckets
+}
+
+type apiKeyAuthenticator struct {
+	keys *services.APIKeyService
+}
+
+// APIKeyAuthenticator
+//
+// reads the key from the X-API-Key header or from an Authorization bearer token starting
+// with services.APIKeyPrefix; other bearer tokens are left to the next Authenticator.
+func APIKeyAuthenticator(keys *services.APIKeyService) Authenticator {
+	return &apiKeyAuthenticator{keys: keys}
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *http.Request) (*auth.Principal, error) {
+	secret := r.Header.Get(APIKeyHeader)
+	if secret == "" {
+		token, ok := BearerToken(r)
+		if !ok || !strings.HasPrefix(token, services.APIKeyPrefix) {
+			return nil, nil
+		}
+		secret = token
+	}
+	return a.keys.Authenticate(r.Context(), secret)
+}
+
+type sigV4Authenticator struct {
+	keys *services.APIKeyService
+}
+
+// SigV4Authenticator
+//
+// verifies requests signed with AWS Signature Version 4, in the Authorization header or in
+// the query string of a presigned URL, with the secret access key of an API key.
+func SigV4Authenticator(keys *services.APIKeyService) Authenticator {
+	return &sigV4Authenticator{keys: keys}
+}
+
+func (a *sigV4Authenticator) Authenticate(r *http.Request) (*auth.Principal, error) {
+	sig, err := auth.ParseSigV4(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", types.ErrUnauthenticated, err)
+	}
+	if sig == nil {
+		return nil, nil
+	}
+	return a.keys.AuthenticateSigV4(r.Context(), r, sig)
+}
+
+type jwtAuthenticator struct {
+	tokens *services.TokenService
+}
+
+// JWTAuthenticator
+//
+// verifies the Authorization bearer tokens that are not API keys as JWTs.
+func JWTAuthenticator(tokens *services.TokenService) Authenticator {
+	return &jwtAuthenticator{tokens: tokens}
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (*auth.Principal, error) {
+	token, ok := BearerToken(r)
+	if !ok || strings.HasPrefix(token, services.APIKeyPrefix) {
+		return nil, nil
+	}
+	return a.tokens.Authenticate(r.Context(), token)
+}
+
+// BearerToken
+//
+// the token of an Authorization: Bearer header.
+func BearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(bearerPrefix):])
+	return token, token != ""
+}
+
+// sourceIp
+//
+// the address of the peer; forwarding headers are not trusted.
+func sourceIp(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, types.ErrUnauthenticated) {
+		w.Header().Set("WWW-Authenticate", wwwAuthenticateRealm)
+	}
+	respondError(w, r, err, authErrorMessage)
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/repository/apikey"
+	"bucket_organizer/internal/app/repository/policy"
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticationAndAuthorization(t *testing.T) {
+	ks := services.NewAPIKeyService(apikey.NewInMemoryRepo(), configs.Auth{})
+	key, err := ks.Create(context.Background(), request.APIKeyRequest{
+		Name:        "reader",
+		Permissions: []auth.Permission{{Bucket: "logs", Actions: []auth.Action{auth.ActionRead}}},
+	})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		_, _ = w.Write([]byte(principal.Name))
+	})
+	authn := Authentication(nil, APIKeyAuthenticator(ks))
+	mux.Handle("GET /objects/{bucketId}/{objectId}", authn(Authorize(auth.ActionRead, PathBucket)(ok)))
+	mux.Handle("DELETE /objects/{bucketId}/{objectId}", authn(Authorize(auth.ActionDelete, PathBucket)(ok)))
+
+	send := func(method, target string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	missing := send(http.MethodGet, "/objects/logs/a")
+	assert.Equal(t, http.StatusUnauthorized, missing.Code)
+	assert.NotEmpty(t, missing.Header().Get("WWW-Authenticate"))
+	assert.Contains(t, missing.Body.String(), `"status":401`)
+
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/objects/logs/a", APIKeyHeader, "bko_wrong").Code)
+
+	byHeader := send(http.MethodGet, "/objects/logs/a", APIKeyHeader, key.Key)
+	assert.Equal(t, http.StatusOK, byHeader.Code)
+	assert.Equal(t, "reader", byHeader.Body.String())
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/objects/logs/a", "Authorization", "Bearer "+key.Key).Code)
+
+	// other bearer tokens are not API keys
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/objects/logs/a", "Authorization", "Bearer eyJ.x.y").Code)
+
+	forbidden := send(http.MethodDelete, "/objects/logs/a", APIKeyHeader, key.Key)
+	assert.Equal(t, http.StatusForbidden, forbidden.Code)
+	assert.Contains(t, forbidden.Body.String(), `"status":403`)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/objects/other/a", APIKeyHeader, key.Key).Code)
+
+	_, err = ks.Revoke(context.Background(), key.Id)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/objects/logs/a", APIKeyHeader, key.Key).Code)
+}
+
+func TestAuthorizationEvaluatesBucketPolicies(t *testing.T) {
+	ctx := context.Background()
+	ks := services.NewAPIKeyService(apikey.NewInMemoryRepo(), configs.Auth{})
+	ps := services.NewPolicyService(policy.NewInMemoryRepo(), ks)
+	_, err := ps.PutRole(ctx, "readers", request.RoleRequest{Permissions: []auth.Permission{{Bucket: "logs", Actions: []auth.Action{auth.ActionRead}}}})
+	require.NoError(t, err)
+	key, err := ks.Create(ctx, request.APIKeyRequest{Name: "ci", Roles: []string{"readers"}})
+	require.NoError(t, err)
+	_, err = ps.PutPolicy(ctx, "logs", auth.Policy{Statements: []auth.Statement{{
+		Effect:     auth.EffectAllow,
+		Principals: []string{"apikey:" + key.Id},
+		Actions:    []auth.Action{auth.ActionWrite},
+		Resources:  []string{"logs/*"},
+		Conditions: auth.Conditions{SourceIp: []string{"10.0.0.0/8"}},
+	}}})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	authn := Authentication(ps, APIKeyAuthenticator(ks))
+	mux.Handle("GET /objects/{bucketId}/{objectId}", authn(Authorize(auth.ActionRead, PathBucket)(ok)))
+	mux.Handle("PUT /objects/{bucketId}/{objectId}", authn(Authorize(auth.ActionWrite, PathBucket)(ok)))
+	send := func(method, remoteAddr string) int {
+		req := httptest.NewRequest(method, "/objects/logs/a", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(APIKeyHeader, key.Key)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "192.0.2.1:1234"))
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPut, "192.0.2.1:1234"))
+	assert.Equal(t, http.StatusOK, send(http.MethodPut, "10.1.2.3:1234"))
+}
+
+func TestAuthorizeWithoutPrincipal(t *testing.T) {
+	h := Authorize(auth.ActionAdmin, AnyBucket)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/keys", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAuthenticationSetsPrincipalForLogging(t *testing.T) {
+	ks := services.NewAPIKeyService(apikey.NewInMemoryRepo(), configs.Auth{AdminKeyHash: services.HashAPIKey("bko_admin")})
+	h := Authentication(nil, APIKeyAuthenticator(ks))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/keys", nil)
+	req.Header.Set(APIKeyHeader, "bko_admin")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Logging reads the request it passed down once the chain returns
+	principal, ok := auth.PrincipalFromContext(req.Context())
+	require.True(t, ok)
+	assert.Equal(t, "apikey:bootstrap", principal.Id)
+	assert.Equal(t, "apikey:bootstrap", req.Context().Value(logger.Principal))
+}
+
+func TestSigV4PresignedURL(t *testing.T) {
+	ks := services.NewAPIKeyService(apikey.NewInMemoryRepo(), configs.Auth{SigningSecret: "signing secret"})
+	key, err := ks.Create(context.Background(), request.APIKeyRequest{
+		Name:        "reader",
+		Permissions: []auth.Permission{{Bucket: "logs", Actions: []auth.Action{auth.ActionRead}}},
+	})
+	require.NoError(t, err)
+	principal, err := ks.Authenticate(context.Background(), key.Key)
+	require.NoError(t, err)
+	u, _ := url.Parse("http://example.com/objects/logs/a")
+	presigned, err := ks.Presign(auth.WithPrincipal(context.Background(), principal), http.MethodGet, u, time.Minute)
+	require.NoError(t, err)
+
+	h := Authentication(nil, APIKeyAuthenticator(ks), SigV4Authenticator(ks))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(method, target string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, presigned.Url))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPut, presigned.Url))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, strings.Replace(presigned.Url, "X-Amz-Expires=60", "X-Amz-Expires=600", 1)))
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"net/http"
-	"net/url"
 
 	"bucket_organizer/internal/app/server/httputils"
 	"bucket_organizer/internal/pkg/types"
@@ -20,15 +19,11 @@ func ErrorChecker(ctx context.Context) error {
 
 func ErrorResponder(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		u := &url.URL{
-			Scheme: "about",
-			Opaque: "blank",
-		}
 		defer func() {
 			if err := recover(); err != nil {
 				respErr := errors.New(err.(error).Error())
 				types.SetErrorInRequestContext(r, respErr, "internal server error")
-				pd := types.NewProblemDetails(r, u, http.StatusText(http.StatusInternalServerError), "internal server error", http.StatusInternalServerError)
+				pd := types.NewProblemDetails(r, types.BlankProblemType, http.StatusText(http.StatusInternalServerError), "internal server error", http.StatusInternalServerError)
 				_ = httputils.Respond(w, r, pd.Status, pd)
 			}
 		}()
@@ -38,13 +33,7 @@ func ErrorResponder(next http.Handler) http.Handler {
 		if err == nil {
 			return
 		}
-		var pd types.ProblemDetails
-		switch {
-		case errors.Is(err, types.ErrNoBucketFound) || errors.Is(err, types.ErrNoObjectFound):
-			pd = types.NewProblemDetails(r, u, http.StatusText(http.StatusBadRequest), err.Error(), http.StatusBadRequest)
-		default:
-			pd = types.NewProblemDetails(r, u, http.StatusText(http.StatusInternalServerError), err.Error(), http.StatusInternalServerError)
-		}
+		pd := types.NewProblemDetailsFromError(r, err)
 		_ = httputils.Respond(w, r, pd.Status, pd)
 	})
 }
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	idempotencyErrorMessage  = "error while handling idempotency key"
+)
+
+// Idempotency
+//
+// replays the stored response when a request carrying an Idempotency-Key header is retried.
+// Requests without the header are passed through untouched.
+func Idempotency(is *services.IdempotencyService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			if len(key) > maxIdempotencyKeyLength {
+				respondError(w, r, types.NewValidationError(types.InvalidParam{Name: IdempotencyKeyHeader, Reason: "too long"}), idempotencyErrorMessage)
+				return
+			}
+			// keys are chosen by clients: scope them to the tenant and the caller, so that
+			// nobody replays the response of someone else's request
+			if principal, ok := auth.PrincipalFromContext(ctx); ok {
+				key = principal.Id + " " + key
+			}
+			key = tenant.FromContext(ctx) + " " + key
+			requestHash, err := hashRequest(w, r, is.MaxBodySize())
+			if err != nil {
+				respondError(w, r, err, idempotencyErrorMessage)
+				return
+			}
+			stored, err := is.Begin(ctx, key, requestHash)
+			if err != nil {
+				respondError(w, r, err, idempotencyErrorMessage)
+				return
+			}
+			if stored != nil {
+				for name, values := range stored.Header {
+					w.Header()[name] = values
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				httputils.WriteHeaderAndContextWithType(w, stored.Status, r, stored.Header.Get("Content-Type"))
+				_, _ = w.Write(stored.Body)
+				return
+			}
+
+			// the headers set by the outer middlewares, e.g. RateLimit-*, are fresh on every retry
+			outer := w.Header().Clone()
+			rec := &responseRecorder{ResponseWriter: w}
+			defer func() {
+				if err := recover(); err != nil {
+					_ = is.Release(ctx, key)
+					panic(err)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+			_ = is.Complete(ctx, key, requestHash, rec.statusCode(), addedHeader(outer, w.Header()), rec.body.Bytes())
+		})
+	}
+}
+
+// addedHeader
+//
+// the fields of header that are not in before or have changed since.
+func addedHeader(before, header http.Header) http.Header {
+	added := make(http.Header)
+	for name, values := range header {
+		if !slices.Equal(before[name], values) {
+			added[name] = slices.Clone(values)
+		}
+	}
+	return added
+}
+
+// hashRequest
+//
+// fingerprints method, target and body, restoring the body for the next handler; bodies
+// larger than maxBodySize fail with types.ErrRequestTooLarge.
+func hashRequest(w http.ResponseWriter, r *http.Request, maxBodySize int64) (string, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return "", fmt.Errorf("%w: more than %d bytes", types.ErrRequestTooLarge, tooLarge.Limit)
+	}
+	if err != nil {
+		return "", types.NewValidationError(types.InvalidParam{Name: "body", Reason: err.Error()})
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	h := sha256.New()
+	_, _ = io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func respondError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	types.SetErrorInRequestContext(r, err, message)
+	pd := types.NewProblemDetailsFromError(r, err)
+	_ = httputils.Respond(w, r, pd.Status, pd)
+}
+
+// responseRecorder
+//
+// copies the response while writing it through.
+type responseRecorder struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *responseRecorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
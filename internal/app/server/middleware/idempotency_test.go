@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"bucket_organizer/internal/app/repository/idempotency"
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/configs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyReplaysFirstResponse(t *testing.T) {
+	is := services.NewIdempotencyService(idempotency.NewInMemoryRepo(), configs.Idempotency{})
+	calls := 0
+	h := Idempotency(is)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_ = httputils.Respond(w, r, http.StatusCreated, map[string]int{"call": calls})
+	}))
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/objects/bucket/object", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("payload")
+	second := send("payload")
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+
+	reused := send("other payload")
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+	assert.Contains(t, reused.Body.String(), "/problems/idempotency-key-reused")
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotencyReplaysHandlerHeaders(t *testing.T) {
+	is := services.NewIdempotencyService(idempotency.NewInMemoryRepo(), configs.Idempotency{})
+	idempotent := Idempotency(is)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/objects/bucket/object")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	remaining := 10
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remaining--
+		w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(remaining))
+		idempotent.ServeHTTP(w, r)
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/objects/bucket/object", strings.NewReader("payload"))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, "9", send().Header().Get(RateLimitRemainingHeader))
+	replayed := send()
+	assert.Equal(t, "true", replayed.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, "/objects/bucket/object", replayed.Header().Get("Location"))
+	assert.Equal(t, "8", replayed.Header().Get(RateLimitRemainingHeader))
+}
+
+func TestIdempotencyReleasesKeyOnServerError(t *testing.T) {
+	is := services.NewIdempotencyService(idempotency.NewInMemoryRepo(), configs.Idempotency{})
+	calls := 0
+	h := Idempotency(is)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodDelete, "/objects/bucket/object", nil)
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyWithoutKey(t *testing.T) {
+	is := services.NewIdempotencyService(idempotency.NewInMemoryRepo(), configs.Idempotency{})
+	calls := 0
+	h := Idempotency(is)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	for range 2 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/objects/bucket/object", nil))
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyLimitsBodySize(t *testing.T) {
+	is := services.NewIdempotencyService(idempotency.NewInMemoryRepo(), configs.Idempotency{MaxBodySize: 8})
+	calls := 0
+	h := Idempotency(is)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls++ }))
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/objects/bucket/object", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tooLarge := send("more than 8 bytes")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, tooLarge.Code)
+	assert.Equal(t, 0, calls)
+	// the key is not taken by the rejected request
+	assert.Equal(t, http.StatusOK, send("8 bytes!").Code)
+	assert.Equal(t, 1, calls)
+}
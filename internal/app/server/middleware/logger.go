@@ -1,19 +1,16 @@
 package middleware
 
 import (
-	"context"
 	"net/http"
 	"time"
 
 	"bucket_organizer/internal/app/server/httputils"
 	"bucket_organizer/pkg/logger"
-	"github.com/google/uuid"
 )
 
 func Logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		ctx := context.WithValue(r.Context(), logger.TraceId, uuid.New().String())
 
 		logValues := make([]interface{}, 0)
 		if requestID := r.Header.Get("X-Request-Id"); requestID != "" {
@@ -28,8 +25,6 @@ func Logging(next http.Handler) http.Handler {
 		logValues = append(logValues, logger.NewLogValue("realIp", r.Header.Get("X-Real-Ip")))
 		logValues = append(logValues, logger.NewLogValue("userAgent", r.UserAgent()))
 
-		*r = *r.WithContext(ctx)
-
 		logger.InfoNoCaller(r.Context(), "http request started", logValues...)
 
 		next.ServeHTTP(w, r)
@@ -37,8 +32,10 @@ func Logging(next http.Handler) http.Handler {
 		logValues = append(logValues, logger.NewLogValue("secondsElapsed", time.Since(start)))
 		logValues = append(logValues, logger.NewLogValue("statusCode", r.Context().Value(httputils.StatusCode)))
 
+		// the request context now also carries what the handlers set, e.g. the principal
+		ctx := r.Context()
 		msg := "http request completed"
-		err := ErrorChecker(r.Context())
+		err := ErrorChecker(ctx)
 		if err == nil {
 			logger.InfoNoCaller(ctx, msg, logValues...)
 			return
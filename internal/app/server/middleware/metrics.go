@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/metrics"
+	"bucket_organizer/internal/pkg/tenant"
+)
+
+var (
+	httpRequests = metrics.NewCounterVec("http_requests_total",
+		"HTTP requests served, by route pattern, method, status and tenant, \""+otherTenant+"\" for anonymous requests.", "route", "method", "status", "tenant")
+	httpRequestDuration = metrics.NewHistogramVec("http_request_duration_seconds",
+		"Latency of the HTTP requests, by route pattern and method.", metrics.DefaultBuckets, "route", "method")
+	httpRequestsInFlight = metrics.NewGaugeVec("http_requests_in_flight",
+		"HTTP requests being served.")
+)
+
+func init() {
+	metrics.Default.MustRegister(httpRequests, httpRequestDuration, httpRequestsInFlight)
+}
+
+// Metrics
+//
+// counts the requests and observes their latency by route pattern rather than path, so that
+// the series do not grow with the buckets and objects; runs inside Logging, like it reading
+// the status and tenant from the request context once the handler returns.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		httpRequestsInFlight.Add(1)
+		defer httpRequestsInFlight.Add(-1)
+
+		next.ServeHTTP(w, r)
+
+		status := http.StatusOK
+		if code, ok := r.Context().Value(httputils.StatusCode).(int); ok {
+			status = code
+		}
+		httpRequests.Inc(r.Pattern, r.Method, strconv.Itoa(status), metricsTenant(r))
+		httpRequestDuration.Since(start, r.Pattern, r.Method)
+	})
+}
+
+// otherTenant
+//
+// the tenant label of the requests of anonymous clients, who choose their tenant.
+const otherTenant = "other"
+
+// metricsTenant
+//
+// the tenant of r when bound to its principal, its own tenant or one granted by id, and
+// otherTenant otherwise, so that clients cannot add series at will.
+func metricsTenant(r *http.Request) string {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		return otherTenant
+	}
+	tenantId := tenant.FromContext(r.Context())
+	if tenantId == principal.HomeTenant() || slices.Contains(principal.Tenants, tenantId) {
+		return tenantId
+	}
+	return otherTenant
+}
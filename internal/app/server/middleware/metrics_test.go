@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/tenant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsTenant(t *testing.T) {
+	partner := &auth.Principal{Id: "apikey:partner", Tenant: "acme", Tenants: []string{"globex", "initech-*"}}
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		tenant    string
+		want      string
+	}{
+		{"anonymous", nil, "chosen-by-client", otherTenant},
+		{"home tenant", partner, "acme", "acme"},
+		{"granted tenant", partner, "globex", "globex"},
+		{"tenant granted by pattern", partner, "initech-42", otherTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/objects/logs/a", nil)
+			ctx := tenant.WithTenant(r.Context(), tt.tenant)
+			if tt.principal != nil {
+				ctx = auth.WithPrincipal(ctx, tt.principal)
+			}
+			assert.Equal(t, tt.want, metricsTenant(r.WithContext(ctx)))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/tenant"
+)
+
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RetryAfterHeader         = "Retry-After"
+	rateLimitErrorMessage    = "error while admitting request"
+)
+
+// RateLimit
+//
+// limits the rate and the concurrency of the requests of every client to the route, responding
+// 429 with a Retry-After header beyond the limits. It runs after authentication, so that
+// clients are counted by principal or tenant when so configured.
+func RateLimit(rs *services.RateLimitService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			decision, release, err := rs.Admit(r.Context(), rateLimitClient(r, rs.Key()), r.Pattern, r.Method)
+			if decision.Limit > 0 {
+				w.Header().Set(RateLimitLimitHeader, strconv.Itoa(decision.Limit))
+				w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(decision.Remaining))
+				w.Header().Set(RateLimitResetHeader, ceilSeconds(decision.Reset))
+			}
+			if err != nil {
+				w.Header().Set(RetryAfterHeader, ceilSeconds(decision.RetryAfter))
+				respondError(w, r, err, rateLimitErrorMessage)
+				return
+			}
+			defer release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// LimitAuthenticationFailures
+//
+// wraps authn, charging the requests it rejects to the peer address; beyond the limit, the
+// requests of the address are rejected with 429 before being authenticated, so that keys and
+// tokens cannot be guessed at will. Authenticated requests are not charged. Unlike the
+// limits of the routes, it ignores X-Real-Ip, which anonymous clients could rotate.
+func LimitAuthenticationFailures(rs *services.RateLimitService, authn func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := "peer:" + r.RemoteAddr
+			if addr, ok := sourceIp(r); ok {
+				client = "peer:" + addr.String()
+			}
+			if decision, err := rs.AdmitAuthentication(r.Context(), client); err != nil {
+				w.Header().Set(RetryAfterHeader, ceilSeconds(decision.RetryAfter))
+				respondError(w, r, err, rateLimitErrorMessage)
+				return
+			}
+			authenticated := false
+			authn(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authenticated = true
+				next.ServeHTTP(w, r)
+			})).ServeHTTP(w, r)
+			if !authenticated {
+				rs.FailAuthentication(client)
+			}
+		})
+	}
+}
+
+// rateLimitClient
+//
+// the client r is counted against, according to key.
+func rateLimitClient(r *http.Request, key string) string {
+	switch key {
+	case services.RateLimitByTenant:
+		return "tenant:" + tenant.FromContext(r.Context())
+	case services.RateLimitByClient:
+		if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+			return principal.Id
+		}
+	}
+	return "ip:" + clientIp(r)
+}
+
+// clientIp
+//
+// the address of the client, as reported in X-Real-Ip by the proxy in front of the server.
+func clientIp(r *http.Request) string {
+	if addr, err := netip.ParseAddr(r.Header.Get("X-Real-Ip")); err == nil {
+		return addr.Unmap().String()
+	}
+	if addr, ok := sourceIp(r); ok {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
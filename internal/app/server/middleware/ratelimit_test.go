@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bucket_organizer/internal/app/services"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	rs, err := services.NewRateLimitService(configs.RateLimit{ReadRate: 0.001, ReadBurst: 2, WriteRate: 0.001})
+	require.NoError(t, err)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux := http.NewServeMux()
+	mux.Handle("GET /objects/{bucketId}/{objectId}", RateLimit(rs)(ok))
+	mux.Handle("PUT /objects/{bucketId}/{objectId}", RateLimit(rs)(ok))
+	send := func(method, realIp string, principal *auth.Principal) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/objects/logs/a", nil)
+		req.Header.Set("X-Real-Ip", realIp)
+		if principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send(http.MethodGet, "10.0.0.1", nil)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "2", first.Header().Get(RateLimitLimitHeader))
+	assert.Equal(t, "1", first.Header().Get(RateLimitRemainingHeader))
+	assert.Equal(t, "1000", first.Header().Get(RateLimitResetHeader))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "10.0.0.1", nil).Code)
+
+	limited := send(http.MethodGet, "10.0.0.1", nil)
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Equal(t, "1000", limited.Header().Get(RetryAfterHeader))
+	assert.Equal(t, "0", limited.Header().Get(RateLimitRemainingHeader))
+	assert.Contains(t, limited.Body.String(), `"status":429`)
+
+	// writes, other addresses and principals have buckets of their own
+	assert.Equal(t, http.StatusOK, send(http.MethodPut, "10.0.0.1", nil).Code)
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "10.0.0.2", nil).Code)
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "10.0.0.1", &auth.Principal{Id: "apikey:ci"}).Code)
+}
+
+func TestLimitAuthenticationFailures(t *testing.T) {
+	rs, err := services.NewRateLimitService(configs.RateLimit{AuthFailureRate: 0.001, AuthFailureBurst: 2})
+	require.NoError(t, err)
+	authn := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(APIKeyHeader) != "bko_valid" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := LimitAuthenticationFailures(rs, authn)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	realIps := 0
+	send := func(peer, key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/objects/logs/a", nil)
+		req.RemoteAddr = peer + ":4711"
+		// a fresh forwarded address every time, which must not reset the limit
+		realIps++
+		req.Header.Set("X-Real-Ip", fmt.Sprintf("192.0.2.%d", realIps))
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// authenticated requests are not charged
+	for range 3 {
+		assert.Equal(t, http.StatusOK, send("10.0.0.1", "bko_valid"))
+	}
+	assert.Equal(t, http.StatusUnauthorized, send("10.0.0.1", "bko_guess1"))
+	assert.Equal(t, http.StatusUnauthorized, send("10.0.0.1", "bko_guess2"))
+	// beyond the limit, not even a valid key is tried
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.1", "bko_guess3"))
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.1", "bko_valid"))
+	assert.Equal(t, http.StatusOK, send("10.0.0.2", "bko_valid"))
+}
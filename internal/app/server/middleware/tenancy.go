@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+)
+
+const (
+	TenantHeader        = "X-Tenant-Id"
+	tenancyErrorMessage = "error while resolving tenant"
+)
+
+// Tenancy
+//
+// scopes the request to a tenant: the X-Tenant-Id header or, for presigned URLs, the tenant
+// query parameter when present, otherwise the tenant of the principal. Principals may only
+// select a tenant other than their own when it is explicitly granted to them; without
+// authentication the requested tenant is trusted.
+func Tenancy() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenantId := r.Header.Get(TenantHeader)
+			if tenantId == "" {
+				tenantId = r.URL.Query().Get("tenant")
+			}
+			principal, authenticated := auth.PrincipalFromContext(r.Context())
+			if tenantId == "" {
+				tenantId = tenant.Default
+				if authenticated {
+					tenantId = principal.HomeTenant()
+				}
+			}
+			if params := tenant.Validate(TenantHeader, tenantId); len(params) > 0 {
+				respondError(w, r, types.NewValidationError(params...), tenancyErrorMessage)
+				return
+			}
+			if authenticated && !principal.CanAccessTenant(tenantId) {
+				err := fmt.Errorf("%w: tenant %s is not granted to %s", types.ErrForbidden, tenantId, principal.Id)
+				respondError(w, r, err, tenancyErrorMessage)
+				return
+			}
+			// in place, like the principal, so that Logging sees it
+			*r = *r.WithContext(tenant.WithTenant(r.Context(), tenantId))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
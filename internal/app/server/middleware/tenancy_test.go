@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/tenant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenancy(t *testing.T) {
+	h := Tenancy()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(tenant.FromContext(r.Context())))
+	}))
+	send := func(principal *auth.Principal, requested string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/objects/logs/a", nil)
+		if principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+		}
+		if requested != "" {
+			req.Header.Set(TenantHeader, requested)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, tenant.Default, send(nil, "").Body.String())
+	assert.Equal(t, "acme", send(nil, "acme").Body.String())
+	assert.Equal(t, http.StatusBadRequest, send(nil, "Not A Tenant").Code)
+
+	member := &auth.Principal{Id: "apikey:member", Tenant: "acme"}
+	assert.Equal(t, "acme", send(member, "").Body.String())
+	assert.Equal(t, "acme", send(member, "acme").Body.String())
+	forbidden := send(member, "globex")
+	assert.Equal(t, http.StatusForbidden, forbidden.Code)
+	assert.Contains(t, forbidden.Body.String(), `"status":403`)
+
+	partner := &auth.Principal{Id: "apikey:partner", Tenant: "acme", Tenants: []string{"globex-*"}}
+	assert.Equal(t, "globex-eu", send(partner, "globex-eu").Body.String())
+	assert.Equal(t, http.StatusForbidden, send(partner, "initech").Code)
+}
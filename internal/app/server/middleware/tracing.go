@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/pkg/tracing"
+)
+
+// Tracing
+//
+// starts the server span of the request, continuing the trace propagated by the W3C traceparent
+// header if any; the outermost middleware, so that the log lines of Logging carry the trace id.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.StartKind(ctx, tracing.KindServer, r.Pattern,
+			tracing.String("http.request.method", r.Method),
+			tracing.String("http.route", r.Pattern),
+			tracing.String("url.path", r.URL.Path),
+		)
+		defer span.End()
+		*r = *r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+
+		status := http.StatusOK
+		if code, ok := r.Context().Value(httputils.StatusCode).(int); ok {
+			status = code
+		}
+		span.SetAttributes(tracing.Int("http.response.status_code", status))
+		// client errors are the client's; only server errors fail the span
+		if status >= http.StatusInternalServerError {
+			msg := http.StatusText(status)
+			if err := ErrorChecker(r.Context()); err != nil {
+				msg = err.Error()
+			}
+			span.SetStatus(tracing.StatusError, msg)
+		}
+	})
+}
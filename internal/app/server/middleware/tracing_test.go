@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bucket_organizer/internal/app/server/httputils"
+	"bucket_organizer/internal/pkg/tracing"
+	"bucket_organizer/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+	tracer := tracing.NewTracer(exporter, 1)
+	previous := tracing.Default()
+	tracing.SetDefault(tracer)
+	t.Cleanup(func() { tracing.SetDefault(previous) })
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /objects/{bucketId}/{objectId}", Tracing(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Start(r.Context(), "BucketService.GetObject")
+		span.End()
+		_, _ = w.Write([]byte(r.Context().Value(logger.TraceId).(string)))
+		status := http.StatusOK
+		if r.PathValue("objectId") == "broken" {
+			status = http.StatusInternalServerError
+		}
+		httputils.SetStatusCode(r, status)
+	})))
+	send := func(target, traceparent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if traceparent != "" {
+			req.Header.Set(tracing.TraceparentHeader, traceparent)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the trace propagated by the caller is continued, and logged
+	rec := send("/objects/logs/a", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", rec.Body.String())
+	require.NoError(t, tracer.Flush(context.Background()))
+	spans := exporter.Spans()
+	require.Len(t, spans, 2)
+	service, server := spans[0], spans[1]
+	assert.Equal(t, "GET /objects/{bucketId}/{objectId}", server.Name)
+	assert.Equal(t, tracing.KindServer, server.Kind)
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.String())
+	assert.Equal(t, tracing.StatusUnset, server.Status)
+	assert.Contains(t, server.Attributes, tracing.String("url.path", "/objects/logs/a"))
+	assert.Contains(t, server.Attributes, tracing.Int("http.response.status_code", http.StatusOK))
+	assert.Equal(t, server.SpanContext.SpanId, service.Parent)
+
+	// without a valid traceparent a new trace starts
+	rec = send("/objects/logs/broken", "not-a-traceparent")
+	assert.Len(t, rec.Body.String(), 32)
+	assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", rec.Body.String())
+	require.NoError(t, tracer.Flush(context.Background()))
+	spans = exporter.Spans()
+	require.Len(t, spans, 4)
+	assert.False(t, spans[3].Parent.IsValid())
+	assert.Equal(t, tracing.StatusError, spans[3].Status)
+}
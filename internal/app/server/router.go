@@ -1,26 +1,133 @@
 package server
 
 import (
+	"expvar"
 	"net/http"
 	"net/http/pprof"
 
 	"bucket_organizer/internal/app/server/handler"
 	"bucket_organizer/internal/app/server/middleware"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/metrics"
 )
 
-func middlewares(handler http.Handler) http.Handler {
-	return middleware.Logging(middleware.ErrorResponder(handler))
+// middlewares
+//
+// wraps handler with the common chain; extra middlewares run after Tracing, Logging and Metrics
+// and before ErrorResponder, in the given order.
+func middlewares(handler http.Handler, extra ...func(http.Handler) http.Handler) http.Handler {
+	handler = middleware.ErrorResponder(handler)
+	for i := len(extra) - 1; i >= 0; i-- {
+		handler = extra[i](handler)
+	}
+	return middleware.Tracing(middleware.Logging(middleware.Metrics(handler)))
+}
+
+// authentication
+//
+// authenticates the request, unless AUTH_ENABLED is false, then scopes it to its tenant. The
+// failed authentications are rate limited by client address, as the rate limits of the
+// routes only apply once authenticated.
+func (s *Server) authentication() func(http.Handler) http.Handler {
+	tenancy := middleware.Tenancy()
+	if !configs.Global().Auth.Enabled {
+		return tenancy
+	}
+	authenticators := []middleware.Authenticator{
+		middleware.APIKeyAuthenticator(s.services.APIKeyService),
+		middleware.SigV4Authenticator(s.services.APIKeyService),
+	}
+	if s.services.TokenService != nil {
+		authenticators = append(authenticators, middleware.JWTAuthenticator(s.services.TokenService))
+	}
+	authn := middleware.LimitAuthenticationFailures(s.services.RateLimitService, middleware.Authentication(s.services.PolicyService, authenticators...))
+	return func(next http.Handler) http.Handler { return authn(tenancy(next)) }
 }
 
 func (s *Server) setupRoutes() {
-	s.router.Handle("PUT /objects/{bucketId}/{objectId}", middlewares(handler.UploadObject(s.services.BucketService)))
-	s.router.Handle("GET /objects/{bucketId}/{objectId}", middlewares(handler.GetObject(s.services.BucketService)))
-	s.router.Handle("DELETE /objects/{bucketId}/{objectId}", middlewares(handler.DeleteObject(s.services.BucketService)))
-
-	s.router.Handle("GET /debug/pprof/", middlewares(http.HandlerFunc(pprof.Index)))
-	s.router.Handle("GET /debug/pprof/cmdline", middlewares(http.HandlerFunc(pprof.Cmdline)))
-	s.router.Handle("GET /debug/pprof/profile", middlewares(http.HandlerFunc(pprof.Profile)))
-	s.router.Handle("GET /debug/pprof/symbol", middlewares(http.HandlerFunc(pprof.Symbol)))
-	s.router.Handle("GET /debug/pprof/trace", middlewares(http.HandlerFunc(pprof.Trace)))
-	s.router.Handle("GET /debug/pprof/{cmd}", middlewares(http.HandlerFunc(pprof.Index)))
+	idempotent := middleware.Idempotency(s.services.IdempotencyService)
+	admit := middleware.Admission(s.services.AdmissionService)
+	authn := s.authentication()
+	limit := middleware.RateLimit(s.services.RateLimitService)
+	read := middleware.Authorize(auth.ActionRead, middleware.PathBucket)
+	write := middleware.Authorize(auth.ActionWrite, middleware.PathBucket)
+	remove := middleware.Authorize(auth.ActionDelete, middleware.PathBucket)
+	bucketAdmin := middleware.Authorize(auth.ActionAdmin, middleware.PathBucket)
+	admin := middleware.Authorize(auth.ActionAdmin, middleware.AnyBucket)
+
+	s.router.Handle("PUT /objects/{bucketId}/{objectId}", middlewares(handler.UploadObject(s.services.BucketService), admit, authn, limit, write, idempotent))
+	s.router.Handle("GET /objects/{bucketId}/{objectId}", middlewares(handler.GetObject(s.services.BucketService), admit, authn, limit, read))
+	s.router.Handle("DELETE /objects/{bucketId}/{objectId}", middlewares(handler.DeleteObject(s.services.BucketService), admit, authn, limit, remove, idempotent))
+
+	// the destination buckets of move and swap are authorized by the handlers
+	s.router.Handle("POST /objects/{bucketId}/{objectId}/move", middlewares(handler.MoveObject(s.services.BucketService), admit, authn, limit, remove, idempotent))
+	s.router.Handle("POST /objects/{bucketId}/{objectId}/swap", middlewares(handler.SwapObjects(s.services.BucketService), admit, authn, limit, write, remove, idempotent))
+	// the action of the presigned method is authorized by the handler
+	s.router.Handle("POST /objects/{bucketId}/{objectId}/presign", middlewares(handler.PresignObject(s.services.APIKeyService), admit, authn, limit))
+	s.router.Handle("GET /objects/{bucketId}/{objectId}/lock", middlewares(handler.GetObjectLock(s.services.BucketService), admit, authn, limit, read))
+	s.router.Handle("PUT /objects/{bucketId}/{objectId}/retention", middlewares(handler.PutObjectRetention(s.services.BucketService), admit, authn, limit, write, idempotent))
+	// releasing a legal hold is authorized by the handler
+	s.router.Handle("PUT /objects/{bucketId}/{objectId}/legal-hold", middlewares(handler.PutObjectLegalHold(s.services.BucketService), admit, authn, limit, write, idempotent))
+
+	s.router.Handle("GET /buckets/{bucketId}/trash", middlewares(handler.ListTrash(s.services.BucketService), admit, authn, limit, read))
+	s.router.Handle("POST /buckets/{bucketId}/trash/{objectId}/restore", middlewares(handler.RestoreObject(s.services.BucketService), admit, authn, limit, write, idempotent))
+
+	s.router.Handle("POST /buckets/{bucketId}/webhooks", middlewares(handler.CreateWebhook(s.services.WebhookService), admit, authn, limit, bucketAdmin, idempotent))
+	s.router.Handle("GET /buckets/{bucketId}/webhooks", middlewares(handler.ListWebhooks(s.services.WebhookService), admit, authn, limit, bucketAdmin))
+	s.router.Handle("DELETE /buckets/{bucketId}/webhooks/{webhookId}", middlewares(handler.DeleteWebhook(s.services.WebhookService), admit, authn, limit, bucketAdmin, idempotent))
+	s.router.Handle("GET /buckets/{bucketId}/webhooks/{webhookId}/deliveries", middlewares(handler.ListWebhookDeliveries(s.services.WebhookService), admit, authn, limit, bucketAdmin))
+
+	s.router.Handle("GET /buckets/{bucketId}/policy", middlewares(handler.GetBucketPolicy(s.services.PolicyService), admit, authn, limit, bucketAdmin))
+	s.router.Handle("PUT /buckets/{bucketId}/policy", middlewares(handler.PutBucketPolicy(s.services.PolicyService), admit, authn, limit, bucketAdmin, idempotent))
+	s.router.Handle("DELETE /buckets/{bucketId}/policy", middlewares(handler.DeleteBucketPolicy(s.services.PolicyService), admit, authn, limit, bucketAdmin, idempotent))
+	// the bucket of the simulated resource is authorized by the policy service
+	s.router.Handle("POST /policy/simulate", middlewares(handler.SimulatePolicy(s.services.PolicyService), admit, authn, limit))
+
+	// every operation of a batch is authorized by the batch service
+	s.router.Handle("POST /batch", middlewares(handler.Batch(s.services.BatchService), admit, authn, limit, idempotent))
+
+	// long polling and streaming requests are not subject to admission control
+	s.router.Handle("GET /changes", middlewares(handler.ListChanges(s.services.ChangeService), authn, limit, middleware.Authorize(auth.ActionRead, middleware.QueryBucket)))
+	// every subscription of a watch socket is authorized by the handler
+	s.router.Handle("GET /watch", middlewares(handler.WatchSocket(s.services.WatchService), authn, limit))
+	s.router.Handle("GET /watch/{bucketId}", middlewares(handler.WatchEvents(s.services.WatchService), authn, limit, read))
+
+	// probes are open, and never shed nor rate limited
+	s.router.Handle("GET /healthz", middlewares(handler.Healthz()))
+	s.router.Handle("GET /livez", middlewares(handler.Livez(s.services.HealthService)))
+	s.router.Handle("GET /readyz", middlewares(handler.Readyz(s.services.HealthService)))
+
+	// the administration endpoints would be open to anyone without authentication
+	if !configs.Global().Auth.Enabled {
+		return
+	}
+	s.router.Handle("POST /admin/keys", middlewares(handler.CreateAPIKey(s.services.APIKeyService), admit, authn, limit, admin, idempotent))
+	s.router.Handle("GET /admin/keys", middlewares(handler.ListAPIKeys(s.services.APIKeyService), admit, authn, limit, admin))
+	s.router.Handle("POST /admin/keys/{keyId}/rotate", middlewares(handler.RotateAPIKey(s.services.APIKeyService), admit, authn, limit, admin))
+	s.router.Handle("DELETE /admin/keys/{keyId}", middlewares(handler.RevokeAPIKey(s.services.APIKeyService), admit, authn, limit, admin))
+
+	s.router.Handle("GET /admin/roles", middlewares(handler.ListRoles(s.services.PolicyService), admit, authn, limit, admin))
+	s.router.Handle("PUT /admin/roles/{role}", middlewares(handler.PutRole(s.services.PolicyService), admit, authn, limit, admin, idempotent))
+	s.router.Handle("DELETE /admin/roles/{role}", middlewares(handler.DeleteRole(s.services.PolicyService), admit, authn, limit, admin))
+
+	s.router.Handle("GET /admin/rate-limits", middlewares(handler.GetRateLimits(s.services.RateLimitService), admit, authn, limit, admin))
+
+	s.router.Handle("GET /admin/log-level", middlewares(handler.GetLogLevel(s.services.LogLevelService), admit, authn, limit, admin))
+	s.router.Handle("PUT /admin/log-level", middlewares(handler.PutLogLevel(s.services.LogLevelService), admit, authn, limit, admin, idempotent))
+
+	// scrapes are never shed nor rate limited: the metrics matter most under load
+	s.router.Handle("GET /metrics", middlewares(handler.Metrics(metrics.Default), authn, admin))
+	s.router.Handle("GET /debug/vars", middlewares(expvar.Handler(), admit, authn, limit, admin))
+	// pprof is exposed only where the environment profile allows it
+	if !configs.Global().Environment.Settings().Pprof {
+		return
+	}
+	s.router.Handle("GET /debug/pprof/", middlewares(http.HandlerFunc(pprof.Index), admit, authn, limit, admin))
+	s.router.Handle("GET /debug/pprof/cmdline", middlewares(http.HandlerFunc(pprof.Cmdline), admit, authn, limit, admin))
+	// profiles and traces last as long as requested, like long polls
+	s.router.Handle("GET /debug/pprof/profile", middlewares(http.HandlerFunc(pprof.Profile), authn, limit, admin))
+	s.router.Handle("GET /debug/pprof/symbol", middlewares(http.HandlerFunc(pprof.Symbol), admit, authn, limit, admin))
+	s.router.Handle("GET /debug/pprof/trace", middlewares(http.HandlerFunc(pprof.Trace), authn, limit, admin))
+	s.router.Handle("GET /debug/pprof/{cmd}", middlewares(http.HandlerFunc(pprof.Index), admit, authn, limit, admin))
 }
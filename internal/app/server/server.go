@@ -3,8 +3,10 @@ package server
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"bucket_organizer/internal/app/services"
@@ -30,10 +32,23 @@ func NewServer(services *services.Services, shutdownFunc func(ctx context.Contex
 	return srv, nil
 }
 
+// allowOrigin
+//
+// checks origin against the allowed origins, see configs.Config.CorsOrigins, on every request,
+// so that reloading the configuration takes effect at once.
+func allowOrigin(origin string) bool {
+	for _, allowed := range configs.Global().CorsOrigins() {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Server) Run(ctx context.Context) *http.Server {
 	config := configs.Global().Server
 	corsHandler := cors.New(cors.Options{
-		AllowedOrigins: []string{"*"},
+		AllowOriginFunc: allowOrigin,
 		AllowedMethods: []string{
 			http.MethodPost,
 			http.MethodGet,
@@ -55,10 +70,19 @@ func (s *Server) Run(ctx context.Context) *http.Server {
 		WriteTimeout:      time.Duration(config.Timeouts.Write) * time.Second,
 		IdleTimeout:       time.Duration(config.Timeouts.Idle) * time.Second,
 	}
+	// watch streams never go idle: end them as soon as shutdown starts
+	s.httpServer.RegisterOnShutdown(s.services.WatchService.Close)
 
+	// listening before answering, so that readiness only passes once connections are accepted
+	started := s.services.HealthService.Starting("server")
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		logger.Fatal(ctx, "HTTP server", err)
+	}
 	go func() {
 		logger.Info(ctx, "Start serving http requests", logger.NewLogValue("port", config.Port))
-		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		started()
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Fatal(ctx, "HTTP server", err)
 		}
 	}()
@@ -66,12 +90,26 @@ func (s *Server) Run(ctx context.Context) *http.Server {
 	return s.httpServer
 }
 
+// Drain
+//
+// fails readiness and waits for the configured shutdown delay, still serving requests.
+func (s *Server) Drain(ctx context.Context) {
+	s.services.HealthService.ShutDown(ctx)
+}
+
+// Shutdown
+//
+// drains in-flight requests first, so that the clients released by gracefulShutdown
+// are no longer in use.
 func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
 	if s.gracefulShutdown != nil {
 		if err := s.gracefulShutdown(ctx); err != nil {
 			logger.Error(ctx, "Shutdown server", err)
 			return err
 		}
 	}
-	return s.httpServer.Shutdown(ctx)
+	return nil
 }
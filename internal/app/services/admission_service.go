@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+
+	"bucket_organizer/internal/pkg/admission"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+)
+
+const (
+	defaultAdmissionInitialLimit = 100
+	defaultAdmissionMinLimit     = 10
+	defaultAdmissionMaxLimit     = 1000
+)
+
+// AdmissionService
+//
+// rejects the requests beyond the adaptive concurrency limit of the server, see admission.Controller.
+type AdmissionService struct {
+	controller *admission.Controller
+	enabled    bool
+}
+
+func NewAdmissionService(config configs.Admission) *AdmissionService {
+	minLimit := intOr(config.MinLimit, defaultAdmissionMinLimit)
+	maxLimit := max(minLimit, intOr(config.MaxLimit, defaultAdmissionMaxLimit))
+	initial := min(maxLimit, max(minLimit, intOr(config.InitialLimit, defaultAdmissionInitialLimit)))
+	return &AdmissionService{
+		controller: admission.NewController(initial, minLimit, maxLimit),
+		enabled:    config.Enabled,
+	}
+}
+
+// Admit
+//
+// admits a request of priority, failing with types.ErrOverloaded when it must be shed. The
+// returned function must be called once the request is served.
+func (s *AdmissionService) Admit(ctx context.Context, priority admission.Priority) (func(), error) {
+	if !s.enabled {
+		return func() {}, nil
+	}
+	done, ok := s.controller.Acquire(priority)
+	if !ok {
+		logger.Debug(ctx, "shedding request", logger.NewLogValue("priority", priority.String()))
+		return nil, types.ErrOverloaded
+	}
+	return done, nil
+}
+
+func (s *AdmissionService) Stats() admission.Stats {
+	return s.controller.Stats()
+}
+
+func intOr(value, fallback int) int {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,377 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"bucket_organizer/internal/app/repository/apikey"
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/dto/response"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	// APIKeyPrefix starts every issued key, telling API keys apart from other bearer tokens.
+	APIKeyPrefix          = "bko_"
+	apiKeyHintLength      = 4
+	apiKeyPrincipalPrefix = "apikey:"
+	bootstrapKeyId        = "bootstrap"
+	defaultSigV4Region    = "us-east-1"
+	defaultPresignExpiry  = time.Hour
+)
+
+// APIKeyService
+//
+// issues API keys and resolves them to principals. Secrets are only ever returned at
+// creation or rotation: the repository stores their SHA-256. The secret access key used
+// for SigV4 signatures is derived from the key id and hash with the signing secret, so it
+// is not stored either and changes when the key is rotated.
+type APIKeyService struct {
+	repo          apikey.Repository
+	adminKeyHash  string
+	signingSecret []byte
+	region        string
+	now           func() time.Time
+}
+
+func NewAPIKeyService(repo apikey.Repository, config configs.Auth) *APIKeyService {
+	s := &APIKeyService{
+		repo:          repo,
+		adminKeyHash:  strings.ToLower(config.AdminKeyHash),
+		signingSecret: []byte(config.SigningSecret.Value()),
+		region:        config.Region,
+		now:           time.Now,
+	}
+	if len(s.signingSecret) == 0 {
+		s.signingSecret = make([]byte, 32)
+		_, _ = rand.Read(s.signingSecret)
+	}
+	if s.region == "" {
+		s.region = defaultSigV4Region
+	}
+	return s
+}
+
+func (s *APIKeyService) Create(ctx context.Context, req request.APIKeyRequest) (*response.APIKeyResponse, error) {
+	params := make([]types.InvalidParam, 0)
+	// a key may get all of its permissions from roles
+	if len(req.Permissions) > 0 || len(req.Roles) == 0 {
+		params = append(params, auth.ValidatePermissions("permissions", req.Permissions)...)
+	}
+	for i, role := range req.Roles {
+		if strings.TrimSpace(role) == "" {
+			params = append(params, types.InvalidParam{Name: fmt.Sprintf("roles[%d]", i), Reason: "must not be empty"})
+		}
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		params = append(params, types.InvalidParam{Name: "name", Reason: "must not be empty"})
+	}
+	if req.Tenant == "" {
+		req.Tenant = tenant.FromContext(ctx)
+	}
+	params = append(params, tenant.Validate("tenant", req.Tenant)...)
+	for i, grant := range req.Tenants {
+		// grants may end with a wildcard
+		if grant != "*" {
+			params = append(params, tenant.Validate(fmt.Sprintf("tenants[%d]", i), strings.TrimSuffix(grant, "*"))...)
+		}
+	}
+	if len(params) > 0 {
+		return nil, types.NewValidationError(params...)
+	}
+	if err := authorizeTenants(ctx, req.Tenant, req.Tenants); err != nil {
+		return nil, err
+	}
+	secret := newAPIKeySecret()
+	key := apikey.Key{
+		CreatedAt:   time.Now().UTC(),
+		Id:          uuid.NewString(),
+		Name:        req.Name,
+		Hash:        HashAPIKey(secret),
+		Hint:        secret[len(secret)-apiKeyHintLength:],
+		Tenant:      req.Tenant,
+		Tenants:     req.Tenants,
+		Roles:       req.Roles,
+		Permissions: req.Permissions,
+	}
+	if err := s.repo.Create(ctx, key); err != nil {
+		logger.Error(ctx, "error creating api key", err)
+		return nil, err
+	}
+	created := newAPIKeyResponse(key)
+	created.Key, created.SecretAccessKey = secret, s.secretAccessKey(key)
+	return &created, nil
+}
+
+// List
+//
+// returns the keys of the tenant of ctx.
+func (s *APIKeyService) List(ctx context.Context) ([]response.APIKeyResponse, error) {
+	keys, err := s.repo.List(ctx)
+	if err != nil {
+		logger.Error(ctx, "error listing api keys", err)
+		return nil, err
+	}
+	resp := make([]response.APIKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		if keyTenant(k) == tenant.FromContext(ctx) {
+			resp = append(resp, newAPIKeyResponse(k))
+		}
+	}
+	return resp, nil
+}
+
+// Rotate
+//
+// replaces the secret of keyId; the previous secret stops working immediately.
+func (s *APIKeyService) Rotate(ctx context.Context, keyId string) (*response.APIKeyResponse, error) {
+	key, err := s.tenantKey(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+	if key.Revoked() {
+		return nil, types.NewValidationError(types.InvalidParam{Name: "keyId", Reason: "key is revoked"})
+	}
+	secret := newAPIKeySecret()
+	now := time.Now().UTC()
+	key.Hash, key.Hint, key.RotatedAt = HashAPIKey(secret), secret[len(secret)-apiKeyHintLength:], &now
+	if err := s.repo.Update(ctx, key); err != nil {
+		logger.Error(ctx, "error rotating api key", err)
+		return nil, err
+	}
+	rotated := newAPIKeyResponse(key)
+	rotated.Key, rotated.SecretAccessKey = secret, s.secretAccessKey(key)
+	return &rotated, nil
+}
+
+// Revoke
+//
+// disables keyId for good. The key stays listed, with its revocation time.
+func (s *APIKeyService) Revoke(ctx context.Context, keyId string) (*response.APIKeyResponse, error) {
+	key, err := s.tenantKey(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+	if !key.Revoked() {
+		now := time.Now().UTC()
+		key.RevokedAt = &now
+		if err := s.repo.Update(ctx, key); err != nil {
+			logger.Error(ctx, "error revoking api key", err)
+			return nil, err
+		}
+	}
+	revoked := newAPIKeyResponse(key)
+	return &revoked, nil
+}
+
+// Authenticate
+//
+// resolves secret to the principal of its key, failing with types.ErrUnauthenticated for
+// unknown and revoked keys. The bootstrap admin key is checked first.
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (*auth.Principal, error) {
+	hash := HashAPIKey(secret)
+	if s.adminKeyHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(s.adminKeyHash)) == 1 {
+		return newAPIKeyPrincipal(s.bootstrapKey()), nil
+	}
+	key, err := s.repo.FindByHash(ctx, hash)
+	if errors.Is(err, types.ErrNoAPIKeyFound) || (err == nil && key.Revoked()) {
+		return nil, types.ErrUnauthenticated
+	}
+	if err != nil {
+		logger.Error(ctx, "error looking up api key", err)
+		return nil, err
+	}
+	return newAPIKeyPrincipal(key), nil
+}
+
+// AuthenticateSigV4
+//
+// verifies the SigV4 signature sig of r, whose access key id is the id of an API key.
+func (s *APIKeyService) AuthenticateSigV4(ctx context.Context, r *http.Request, sig *auth.SigV4Request) (*auth.Principal, error) {
+	key, err := s.signingKey(ctx, sig.AccessKeyId)
+	if errors.Is(err, types.ErrNoAPIKeyFound) || (err == nil && key.Revoked()) {
+		return nil, fmt.Errorf("%w: unknown access key", types.ErrUnauthenticated)
+	}
+	if err != nil {
+		logger.Error(ctx, "error looking up api key", err)
+		return nil, err
+	}
+	if err := auth.VerifySigV4(r, sig, s.secretAccessKey(key), s.region, s.now()); err != nil {
+		return nil, fmt.Errorf("%w: %w", types.ErrUnauthenticated, err)
+	}
+	return newAPIKeyPrincipal(key), nil
+}
+
+// Presign
+//
+// signs u for method with the API key of the caller, which must be authenticated with one.
+// expires defaults to an hour and is capped by auth.SigV4MaxExpires.
+func (s *APIKeyService) Presign(ctx context.Context, method string, u *url.URL, expires time.Duration) (*response.PresignResponse, error) {
+	if expires == 0 {
+		expires = defaultPresignExpiry
+	}
+	if expires < time.Second || expires > auth.SigV4MaxExpires {
+		return nil, types.NewValidationError(types.InvalidParam{Name: "expiresIn", Reason: fmt.Sprintf("must be between 1 and %d seconds", int(auth.SigV4MaxExpires.Seconds()))})
+	}
+	principal, ok := auth.PrincipalFromContext(ctx)
+	keyId, isKey := "", false
+	if ok {
+		keyId, isKey = strings.CutPrefix(principal.Id, apiKeyPrincipalPrefix)
+	}
+	if !isKey {
+		return nil, fmt.Errorf("%w: presigning requires an API key", types.ErrForbidden)
+	}
+	key, err := s.signingKey(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC().Truncate(time.Second)
+	signed := auth.PresignSigV4(method, u, key.Id, s.secretAccessKey(key), s.region, now, expires)
+	return &response.PresignResponse{ExpiresAt: now.Add(expires), Method: method, Url: signed.String()}, nil
+}
+
+// signingKey
+//
+// the API key keyId, including the bootstrap one.
+func (s *APIKeyService) signingKey(ctx context.Context, keyId string) (apikey.Key, error) {
+	if keyId == bootstrapKeyId {
+		if s.adminKeyHash == "" {
+			return apikey.Key{}, types.ErrNoAPIKeyFound
+		}
+		return s.bootstrapKey(), nil
+	}
+	return s.repo.Get(ctx, keyId)
+}
+
+// tenantKey
+//
+// the key keyId, provided it belongs to the tenant of ctx: the keys of other tenants are
+// reported missing.
+func (s *APIKeyService) tenantKey(ctx context.Context, keyId string) (apikey.Key, error) {
+	key, err := s.repo.Get(ctx, keyId)
+	if err != nil {
+		return apikey.Key{}, err
+	}
+	if keyTenant(key) != tenant.FromContext(ctx) {
+		return apikey.Key{}, types.ErrNoAPIKeyFound
+	}
+	return key, nil
+}
+
+// bootstrapKey
+//
+// the operator key of AUTH_ADMIN_KEY_HASH, granted every tenant.
+func (s *APIKeyService) bootstrapKey() apikey.Key {
+	return apikey.Key{
+		Id:          bootstrapKeyId,
+		Name:        bootstrapKeyId,
+		Hash:        s.adminKeyHash,
+		Tenants:     []string{"*"},
+		Permissions: []auth.Permission{{Bucket: auth.AllBuckets, Actions: []auth.Action{auth.ActionAdmin}}},
+	}
+}
+
+// secretAccessKey
+//
+// the SigV4 secret of key, derived rather than stored.
+func (s *APIKeyService) secretAccessKey(key apikey.Key) string {
+	mac := hmac.New(sha256.New, s.signingSecret)
+	mac.Write([]byte(key.Id + "\n" + key.Hash))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Principal
+//
+// the principal authenticated by the API key keyId, revoked or not; the keys of other
+// tenants are reported missing.
+func (s *APIKeyService) Principal(ctx context.Context, keyId string) (*auth.Principal, error) {
+	key, err := s.tenantKey(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+	return newAPIKeyPrincipal(key), nil
+}
+
+func newAPIKeyPrincipal(key apikey.Key) *auth.Principal {
+	return &auth.Principal{
+		Id:          apiKeyPrincipalPrefix + key.Id,
+		Name:        key.Name,
+		Tenant:      keyTenant(key),
+		Tenants:     key.Tenants,
+		Roles:       key.Roles,
+		Permissions: key.Permissions,
+	}
+}
+
+// keyTenant
+//
+// the tenant of key; keys issued before tenants existed belong to tenant.Default.
+func keyTenant(key apikey.Key) string {
+	if key.Tenant == "" {
+		return tenant.Default
+	}
+	return key.Tenant
+}
+
+// authorizeTenants
+//
+// only a principal with access to a tenant may issue keys for it or grant it, and wildcard
+// grants require access to every tenant.
+func authorizeTenants(ctx context.Context, home string, grants []string) error {
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	for _, t := range append([]string{home}, grants...) {
+		if (strings.Contains(t, "*") && !slices.Contains(p.Tenants, "*")) || !p.CanAccessTenant(t) {
+			return fmt.Errorf("%w: tenant %s is not granted to %s", types.ErrForbidden, t, p.Id)
+		}
+	}
+	return nil
+}
+
+// HashAPIKey
+//
+// the hex SHA-256 of secret, as stored by the repository and expected in AUTH_ADMIN_KEY_HASH.
+func HashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAPIKeySecret() string {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+}
+
+func newAPIKeyResponse(key apikey.Key) response.APIKeyResponse {
+	return response.APIKeyResponse{
+		CreatedAt:   key.CreatedAt,
+		RotatedAt:   key.RotatedAt,
+		RevokedAt:   key.RevokedAt,
+		Id:          key.Id,
+		Name:        key.Name,
+		Hint:        key.Hint,
+		Tenant:      keyTenant(key),
+		Tenants:     key.Tenants,
+		Roles:       key.Roles,
+		Permissions: key.Permissions,
+	}
+}
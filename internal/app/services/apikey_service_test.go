@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/repository/apikey"
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	ctx := context.Background()
+	repo := apikey.NewInMemoryRepo()
+	ks := NewAPIKeyService(repo, configs.Auth{})
+	permissions := []auth.Permission{{Bucket: "logs", Actions: []auth.Action{auth.ActionRead}}}
+
+	var validationErr *types.ValidationError
+	_, err := ks.Create(ctx, request.APIKeyRequest{Permissions: permissions})
+	assert.ErrorAs(t, err, &validationErr)
+
+	created, err := ks.Create(ctx, request.APIKeyRequest{Name: "ci", Permissions: permissions})
+	require.NoError(t, err)
+	assert.Contains(t, created.Key, APIKeyPrefix)
+	stored, err := repo.Get(ctx, created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, HashAPIKey(created.Key), stored.Hash)
+	assert.NotContains(t, stored.Hash, created.Key)
+
+	principal, err := ks.Authenticate(ctx, created.Key)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", principal.Name)
+	assert.Equal(t, permissions, principal.Permissions)
+
+	keys, err := ks.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Empty(t, keys[0].Key)
+
+	rotated, err := ks.Rotate(ctx, created.Id)
+	require.NoError(t, err)
+	assert.NotEqual(t, created.Key, rotated.Key)
+	assert.NotNil(t, rotated.RotatedAt)
+	_, err = ks.Authenticate(ctx, created.Key)
+	assert.ErrorIs(t, err, types.ErrUnauthenticated)
+	_, err = ks.Authenticate(ctx, rotated.Key)
+	assert.NoError(t, err)
+
+	revoked, err := ks.Revoke(ctx, created.Id)
+	require.NoError(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+	_, err = ks.Authenticate(ctx, rotated.Key)
+	assert.ErrorIs(t, err, types.ErrUnauthenticated)
+	_, err = ks.Rotate(ctx, created.Id)
+	assert.ErrorAs(t, err, &validationErr)
+	_, err = ks.Revoke(ctx, "missing")
+	assert.ErrorIs(t, err, types.ErrNoAPIKeyFound)
+}
+
+func TestAPIKeyBootstrapAdmin(t *testing.T) {
+	ks := NewAPIKeyService(apikey.NewInMemoryRepo(), configs.Auth{AdminKeyHash: HashAPIKey("bko_bootstrap")})
+	principal, err := ks.Authenticate(context.Background(), "bko_bootstrap")
+	require.NoError(t, err)
+	assert.True(t, principal.Allows(auth.AllBuckets, auth.ActionAdmin))
+}
+
+func TestAPIKeyTenantScoping(t *testing.T) {
+	ks := NewAPIKeyService(apikey.NewInMemoryRepo(), configs.Auth{})
+	permissions := []auth.Permission{{Bucket: "logs", Actions: []auth.Action{auth.ActionRead}}}
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	created, err := ks.Create(acme, request.APIKeyRequest{Name: "ci", Permissions: permissions})
+	require.NoError(t, err)
+	assert.Equal(t, "acme", created.Tenant)
+	principal, err := ks.Authenticate(globex, created.Key)
+	require.NoError(t, err)
+	assert.False(t, principal.CanAccessTenant("globex"))
+
+	keys, err := ks.List(globex)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+	_, err = ks.Rotate(globex, created.Id)
+	assert.ErrorIs(t, err, types.ErrNoAPIKeyFound)
+	_, err = ks.Revoke(globex, created.Id)
+	assert.ErrorIs(t, err, types.ErrNoAPIKeyFound)
+
+	caller := auth.WithPrincipal(acme, principal)
+	_, err = ks.Create(caller, request.APIKeyRequest{Name: "escape", Tenant: "globex", Permissions: permissions})
+	assert.ErrorIs(t, err, types.ErrForbidden)
+	_, err = ks.Create(caller, request.APIKeyRequest{Name: "wide", Tenants: []string{"*"}, Permissions: permissions})
+	assert.ErrorIs(t, err, types.ErrForbidden)
+}
+
+func TestAPIKeySigV4(t *testing.T) {
+	repo := apikey.NewInMemoryRepo()
+	ks := NewAPIKeyService(repo, configs.Auth{SigningSecret: "signing secret"})
+	created, err := ks.Create(context.Background(), request.APIKeyRequest{
+		Name:        "ci",
+		Permissions: []auth.Permission{{Bucket: "logs", Actions: []auth.Action{auth.ActionRead}}},
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.SecretAccessKey)
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost/objects/logs/a", nil)
+	require.NoError(t, auth.SignSigV4(r, created.Id, created.SecretAccessKey, defaultSigV4Region, time.Now()))
+	sig, err := auth.ParseSigV4(r)
+	require.NoError(t, err)
+	principal, err := ks.AuthenticateSigV4(r.Context(), r, sig)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", principal.Name)
+
+	// rotating the key changes its secret access key
+	rotated, err := ks.Rotate(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.NotEqual(t, created.SecretAccessKey, rotated.SecretAccessKey)
+	_, err = ks.AuthenticateSigV4(r.Context(), r, sig)
+	assert.ErrorIs(t, err, types.ErrUnauthenticated)
+
+	// presigning signs with the key of the caller
+	ctx := auth.WithPrincipal(context.Background(), principal)
+	u, _ := url.Parse("http://localhost/objects/logs/a")
+	presigned, err := ks.Presign(ctx, http.MethodGet, u, time.Minute)
+	require.NoError(t, err)
+	get := httptest.NewRequest(http.MethodGet, presigned.Url, nil)
+	sig, err = auth.ParseSigV4(get)
+	require.NoError(t, err)
+	_, err = ks.AuthenticateSigV4(get.Context(), get, sig)
+	assert.NoError(t, err)
+
+	_, err = ks.Presign(ctx, http.MethodGet, u, 8*24*time.Hour)
+	var validationErr *types.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	jwtCtx := auth.WithPrincipal(context.Background(), &auth.Principal{Id: "jwt:alice"})
+	_, err = ks.Presign(jwtCtx, http.MethodGet, u, time.Minute)
+	assert.ErrorIs(t, err, types.ErrForbidden)
+
+	_, err = ks.Revoke(context.Background(), created.Id)
+	require.NoError(t, err)
+	_, err = ks.AuthenticateSigV4(get.Context(), get, sig)
+	assert.ErrorIs(t, err, types.ErrUnauthenticated)
+}
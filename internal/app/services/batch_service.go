@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/dto/response"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+)
+
+const (
+	defaultBatchConcurrency   = 8
+	defaultBatchMaxOperations = 1000
+)
+
+// BatchOperation
+//
+// a batch operation with its position in the request.
+type BatchOperation struct {
+	request.BatchOperation
+	Index int
+}
+
+// BatchResult
+//
+// the outcome of a single batch operation; Err is nil on success.
+type BatchResult struct {
+	Err    error
+	Object *response.ObjectResponse
+	Op     string
+	Index  int
+}
+
+type BatchService struct {
+	bucketService *BucketService
+	config        configs.Batch
+}
+
+func NewBatchService(bucketService *BucketService, config configs.Batch) *BatchService {
+	return &BatchService{
+		bucketService: bucketService,
+		config:        config,
+	}
+}
+
+func (s *BatchService) MaxOperations() int {
+	if s.config.MaxOperations <= 0 {
+		return defaultBatchMaxOperations
+	}
+	return s.config.MaxOperations
+}
+
+// Execute
+//
+// runs the operations received from ops with bounded concurrency, calling emit once per
+// operation as soon as it completes. emit is never called concurrently.
+func (s *BatchService) Execute(ctx context.Context, ops <-chan BatchOperation, emit func(BatchResult)) {
+	concurrency := s.config.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for op := range ops {
+				result := s.execute(ctx, s.bucketService, op)
+				mu.Lock()
+				emit(result)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// ExecuteAtomically
+//
+// runs the operations sequentially in a single transaction. When an operation fails it keeps
+// its own error and every other operation is reported as types.ErrBatchAborted; when the
+// commit itself fails every operation reports the commit error.
+func (s *BatchService) ExecuteAtomically(ctx context.Context, ops []BatchOperation) []BatchResult {
+	results := make([]BatchResult, len(ops))
+	err := s.bucketService.Atomically(ctx, func(bs *BucketService) error {
+		for i, op := range ops {
+			results[i] = s.execute(ctx, bs, op)
+			if results[i].Err != nil {
+				return results[i].Err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error(ctx, "atomic batch aborted", err)
+		failed := slices.ContainsFunc(results, func(result BatchResult) bool {
+			return result.Err != nil
+		})
+		for i := range results {
+			switch {
+			case !failed:
+				results[i] = BatchResult{Index: ops[i].Index, Op: ops[i].Op, Err: err}
+			case !errors.Is(results[i].Err, err):
+				results[i] = BatchResult{Index: ops[i].Index, Op: ops[i].Op, Err: types.ErrBatchAborted}
+			}
+		}
+	}
+	return results
+}
+
+func (s *BatchService) execute(ctx context.Context, bs *BucketService, op BatchOperation) BatchResult {
+	result := BatchResult{Index: op.Index, Op: op.Op}
+	if err := validateBatchOperation(op.BatchOperation); err != nil {
+		result.Err = err
+		return result
+	}
+	if err := authorizeBatchOperation(ctx, op.BatchOperation); err != nil {
+		result.Err = err
+		return result
+	}
+	switch op.Op {
+	case request.BatchOpPut:
+		result.Object, result.Err = bs.InsertObject(ctx, op.BucketId, op.ObjectId)
+	case request.BatchOpGet:
+		result.Object, result.Err = bs.GetObject(ctx, op.BucketId, op.ObjectId)
+	case request.BatchOpDelete:
+		result.Err = bs.RemoveObject(ctx, op.BucketId, op.ObjectId)
+	case request.BatchOpCopy:
+		result.Object, result.Err = bs.CopyObject(ctx, op.BucketId, op.ObjectId, op.DestinationBucketId, op.DestinationObjectId)
+	}
+	return result
+}
+
+// authorizeBatchOperation
+//
+// checks the permissions of op: batches only require authentication, so every operation
+// is authorized on its own buckets.
+func authorizeBatchOperation(ctx context.Context, op request.BatchOperation) error {
+	switch op.Op {
+	case request.BatchOpPut:
+		return auth.AuthorizeRequest(ctx, auth.Request{Action: auth.ActionWrite, BucketId: op.BucketId, ObjectId: op.ObjectId})
+	case request.BatchOpGet:
+		return auth.AuthorizeRequest(ctx, auth.Request{Action: auth.ActionRead, BucketId: op.BucketId, ObjectId: op.ObjectId})
+	case request.BatchOpDelete:
+		return auth.AuthorizeRequest(ctx, auth.Request{Action: auth.ActionDelete, BucketId: op.BucketId, ObjectId: op.ObjectId})
+	case request.BatchOpCopy:
+		if err := auth.AuthorizeRequest(ctx, auth.Request{Action: auth.ActionRead, BucketId: op.BucketId, ObjectId: op.ObjectId}); err != nil {
+			return err
+		}
+		return auth.AuthorizeRequest(ctx, auth.Request{Action: auth.ActionWrite, BucketId: op.DestinationBucketId, ObjectId: op.DestinationObjectId})
+	}
+	return nil
+}
+
+func validateBatchOperation(op request.BatchOperation) error {
+	params := make([]types.InvalidParam, 0)
+	switch op.Op {
+	case request.BatchOpPut, request.BatchOpGet, request.BatchOpDelete:
+	case request.BatchOpCopy:
+		if op.DestinationBucketId == "" {
+			params = append(params, types.InvalidParam{Name: "destinationBucketId", Reason: "required for copy"})
+		}
+		if op.DestinationObjectId == "" {
+			params = append(params, types.InvalidParam{Name: "destinationObjectId", Reason: "required for copy"})
+		}
+	default:
+		params = append(params, types.InvalidParam{Name: "op", Reason: "must be one of put, get, delete, copy"})
+	}
+	if op.BucketId == "" {
+		params = append(params, types.InvalidParam{Name: "bucketId", Reason: "required"})
+	}
+	if op.ObjectId == "" {
+		params = append(params, types.InvalidParam{Name: "objectId", Reason: "required"})
+	}
+	if len(params) > 0 {
+		return types.NewValidationError(params...)
+	}
+	return nil
+}
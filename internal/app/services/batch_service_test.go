@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBatchService(repo bucket.Repository) *BatchService {
+	return NewBatchService(NewBucketService(repo, configs.Trash{}), configs.Batch{Concurrency: 4})
+}
+
+func TestBatchServiceExecute(t *testing.T) {
+	ctx := context.Background()
+	repo := bucket.NewInMemoryRepo()
+	bs := newTestBatchService(repo)
+
+	ops := []request.BatchOperation{
+		{Op: request.BatchOpPut, BucketId: "a", ObjectId: "1"},
+		{Op: request.BatchOpPut, BucketId: "a", ObjectId: "2"},
+		{Op: request.BatchOpGet, BucketId: "missing", ObjectId: "1"},
+		{Op: "rename", BucketId: "a", ObjectId: "1"},
+	}
+	queue := make(chan BatchOperation, len(ops))
+	for i, op := range ops {
+		queue <- BatchOperation{Index: i, BatchOperation: op}
+	}
+	close(queue)
+
+	results := make([]BatchResult, len(ops))
+	bs.Execute(ctx, queue, func(result BatchResult) {
+		results[result.Index] = result
+	})
+
+	assert.NoError(t, results[0].Err)
+	assert.NoError(t, results[1].Err)
+	assert.ErrorIs(t, results[2].Err, types.ErrNoBucketFound)
+	var validationErr *types.ValidationError
+	assert.ErrorAs(t, results[3].Err, &validationErr)
+}
+
+func TestBatchServiceExecuteAtomicallyRollsBack(t *testing.T) {
+	ctx := context.Background()
+	repo := bucket.NewInMemoryRepo()
+	require.NoError(t, repo.InsertObject(ctx, "a", "1"))
+	bs := newTestBatchService(repo)
+
+	results := bs.ExecuteAtomically(ctx, []BatchOperation{
+		{Index: 0, BatchOperation: request.BatchOperation{Op: request.BatchOpDelete, BucketId: "a", ObjectId: "1"}},
+		{Index: 1, BatchOperation: request.BatchOperation{Op: request.BatchOpCopy, BucketId: "a", ObjectId: "1", DestinationBucketId: "b", DestinationObjectId: "1"}},
+	})
+	assert.ErrorIs(t, results[0].Err, types.ErrBatchAborted)
+	assert.ErrorIs(t, results[1].Err, types.ErrNoObjectFound)
+
+	_, err := repo.GetObject(ctx, "a", "1")
+	assert.NoError(t, err)
+	_, err = repo.GetObject(ctx, "b", "1")
+	assert.ErrorIs(t, err, types.ErrNoBucketFound)
+}
+
+func TestBatchServiceExecuteAuthorizesEachOperation(t *testing.T) {
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Permissions: []auth.Permission{
+		{Bucket: "a", Actions: []auth.Action{auth.ActionRead, auth.ActionWrite}},
+	}})
+	bs := newTestBatchService(bucket.NewInMemoryRepo())
+
+	ops := []request.BatchOperation{
+		{Op: request.BatchOpPut, BucketId: "a", ObjectId: "1"},
+		{Op: request.BatchOpDelete, BucketId: "a", ObjectId: "1"},
+		{Op: request.BatchOpCopy, BucketId: "a", ObjectId: "1", DestinationBucketId: "b", DestinationObjectId: "1"},
+	}
+	queue := make(chan BatchOperation, len(ops))
+	for i, op := range ops {
+		queue <- BatchOperation{Index: i, BatchOperation: op}
+	}
+	close(queue)
+
+	results := make([]BatchResult, len(ops))
+	bs.Execute(ctx, queue, func(result BatchResult) {
+		results[result.Index] = result
+	})
+
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, types.ErrForbidden)
+	assert.ErrorIs(t, results[2].Err, types.ErrForbidden)
+}
@@ -2,26 +2,61 @@ package services
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
+	"time"
 
 	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/server/dto/request"
 	"bucket_organizer/internal/app/server/dto/response"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/tracing"
+	"bucket_organizer/internal/pkg/types"
 	"bucket_organizer/pkg/logger"
 )
 
+const (
+	defaultTrashRetention     = 7 * 24 * time.Hour
+	defaultTrashPurgeInterval = time.Minute
+)
+
+// maxTransactionAttempts
+//
+// how many times Atomically runs a transaction that fails with a conflict.
+const maxTransactionAttempts = 3
+
 type BucketService struct {
 	bucketRepo bucket.Repository
+	// objects is bucketRepo, or the enclosing transaction inside Atomically.
+	objects bucket.Objects
+	// trash is shared with the services of the transactions, see Configure.
+	trash *atomic.Pointer[configs.Trash]
+	inTx  bool
 }
 
-func NewBucketService(bucketRepo bucket.Repository) *BucketService {
-	return &BucketService{
+func NewBucketService(bucketRepo bucket.Repository, trash configs.Trash) *BucketService {
+	s := &BucketService{
 		bucketRepo: bucketRepo,
+		objects:    bucketRepo,
+		trash:      &atomic.Pointer[configs.Trash]{},
 	}
+	s.Configure(trash)
+	return s
+}
+
+// Configure
+//
+// applies a reloaded trash configuration; the purge interval only changes on restart.
+func (s *BucketService) Configure(trash configs.Trash) {
+	s.trash.Store(&trash)
 }
 
 func (s *BucketService) InsertObject(ctx context.Context, bucketId, objectId string) (*response.ObjectResponse, error) {
-	if err := s.bucketRepo.InsertObject(ctx, bucketId, objectId); err != nil {
+	ctx, span := startSpan(ctx, "BucketService.InsertObject", bucketId, objectId)
+	defer span.End()
+	if err := s.objects.InsertObject(ctx, bucketId, objectId); err != nil {
 		logger.Error(ctx, "error inserting object", err)
-		return nil, err
+		return nil, span.Fail(err)
 	}
 	return &response.ObjectResponse{
 		Id: objectId,
@@ -29,20 +64,285 @@ func (s *BucketService) InsertObject(ctx context.Context, bucketId, objectId str
 }
 
 func (s *BucketService) GetObject(ctx context.Context, bucketId, objectId string) (*response.ObjectResponse, error) {
-	o, err := s.bucketRepo.GetObject(ctx, bucketId, objectId)
+	ctx, span := startSpan(ctx, "BucketService.GetObject", bucketId, objectId)
+	defer span.End()
+	o, err := s.objects.GetObject(ctx, bucketId, objectId)
 	if err != nil {
 		logger.Error(ctx, "error getting object", err)
-		return nil, err
+		return nil, span.Fail(err)
 	}
 	return &response.ObjectResponse{
 		Id: o,
 	}, nil
 }
 
+// CopyObject
+//
+// copies the object to the destination; object lock state is not copied.
+func (s *BucketService) CopyObject(ctx context.Context, bucketId, objectId, destinationBucketId, destinationObjectId string) (*response.ObjectResponse, error) {
+	ctx, span := startSpan(ctx, "BucketService.CopyObject", bucketId, objectId)
+	defer span.End()
+	if _, err := s.GetObject(ctx, bucketId, objectId); err != nil {
+		return nil, span.Fail(err)
+	}
+	object, err := s.InsertObject(ctx, destinationBucketId, destinationObjectId)
+	return object, span.Fail(err)
+}
+
+// MoveObject
+//
+// atomically moves the object to another bucket, keeping its id.
+func (s *BucketService) MoveObject(ctx context.Context, bucketId, objectId, destinationBucketId string) (*response.ObjectResponse, error) {
+	ctx, span := startSpan(ctx, "BucketService.MoveObject", bucketId, objectId)
+	defer span.End()
+	var object *response.ObjectResponse
+	err := s.Atomically(ctx, func(bs *BucketService) error {
+		if err := bs.objects.RemoveObject(ctx, bucketId, objectId); err != nil {
+			return err
+		}
+		var err error
+		object, err = bs.InsertObject(ctx, destinationBucketId, objectId)
+		return err
+	})
+	if err != nil {
+		logger.Error(ctx, "error moving object", err)
+		return nil, span.Fail(err)
+	}
+	return object, nil
+}
+
+// SwapObjects
+//
+// atomically exchanges two objects between their buckets: afterwards bucketId holds
+// otherObjectId and otherBucketId holds objectId.
+func (s *BucketService) SwapObjects(ctx context.Context, bucketId, objectId, otherBucketId, otherObjectId string) error {
+	ctx, span := startSpan(ctx, "BucketService.SwapObjects", bucketId, objectId)
+	defer span.End()
+	err := s.Atomically(ctx, func(bs *BucketService) error {
+		for _, o := range [][2]string{{bucketId, objectId}, {otherBucketId, otherObjectId}} {
+			if err := bs.objects.RemoveObject(ctx, o[0], o[1]); err != nil {
+				return err
+			}
+		}
+		for _, o := range [][2]string{{bucketId, otherObjectId}, {otherBucketId, objectId}} {
+			if err := bs.objects.InsertObject(ctx, o[0], o[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error(ctx, "error swapping objects", err)
+		return span.Fail(err)
+	}
+	return nil
+}
+
+// Atomically
+//
+// runs fn in a repository transaction, committing only if fn succeeds and retrying on
+// types.ErrTransactionConflict. Calls nested inside fn join the enclosing transaction.
+func (s *BucketService) Atomically(ctx context.Context, fn func(bs *BucketService) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+	var err error
+	for range maxTransactionAttempts {
+		err = s.runTransaction(ctx, fn)
+		if !errors.Is(err, types.ErrTransactionConflict) {
+			return err
+		}
+		logger.Debug(ctx, "transaction conflict, retrying")
+	}
+	return err
+}
+
+func (s *BucketService) runTransaction(ctx context.Context, fn func(bs *BucketService) error) error {
+	tx, err := s.bucketRepo.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	bs := &BucketService{
+		bucketRepo: s.bucketRepo,
+		objects:    tx,
+		trash:      s.trash,
+		inTx:       true,
+	}
+	if err := fn(bs); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// WithGovernanceBypass
+//
+// allows the mutations performed with ctx to bypass governance mode retention.
+func WithGovernanceBypass(ctx context.Context) context.Context {
+	return bucket.WithGovernanceBypass(ctx)
+}
+
+// RemoveObject
+//
+// moves the object to the bucket trash when trash mode is enabled, otherwise deletes it.
 func (s *BucketService) RemoveObject(ctx context.Context, bucketId, objectId string) error {
-	if err := s.bucketRepo.RemoveObject(ctx, bucketId, objectId); err != nil {
+	ctx, span := startSpan(ctx, "BucketService.RemoveObject", bucketId, objectId)
+	defer span.End()
+	if s.trash.Load().Enabled {
+		if err := s.objects.TrashObject(ctx, bucketId, objectId, time.Now()); err != nil {
+			logger.Error(ctx, "error trashing object", err)
+			return span.Fail(err)
+		}
+		return nil
+	}
+	if err := s.objects.RemoveObject(ctx, bucketId, objectId); err != nil {
 		logger.Error(ctx, "error removing object", err)
-		return err
+		return span.Fail(err)
 	}
 	return nil
 }
+
+func (s *BucketService) ListTrash(ctx context.Context, bucketId string) ([]response.TrashedObjectResponse, error) {
+	ctx, span := startSpan(ctx, "BucketService.ListTrash", bucketId, "")
+	defer span.End()
+	objects, err := s.objects.ListTrash(ctx, bucketId)
+	if err != nil {
+		logger.Error(ctx, "error listing trash", err)
+		return nil, span.Fail(err)
+	}
+	retention := s.trashRetention()
+	trashed := make([]response.TrashedObjectResponse, 0, len(objects))
+	for _, o := range objects {
+		trashed = append(trashed, response.TrashedObjectResponse{
+			Id:        o.Id,
+			DeletedAt: o.DeletedAt,
+			ExpiresAt: o.DeletedAt.Add(retention),
+		})
+	}
+	return trashed, nil
+}
+
+func (s *BucketService) RestoreObject(ctx context.Context, bucketId, objectId string) (*response.ObjectResponse, error) {
+	ctx, span := startSpan(ctx, "BucketService.RestoreObject", bucketId, objectId)
+	defer span.End()
+	if err := s.objects.RestoreObject(ctx, bucketId, objectId); err != nil {
+		logger.Error(ctx, "error restoring object", err)
+		return nil, span.Fail(err)
+	}
+	return &response.ObjectResponse{
+		Id: objectId,
+	}, nil
+}
+
+// PurgeExpiredTrash
+//
+// permanently deletes trashed objects older than the configured retention.
+func (s *BucketService) PurgeExpiredTrash(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.PurgeExpiredTrash")
+	defer span.End()
+	purged, err := s.objects.PurgeTrash(ctx, time.Now().Add(-s.trashRetention()))
+	if err != nil {
+		logger.Error(ctx, "error purging trash", err)
+		return 0, span.Fail(err)
+	}
+	span.SetAttributes(tracing.Int("purged", purged))
+	if purged > 0 {
+		logger.Info(ctx, "purged expired trash", logger.NewLogValue("objects", purged))
+	}
+	return purged, nil
+}
+
+// RunTrashPurger
+//
+// periodically purges expired trash until ctx is done.
+func (s *BucketService) RunTrashPurger(ctx context.Context) {
+	interval := time.Duration(s.trash.Load().PurgeInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultTrashPurgeInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, _ = s.PurgeExpiredTrash(ctx)
+		}
+	}
+}
+
+func (s *BucketService) trashRetention() time.Duration {
+	retention := s.trash.Load().Retention
+	if retention <= 0 {
+		return defaultTrashRetention
+	}
+	return time.Duration(retention) * time.Second
+}
+
+func (s *BucketService) GetObjectLock(ctx context.Context, bucketId, objectId string) (*response.ObjectLockResponse, error) {
+	ctx, span := startSpan(ctx, "BucketService.GetObjectLock", bucketId, objectId)
+	defer span.End()
+	lock, err := s.objects.GetObjectLock(ctx, bucketId, objectId)
+	if err != nil {
+		logger.Error(ctx, "error getting object lock", err)
+		return nil, span.Fail(err)
+	}
+	return newObjectLockResponse(objectId, lock), nil
+}
+
+func (s *BucketService) PutObjectRetention(ctx context.Context, bucketId, objectId string, req request.RetentionRequest) (*response.ObjectLockResponse, error) {
+	ctx, span := startSpan(ctx, "BucketService.PutObjectRetention", bucketId, objectId)
+	defer span.End()
+	retention := bucket.Retention{
+		Mode:        bucket.RetentionMode(req.Mode),
+		RetainUntil: req.RetainUntil,
+	}
+	if err := retention.Validate(); err != nil {
+		return nil, span.Fail(err)
+	}
+	if err := s.objects.PutObjectRetention(ctx, bucketId, objectId, retention); err != nil {
+		logger.Error(ctx, "error setting object retention", err)
+		return nil, span.Fail(err)
+	}
+	lock, err := s.GetObjectLock(ctx, bucketId, objectId)
+	return lock, span.Fail(err)
+}
+
+func (s *BucketService) PutObjectLegalHold(ctx context.Context, bucketId, objectId string, legalHold bool) (*response.ObjectLockResponse, error) {
+	ctx, span := startSpan(ctx, "BucketService.PutObjectLegalHold", bucketId, objectId)
+	defer span.End()
+	if err := s.objects.PutObjectLegalHold(ctx, bucketId, objectId, legalHold); err != nil {
+		logger.Error(ctx, "error setting object legal hold", err)
+		return nil, span.Fail(err)
+	}
+	lock, err := s.GetObjectLock(ctx, bucketId, objectId)
+	return lock, span.Fail(err)
+}
+
+// startSpan
+//
+// starts the span of a BucketService method acting on the object, or on the bucket when
+// objectId is empty.
+func startSpan(ctx context.Context, name, bucketId, objectId string) (context.Context, *tracing.Span) {
+	attributes := []tracing.Attribute{tracing.String("bucket.id", bucketId)}
+	if objectId != "" {
+		attributes = append(attributes, tracing.String("object.id", objectId))
+	}
+	return tracing.Start(ctx, name, attributes...)
+}
+
+func newObjectLockResponse(objectId string, lock bucket.Lock) *response.ObjectLockResponse {
+	resp := &response.ObjectLockResponse{
+		Id:        objectId,
+		LegalHold: lock.LegalHold,
+	}
+	if lock.Retention.Mode != "" {
+		retainUntil := lock.Retention.RetainUntil
+		resp.Retention = response.RetentionResponse{
+			Mode:        string(lock.Retention.Mode),
+			RetainUntil: &retainUntil,
+		}
+	}
+	return resp
+}
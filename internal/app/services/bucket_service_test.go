@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketServiceMoveObject(t *testing.T) {
+	ctx := context.Background()
+	repo := bucket.NewInMemoryRepo()
+	bs := NewBucketService(repo, configs.Trash{})
+	require.NoError(t, repo.InsertObject(ctx, "source", "object"))
+
+	object, err := bs.MoveObject(ctx, "source", "object", "destination")
+	require.NoError(t, err)
+	assert.Equal(t, "object", object.Id)
+
+	_, err = repo.GetObject(ctx, "source", "object")
+	assert.ErrorIs(t, err, types.ErrNoObjectFound)
+	_, err = repo.GetObject(ctx, "destination", "object")
+	assert.NoError(t, err)
+}
+
+func TestBucketServiceSwapObjectsIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	repo := bucket.NewInMemoryRepo()
+	bs := NewBucketService(repo, configs.Trash{})
+	require.NoError(t, repo.InsertObject(ctx, "a", "1"))
+	require.NoError(t, repo.InsertObject(ctx, "b", "2"))
+	require.NoError(t, repo.PutObjectLegalHold(ctx, "b", "2", true))
+
+	assert.ErrorIs(t, bs.SwapObjects(ctx, "a", "1", "b", "2"), types.ErrObjectLocked)
+	_, err := repo.GetObject(ctx, "a", "1")
+	assert.NoError(t, err, "the first removal must be rolled back")
+
+	require.NoError(t, repo.PutObjectLegalHold(ctx, "b", "2", false))
+	require.NoError(t, bs.SwapObjects(ctx, "a", "1", "b", "2"))
+	_, err = repo.GetObject(ctx, "a", "2")
+	assert.NoError(t, err)
+	_, err = repo.GetObject(ctx, "b", "1")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/app/server/dto/response"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/pkg/logger"
+)
+
+const (
+	defaultChangesLimit   = 100
+	maxChangesLimit       = 1000
+	defaultChangesMaxWait = 30 * time.Second
+)
+
+type ChangeService struct {
+	repo      changes.Repository
+	notify    chan struct{}
+	listeners []func(events []changes.Event)
+	config    configs.Changes
+	mu        sync.Mutex
+}
+
+func NewChangeService(repo changes.Repository, config configs.Changes) *ChangeService {
+	return &ChangeService{
+		repo:   repo,
+		config: config,
+		notify: make(chan struct{}),
+	}
+}
+
+// Record
+//
+// appends the committed mutations to the change log. It is installed as the bucket
+// repository bucket.CommitHook, so a failure aborts the commit.
+func (s *ChangeService) Record(ctx context.Context, mutations []bucket.Mutation) error {
+	now := time.Now().UTC()
+	events := make([]changes.Event, 0, len(mutations))
+	for _, m := range mutations {
+		events = append(events, changes.Event{
+			Time:     now,
+			Type:     string(m.Type),
+			TenantId: m.TenantId,
+			BucketId: m.BucketId,
+			ObjectId: m.ObjectId,
+		})
+	}
+	appended, err := s.repo.Append(ctx, events...)
+	if err != nil {
+		logger.Error(ctx, "error recording changes", err)
+		return err
+	}
+	s.purge(ctx, appended)
+	s.mu.Lock()
+	close(s.notify)
+	s.notify = make(chan struct{})
+	listeners := s.listeners
+	s.mu.Unlock()
+	for _, listener := range listeners {
+		listener(appended)
+	}
+	return nil
+}
+
+// purge
+//
+// drops the events beyond the configured maximum; the changes are committed already, so
+// a failure is only logged.
+func (s *ChangeService) purge(ctx context.Context, appended []changes.Event) {
+	maxEvents := uint64(max(s.config.MaxEvents, 0))
+	if maxEvents == 0 || len(appended) == 0 {
+		return
+	}
+	last := appended[len(appended)-1].Sequence
+	if last <= maxEvents {
+		return
+	}
+	if _, err := s.repo.Purge(ctx, last-maxEvents); err != nil {
+		logger.Error(ctx, "error purging changes", err)
+	}
+}
+
+// AddListener
+//
+// registers fn to receive every batch of recorded events, in sequence order.
+// fn is called while the commit is in progress and must not block.
+func (s *ChangeService) AddListener(fn func(events []changes.Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// List
+//
+// returns the events after since, of every tenant when tenantId is empty. When there are none
+// and wait is positive it blocks until new events are recorded, wait elapses (capped to the
+// configured maximum) or ctx is done. LastSequence is that of the last event scanned, even
+// when filtered out, so that polling a quiet bucket does not rescan the same events.
+func (s *ChangeService) List(ctx context.Context, since uint64, tenantId, bucketId string, limit int, wait time.Duration) (*response.ChangesResponse, error) {
+	if limit <= 0 {
+		limit = defaultChangesLimit
+	}
+	limit = min(limit, maxChangesLimit)
+	timeout := time.NewTimer(min(wait, s.MaxWait()))
+	defer timeout.Stop()
+	for {
+		s.mu.Lock()
+		notify := s.notify
+		s.mu.Unlock()
+
+		// read first: the events listed next include every event up to it
+		head, err := s.repo.LastSequence(ctx)
+		if err != nil {
+			logger.Error(ctx, "error listing changes", err)
+			return nil, err
+		}
+		events, err := s.repo.List(ctx, since, tenantId, bucketId, limit)
+		if err != nil {
+			logger.Error(ctx, "error listing changes", err)
+			return nil, err
+		}
+		scanned := since
+		if len(events) < limit {
+			scanned = max(since, head)
+		}
+		if len(events) > 0 || wait <= 0 {
+			return newChangesResponse(scanned, events), nil
+		}
+		select {
+		case <-notify:
+		case <-timeout.C:
+			return newChangesResponse(scanned, events), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// history
+//
+// returns a page of events after since without waiting.
+func (s *ChangeService) history(ctx context.Context, since uint64, tenantId, bucketId string, limit int) ([]changes.Event, error) {
+	return s.repo.List(ctx, since, tenantId, bucketId, limit)
+}
+
+func (s *ChangeService) MaxWait() time.Duration {
+	if s.config.MaxWait <= 0 {
+		return defaultChangesMaxWait
+	}
+	return time.Duration(s.config.MaxWait) * time.Second
+}
+
+// newChangesResponse
+//
+// scanned is the sequence up to which the events were listed.
+func newChangesResponse(scanned uint64, events []changes.Event) *response.ChangesResponse {
+	last := scanned
+	if len(events) > 0 {
+		last = max(last, events[len(events)-1].Sequence)
+	}
+	return &response.ChangesResponse{Events: events, LastSequence: last}
+}
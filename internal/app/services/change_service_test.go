@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/pkg/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeServiceLongPoll(t *testing.T) {
+	ctx := context.Background()
+	cs := NewChangeService(changes.NewInMemoryRepo(), configs.Changes{})
+	repo := bucket.NewInMemoryRepo(bucket.WithCommitHook(cs.Record))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = repo.InsertObject(ctx, "other", "1")
+		_ = repo.InsertObject(ctx, "bucket", "1")
+	}()
+
+	resp, err := cs.List(ctx, 0, "", "bucket", 10, 5*time.Second)
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.Events)
+	assert.Equal(t, "bucket", resp.Events[0].BucketId)
+	assert.Equal(t, resp.Events[len(resp.Events)-1].Sequence, resp.LastSequence)
+
+	start := time.Now()
+	resp, err = cs.List(ctx, resp.LastSequence, "", "bucket", 10, 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Events)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestChangeServiceScannedSequence(t *testing.T) {
+	ctx := context.Background()
+	cs := NewChangeService(changes.NewInMemoryRepo(), configs.Changes{})
+	repo := bucket.NewInMemoryRepo(bucket.WithCommitHook(cs.Record))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "1"))
+	require.NoError(t, repo.InsertObject(ctx, "other", "1"))
+	require.NoError(t, repo.InsertObject(ctx, "other", "2"))
+
+	// creating a bucket records an event too: nothing matches after the object of bucket,
+	// yet the events up to the last one were scanned
+	resp, err := cs.List(ctx, 2, "", "bucket", 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Events)
+	assert.Equal(t, uint64(5), resp.LastSequence)
+
+	// a full page stops at its last event
+	resp, err = cs.List(ctx, 0, "", "", 2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), resp.LastSequence)
+}
+
+func TestChangeServicePurgesBeyondMaxEvents(t *testing.T) {
+	ctx := context.Background()
+	cs := NewChangeService(changes.NewInMemoryRepo(), configs.Changes{MaxEvents: 2})
+	repo := bucket.NewInMemoryRepo(bucket.WithCommitHook(cs.Record))
+	for _, objectId := range []string{"1", "2", "3", "4"} {
+		require.NoError(t, repo.InsertObject(ctx, "bucket", objectId))
+	}
+
+	resp, err := cs.List(ctx, 0, "", "", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, resp.Events, 2)
+	assert.Equal(t, uint64(4), resp.Events[0].Sequence)
+	assert.Equal(t, uint64(5), resp.LastSequence)
+}
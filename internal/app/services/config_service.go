@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/pkg/logger"
+)
+
+// ConfigService
+//
+// reloads the configuration on SIGHUP and when the configuration file changes. The
+// components subscribed with configs.Subscribe apply the live settings; changes to the
+// others are logged as waiting for a restart.
+type ConfigService struct {
+	interval time.Duration
+	reload   func() (configs.Update, error)
+	file     func() string
+}
+
+// NewConfigService
+//
+// checks the configuration file every interval, never when interval is 0.
+func NewConfigService(interval time.Duration) *ConfigService {
+	return &ConfigService{interval: interval, reload: configs.Reload, file: configs.File}
+}
+
+// Reload
+//
+// reloads the configuration now; an invalid one is logged and leaves the current one in place.
+func (s *ConfigService) Reload(ctx context.Context) {
+	update, err := s.reload()
+	if err != nil {
+		logger.Error(ctx, "configuration not reloaded", err)
+		return
+	}
+	if len(update.Applied) > 0 {
+		logger.Info(ctx, "configuration reloaded", logger.NewLogValue("applied", update.Applied))
+	}
+	if len(update.Restart) > 0 {
+		logger.Info(ctx, "configuration changes require a restart", logger.NewLogValue("restart", update.Restart))
+	}
+	if len(update.Applied) == 0 && len(update.Restart) == 0 {
+		logger.Debug(ctx, "configuration unchanged")
+	}
+}
+
+// RunWatcher
+//
+// reloads the configuration on SIGHUP and when the configuration file is modified until ctx
+// is done.
+func (s *ConfigService) RunWatcher(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	modified := s.modified()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			logger.Info(ctx, "SIGHUP received, reloading configuration")
+			modified = s.modified()
+			s.Reload(ctx)
+		case <-tick:
+			if m := s.modified(); !m.Equal(modified) {
+				modified = m
+				s.Reload(ctx)
+			}
+		}
+	}
+}
+
+// modified
+//
+// the modification time of the configuration file, zero when there is none.
+func (s *ConfigService) modified() time.Time {
+	file := s.file()
+	if file == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
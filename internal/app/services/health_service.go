@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/health"
+	"bucket_organizer/pkg/logger"
+)
+
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// HealthService
+//
+// answers the liveness and readiness probes with the checks registered by the components.
+// Readiness also fails until every startup task is done, e.g. the publish relay catching up
+// with the change log, and from the moment shutdown begins.
+type HealthService struct {
+	registry      *health.Registry
+	shutdownDelay time.Duration
+	mu            sync.Mutex
+	starting      map[string]int
+	shuttingDown  bool
+}
+
+func NewHealthService(config configs.Health) *HealthService {
+	timeout := time.Duration(config.CheckTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	s := &HealthService{
+		registry:      health.NewRegistry(timeout),
+		shutdownDelay: time.Duration(config.ShutdownDelay) * time.Second,
+		starting:      make(map[string]int),
+	}
+	s.registry.Register(health.Readiness, "startup", s.checkStartup)
+	s.registry.Register(health.Readiness, "shutdown", s.checkShutdown)
+	return s
+}
+
+func (s *HealthService) Register(probe health.Probe, name string, check health.Check) {
+	s.registry.Register(probe, name, check)
+}
+
+// Starting
+//
+// holds readiness until the returned function is called, which may be called more than once.
+func (s *HealthService) Starting(task string) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.starting[task]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.starting[task]--; s.starting[task] == 0 {
+				delete(s.starting, task)
+			}
+		})
+	}
+}
+
+// Worker
+//
+// wraps a background worker, registering a liveness check that fails if it returns before
+// shutdown begins.
+func (s *HealthService) Worker(name string, run func(ctx context.Context)) func(ctx context.Context) {
+	var mu sync.Mutex
+	stopped := false
+	s.registry.Register(health.Liveness, "worker:"+name, func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped && !s.isShuttingDown() {
+			return errors.New("worker stopped")
+		}
+		return nil
+	})
+	return func(ctx context.Context) {
+		defer func() {
+			mu.Lock()
+			stopped = true
+			mu.Unlock()
+		}()
+		run(ctx)
+	}
+}
+
+// Live
+//
+// the liveness report; failing reports ask for a restart.
+func (s *HealthService) Live(ctx context.Context) health.Report {
+	return s.registry.Run(ctx, health.Liveness)
+}
+
+// Ready
+//
+// the readiness report; failing reports ask to route the traffic elsewhere.
+func (s *HealthService) Ready(ctx context.Context) health.Report {
+	return s.registry.Run(ctx, health.Readiness)
+}
+
+// ShutDown
+//
+// fails readiness, then keeps serving for the configured delay, so that the load balancers
+// stop routing requests to the server before it stops accepting them.
+func (s *HealthService) ShutDown(ctx context.Context) {
+	s.mu.Lock()
+	s.shuttingDown = true
+	s.mu.Unlock()
+	if s.shutdownDelay <= 0 {
+		return
+	}
+	logger.Info(ctx, "not ready, draining traffic", logger.NewLogValue("delay", s.shutdownDelay.String()))
+	select {
+	case <-ctx.Done():
+	case <-time.After(s.shutdownDelay):
+	}
+}
+
+func (s *HealthService) isShuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shuttingDown
+}
+
+func (s *HealthService) checkStartup(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.starting) == 0 {
+		return nil
+	}
+	tasks := make([]string, 0, len(s.starting))
+	for task := range s.starting {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+	return fmt.Errorf("starting: %s", strings.Join(tasks, ", "))
+}
+
+func (s *HealthService) checkShutdown(ctx context.Context) error {
+	if s.isShuttingDown() {
+		return errors.New("shutting down")
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/publisher"
+	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthServiceReadiness(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	hs := NewHealthService(configs.Health{})
+
+	// the relay holds readiness until it has published the changes recorded before it started
+	cs := NewChangeService(changes.NewInMemoryRepo(), configs.Changes{})
+	bs := NewBucketService(bucket.NewInMemoryRepo(bucket.WithCommitHook(cs.Record)), configs.Trash{})
+	for _, id := range []string{"a", "b"} {
+		_, err := bs.InsertObject(ctx, "bucket", id)
+		require.NoError(t, err)
+	}
+	bus := publisher.NewChannelBus(0)
+	subscription := bus.Subscribe()
+	cursor := changes.NewInMemoryCursor()
+	require.NoError(t, cursor.Store(ctx, 0))
+	relay := NewPublishService(bus, cs, cursor)
+	relay.OnCaughtUp(hs.Starting("publish relay"))
+	done := hs.Starting("server")
+	go relay.RunRelay(ctx)
+
+	report := hs.Ready(ctx)
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, "starting: publish relay, server", report.Checks["startup"].Error)
+	assert.Equal(t, health.StatusPass, report.Checks["shutdown"].Status)
+	done()
+	done()
+	// the unbuffered bus blocks the relay until the events are received
+	for range 3 {
+		<-subscription.Events()
+	}
+	require.Eventually(t, func() bool { return hs.Ready(ctx).Status == health.StatusPass }, time.Second, 10*time.Millisecond)
+
+	hs.ShutDown(ctx)
+	report = hs.Ready(ctx)
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, "shutting down", report.Checks["shutdown"].Error)
+}
+
+func TestHealthServiceWorkers(t *testing.T) {
+	ctx := context.Background()
+	hs := NewHealthService(configs.Health{})
+	workerCtx, stop := context.WithCancel(ctx)
+	exited := make(chan struct{})
+	run := hs.Worker("purger", func(ctx context.Context) { <-ctx.Done() })
+	crash := hs.Worker("dispatcher", func(ctx context.Context) {})
+	go func() {
+		run(workerCtx)
+		close(exited)
+	}()
+	assert.Equal(t, health.StatusPass, hs.Live(ctx).Status)
+
+	crash(ctx)
+	report := hs.Live(ctx)
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, "worker stopped", report.Checks["worker:dispatcher"].Error)
+	assert.Equal(t, health.StatusPass, report.Checks["worker:purger"].Status)
+
+	// workers stopped by the shutdown are expected to
+	hs.ShutDown(ctx)
+	stop()
+	<-exited
+	assert.Equal(t, health.StatusPass, hs.Live(ctx).Status)
+}
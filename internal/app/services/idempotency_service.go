@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"bucket_organizer/internal/app/repository/idempotency"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+)
+
+const (
+	defaultIdempotencyTTL           = 24 * time.Hour
+	defaultIdempotencyMaxBodySize   = 1 << 20
+	defaultIdempotencyPurgeInterval = time.Minute
+)
+
+type IdempotencyService struct {
+	repo   idempotency.Repository
+	config configs.Idempotency
+}
+
+func NewIdempotencyService(repo idempotency.Repository, config configs.Idempotency) *IdempotencyService {
+	return &IdempotencyService{
+		repo:   repo,
+		config: config,
+	}
+}
+
+// Begin
+//
+// returns the stored response to replay for key, or nil when the caller owns the key and
+// must run the request then call Complete or Release.
+// A key reused with a different request fails with types.ErrIdempotencyKeyReused,
+// a key whose first request is still running with types.ErrIdempotencyKeyInProgress.
+func (s *IdempotencyService) Begin(ctx context.Context, key, requestHash string) (*idempotency.Record, error) {
+	record, err := s.repo.Reserve(ctx, key, requestHash, time.Now().Add(s.ttl()))
+	if err != nil {
+		logger.Error(ctx, "error reserving idempotency key", err)
+		return nil, err
+	}
+	switch {
+	case record == nil:
+		return nil, nil
+	case record.RequestHash != requestHash:
+		return nil, types.ErrIdempotencyKeyReused
+	case !record.Completed():
+		return nil, types.ErrIdempotencyKeyInProgress
+	}
+	logger.Debug(ctx, "replaying idempotent response", logger.NewLogValue("idempotencyKey", key))
+	return record, nil
+}
+
+// Complete
+//
+// stores the response produced for key. Server errors release the key instead, so that the
+// client can retry.
+func (s *IdempotencyService) Complete(ctx context.Context, key, requestHash string, status int, header http.Header, body []byte) error {
+	if status >= http.StatusInternalServerError {
+		return s.repo.Release(ctx, key)
+	}
+	err := s.repo.Complete(ctx, idempotency.Record{
+		Key:         key,
+		RequestHash: requestHash,
+		Status:      status,
+		Header:      header,
+		Body:        body,
+		ExpiresAt:   time.Now().Add(s.ttl()),
+	})
+	if err != nil {
+		logger.Error(ctx, "error storing idempotent response", err)
+	}
+	return err
+}
+
+func (s *IdempotencyService) Release(ctx context.Context, key string) error {
+	return s.repo.Release(ctx, key)
+}
+
+// RunPurger
+//
+// periodically drops expired keys until ctx is done.
+func (s *IdempotencyService) RunPurger(ctx context.Context) {
+	ticker := time.NewTicker(defaultIdempotencyPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := s.repo.PurgeExpired(ctx, now); err != nil {
+				logger.Error(ctx, "error purging idempotency keys", err)
+			}
+		}
+	}
+}
+
+// MaxBodySize
+//
+// the size in bytes of the largest request body fingerprinted.
+func (s *IdempotencyService) MaxBodySize() int64 {
+	if s.config.MaxBodySize <= 0 {
+		return defaultIdempotencyMaxBodySize
+	}
+	return s.config.MaxBodySize
+}
+
+func (s *IdempotencyService) ttl() time.Duration {
+	if s.config.TTL <= 0 {
+		return defaultIdempotencyTTL
+	}
+	return time.Duration(s.config.TTL) * time.Second
+}
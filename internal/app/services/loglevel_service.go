@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/dto/response"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+)
+
+// maxLogLevelRevert caps how long a temporary log level lasts, so that a forgotten debug level
+// does not flood the logs for days.
+const maxLogLevelRevert = 24 * time.Hour
+
+// LogLevelService
+//
+// changes the log level at runtime, globally or per component, optionally for a while only.
+type LogLevelService struct {
+	mu      sync.Mutex
+	reverts map[string]*logLevelRevert
+	// configured is the LOG_LEVEL last applied: reloads leaving it unchanged keep the levels
+	// set at runtime
+	configured string
+	// unit is the unit of LogLevelRequest.RevertAfter
+	unit time.Duration
+}
+
+// logLevelRevert
+//
+// a pending revert of the level of a component, "" for the global level, to level; an empty
+// level drops the level of the component.
+type logLevelRevert struct {
+	timer *time.Timer
+	level string
+	at    time.Time
+}
+
+// NewLogLevelService
+//
+// level is the LOG_LEVEL the logger started with.
+func NewLogLevelService(level string) *LogLevelService {
+	configured, _ := logger.ParseLevel(level)
+	return &LogLevelService{reverts: make(map[string]*logLevelRevert), configured: configured, unit: time.Second}
+}
+
+func (s *LogLevelService) Levels() response.LogLevelResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	levels := response.LogLevelResponse{
+		Level:      logger.GetLevel(),
+		Components: make(map[string]response.ComponentLogLevel),
+	}
+	if pending, ok := s.reverts[""]; ok {
+		levels.RevertAt = &pending.at
+	}
+	for component, level := range logger.ComponentLevels() {
+		componentLevel := response.ComponentLogLevel{Level: level}
+		if pending, ok := s.reverts[component]; ok {
+			componentLevel.RevertAt = &pending.at
+		}
+		levels.Components[component] = componentLevel
+	}
+	return levels
+}
+
+// SetLevel
+//
+// applies the level of the request. A temporary level reverts to the level in effect before
+// the first of the temporary levels in a row, a permanent one cancels the pending revert.
+func (s *LogLevelService) SetLevel(ctx context.Context, req request.LogLevelRequest) (response.LogLevelResponse, error) {
+	params := make([]types.InvalidParam, 0)
+	level := req.Level
+	if level != "" {
+		var err error
+		if level, err = logger.ParseLevel(level); err != nil {
+			params = append(params, types.InvalidParam{Name: "level", Reason: err.Error()})
+		}
+	} else if req.Component == "" {
+		params = append(params, types.InvalidParam{Name: "level", Reason: "must not be empty without a component"})
+	}
+	revertAfter := time.Duration(req.RevertAfter) * s.unit
+	if revertAfter < 0 || revertAfter > maxLogLevelRevert {
+		params = append(params, types.InvalidParam{Name: "revertAfter", Reason: "must be between 0 and 86400 seconds"})
+	}
+	if len(params) > 0 {
+		return response.LogLevelResponse{}, types.NewValidationError(params...)
+	}
+
+	s.mu.Lock()
+	previous := logger.GetLevel()
+	if req.Component != "" {
+		previous = logger.ComponentLevels()[req.Component]
+	}
+	if pending, ok := s.reverts[req.Component]; ok {
+		pending.timer.Stop()
+		previous = pending.level
+		delete(s.reverts, req.Component)
+	}
+	setLogLevel(req.Component, level)
+	if revertAfter > 0 {
+		pending := &logLevelRevert{level: previous, at: time.Now().Add(revertAfter)}
+		ctx := context.WithoutCancel(ctx)
+		pending.timer = time.AfterFunc(revertAfter, func() { s.revert(ctx, req.Component, pending) })
+		s.reverts[req.Component] = pending
+	}
+	s.mu.Unlock()
+
+	logger.Info(ctx, "log level set", logger.NewLogValue("component", req.Component), logger.NewLogValue("level", level), logger.NewLogValue("revertAfter", revertAfter.String()))
+	return s.Levels(), nil
+}
+
+// Configure
+//
+// applies a reloaded LOG_LEVEL when it changed, a level set at runtime prevailing otherwise;
+// during a temporary global level, it becomes the level reverted to.
+func (s *LogLevelService) Configure(ctx context.Context, level string) error {
+	level, err := logger.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if level == s.configured {
+		return nil
+	}
+	s.configured = level
+	if pending, ok := s.reverts[""]; ok {
+		pending.level = level
+		return nil
+	}
+	setLogLevel("", level)
+	return nil
+}
+
+func (s *LogLevelService) revert(ctx context.Context, component string, pending *logLevelRevert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// superseded by a later level
+	if s.reverts[component] != pending {
+		return
+	}
+	delete(s.reverts, component)
+	setLogLevel(component, pending.level)
+	logger.Info(ctx, "log level reverted", logger.NewLogValue("component", component), logger.NewLogValue("level", pending.level))
+}
+
+// setLogLevel
+//
+// level has been parsed already; an empty one drops the level of component.
+func setLogLevel(component, level string) {
+	switch {
+	case component == "":
+		_ = logger.SetLevel(level)
+	case level == "":
+		logger.ResetComponentLevel(component)
+	default:
+		_ = logger.SetComponentLevel(component, level)
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogLevelService(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, logger.SetLevel("info"))
+	t.Cleanup(func() {
+		_ = logger.SetLevel("info")
+		logger.ResetComponentLevel("services")
+	})
+	ls := NewLogLevelService("info")
+	ls.unit = time.Millisecond
+
+	levels, err := ls.SetLevel(ctx, request.LogLevelRequest{Component: "services", Level: "DEBUG"})
+	require.NoError(t, err)
+	assert.Equal(t, "info", levels.Level)
+	assert.Equal(t, "debug", levels.Components["services"].Level)
+	assert.Nil(t, levels.Components["services"].RevertAt)
+
+	// temporary levels in a row revert to the level before the first one
+	_, err = ls.SetLevel(ctx, request.LogLevelRequest{Level: "warn", RevertAfter: 60_000})
+	require.NoError(t, err)
+	levels, err = ls.SetLevel(ctx, request.LogLevelRequest{Level: "error", RevertAfter: 50})
+	require.NoError(t, err)
+	assert.Equal(t, "error", levels.Level)
+	require.NotNil(t, levels.RevertAt)
+	// a reloaded LOG_LEVEL becomes the level reverted to
+	require.NoError(t, ls.Configure(ctx, "DEBUG"))
+	assert.Equal(t, "error", logger.GetLevel())
+	assert.Eventually(t, func() bool { return logger.GetLevel() == "debug" }, time.Second, 5*time.Millisecond)
+	assert.Nil(t, ls.Levels().RevertAt)
+
+	// a component without a level of its own drops it on revert
+	_, err = ls.SetLevel(ctx, request.LogLevelRequest{Component: "middleware", Level: "error", RevertAfter: 50})
+	require.NoError(t, err)
+	assert.Contains(t, ls.Levels().Components, "middleware")
+	assert.Eventually(t, func() bool { return len(ls.Levels().Components) == 1 }, time.Second, 5*time.Millisecond)
+
+	// a permanent level cancels the pending revert
+	_, err = ls.SetLevel(ctx, request.LogLevelRequest{Component: "services", Level: "error", RevertAfter: 50})
+	require.NoError(t, err)
+	_, err = ls.SetLevel(ctx, request.LogLevelRequest{Component: "services", Level: ""})
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, ls.Levels().Components)
+
+	var validationErr *types.ValidationError
+	for _, req := range []request.LogLevelRequest{
+		{Level: ""},
+		{Level: "verbose"},
+		{Level: "info", RevertAfter: -1},
+		{Level: "info", RevertAfter: int(maxLogLevelRevert/time.Millisecond) + 1},
+	} {
+		_, err = ls.SetLevel(ctx, req)
+		assert.ErrorAs(t, err, &validationErr, req)
+	}
+	assert.Error(t, ls.Configure(ctx, "verbose"))
+}
+
+func TestLogLevelServiceKeepsRuntimeLevel(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, logger.SetLevel("info"))
+	t.Cleanup(func() { _ = logger.SetLevel("info") })
+	ls := NewLogLevelService("info")
+
+	_, err := ls.SetLevel(ctx, request.LogLevelRequest{Level: "debug"})
+	require.NoError(t, err)
+	// a reload changing another field leaves LOG_LEVEL as it was
+	require.NoError(t, ls.Configure(ctx, "info"))
+	assert.Equal(t, "debug", logger.GetLevel())
+
+	require.NoError(t, ls.Configure(ctx, "warn"))
+	assert.Equal(t, "warn", logger.GetLevel())
+}
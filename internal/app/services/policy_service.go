@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"bucket_organizer/internal/app/repository/policy"
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/dto/response"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+)
+
+// PolicyService
+//
+// manages bucket policies and roles, and evaluates authorization requests against them
+// as the auth.Evaluator of authenticated requests.
+type PolicyService struct {
+	repo policy.Repository
+	keys *APIKeyService
+}
+
+func NewPolicyService(repo policy.Repository, keys *APIKeyService) *PolicyService {
+	return &PolicyService{repo: repo, keys: keys}
+}
+
+func (s *PolicyService) GetPolicy(ctx context.Context, bucketId string) (*auth.Policy, error) {
+	p, err := s.repo.GetPolicy(ctx, bucketId)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *PolicyService) PutPolicy(ctx context.Context, bucketId string, p auth.Policy) (*auth.Policy, error) {
+	if params := auth.ValidatePolicy(p); len(params) > 0 {
+		return nil, types.NewValidationError(params...)
+	}
+	if err := s.repo.PutPolicy(ctx, bucketId, p); err != nil {
+		logger.Error(ctx, "error storing bucket policy", err)
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *PolicyService) DeletePolicy(ctx context.Context, bucketId string) error {
+	return s.repo.DeletePolicy(ctx, bucketId)
+}
+
+func (s *PolicyService) ListRoles(ctx context.Context) ([]policy.Role, error) {
+	return s.repo.ListRoles(ctx)
+}
+
+func (s *PolicyService) PutRole(ctx context.Context, name string, req request.RoleRequest) (*policy.Role, error) {
+	if params := auth.ValidatePermissions("permissions", req.Permissions); len(params) > 0 {
+		return nil, types.NewValidationError(params...)
+	}
+	role := policy.Role{Name: name, Permissions: req.Permissions}
+	if err := s.repo.PutRole(ctx, role); err != nil {
+		logger.Error(ctx, "error storing role", err)
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (s *PolicyService) DeleteRole(ctx context.Context, name string) error {
+	return s.repo.DeleteRole(ctx, name)
+}
+
+// Evaluate
+//
+// decides req with auth.Evaluate. Requests are denied when the roles or the policy cannot
+// be read.
+func (s *PolicyService) Evaluate(ctx context.Context, req auth.Request) auth.Decision {
+	roles := make(map[string][]auth.Permission, len(req.Principal.Roles))
+	for _, name := range req.Principal.Roles {
+		role, err := s.repo.GetRole(ctx, name)
+		if errors.Is(err, types.ErrNoRoleFound) {
+			continue
+		}
+		if err != nil {
+			logger.Error(ctx, "error reading role", err)
+			return auth.Decision{Effect: auth.EffectDeny, Reasons: []string{"role " + name + " is unavailable"}}
+		}
+		roles[name] = role.Permissions
+	}
+	var bucketPolicy *auth.Policy
+	if req.BucketId != auth.AllBuckets {
+		p, err := s.repo.GetPolicy(ctx, req.BucketId)
+		switch {
+		case err == nil:
+			bucketPolicy = &p
+		case !errors.Is(err, types.ErrNoPolicyFound):
+			logger.Error(ctx, "error reading bucket policy", err)
+			return auth.Decision{Effect: auth.EffectDeny, Reasons: []string{"the policy of bucket " + req.BucketId + " is unavailable"}}
+		}
+	}
+	return auth.Evaluate(req, roles, bucketPolicy)
+}
+
+// Simulate
+//
+// explains the decision on a hypothetical request; the caller must administer its bucket.
+// Without a principal the caller is simulated, and an API key principal given by id alone
+// is resolved to the key, provided it belongs to the tenant of the caller.
+func (s *PolicyService) Simulate(ctx context.Context, req request.SimulateRequest) (*response.SimulateResponse, error) {
+	bucketId, objectId, _ := strings.Cut(req.Resource, "/")
+	params := make([]types.InvalidParam, 0)
+	if bucketId == "" {
+		params = append(params, types.InvalidParam{Name: "resource", Reason: "must be <bucket> or <bucket>/<object>"})
+	}
+	if !slices.Contains(auth.Actions, req.Action) {
+		params = append(params, types.InvalidParam{Name: "action", Reason: "unknown action"})
+	}
+	var sourceIp netip.Addr
+	if req.SourceIp != "" {
+		var err error
+		if sourceIp, err = netip.ParseAddr(req.SourceIp); err != nil {
+			params = append(params, types.InvalidParam{Name: "sourceIp", Reason: "must be an IP address"})
+		}
+	}
+	switch {
+	case req.Principal == nil:
+		if _, ok := auth.PrincipalFromContext(ctx); !ok {
+			params = append(params, types.InvalidParam{Name: "principal", Reason: "required when not authenticated"})
+		}
+	case req.Principal.Id == "":
+		params = append(params, types.InvalidParam{Name: "principal.id", Reason: "must not be empty"})
+	}
+	if len(params) > 0 {
+		return nil, types.NewValidationError(params...)
+	}
+	// before resolving the principal, whose key must not be disclosed to any caller
+	if err := auth.Authorize(ctx, bucketId, auth.ActionAdmin); err != nil {
+		return nil, err
+	}
+	principal, err := s.simulatedPrincipal(ctx, req.Principal)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := s.Evaluate(ctx, auth.Request{
+		Principal: principal,
+		SourceIp:  sourceIp,
+		Action:    req.Action,
+		BucketId:  bucketId,
+		ObjectId:  objectId,
+		Prefix:    req.Prefix,
+	})
+	echoed := *principal
+	// the other tenants granted to a key are none of the business of its tenant
+	echoed.Tenants = nil
+	return &response.SimulateResponse{
+		Allowed:   decision.Allowed(),
+		Effect:    decision.Effect,
+		Reasons:   decision.Reasons,
+		Principal: echoed,
+	}, nil
+}
+
+// simulatedPrincipal
+//
+// p has been validated already.
+func (s *PolicyService) simulatedPrincipal(ctx context.Context, p *auth.Principal) (*auth.Principal, error) {
+	if p == nil {
+		caller, _ := auth.PrincipalFromContext(ctx)
+		return caller, nil
+	}
+	if keyId, ok := strings.CutPrefix(p.Id, apiKeyPrincipalPrefix); ok && len(p.Roles) == 0 && len(p.Permissions) == 0 {
+		return s.keys.Principal(ctx, keyId)
+	}
+	return p, nil
+}
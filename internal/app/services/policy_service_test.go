@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"bucket_organizer/internal/app/repository/apikey"
+	"bucket_organizer/internal/app/repository/policy"
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyServiceSimulate(t *testing.T) {
+	ctx := context.Background()
+	ks := NewAPIKeyService(apikey.NewInMemoryRepo(), configs.Auth{})
+	ps := NewPolicyService(policy.NewInMemoryRepo(), ks)
+
+	var validationErr *types.ValidationError
+	_, err := ps.PutRole(ctx, "readers", request.RoleRequest{})
+	assert.ErrorAs(t, err, &validationErr)
+	_, err = ps.PutRole(ctx, "readers", request.RoleRequest{Permissions: []auth.Permission{{Bucket: "logs", Actions: []auth.Action{auth.ActionRead}}}})
+	require.NoError(t, err)
+	_, err = ps.PutPolicy(ctx, "logs", auth.Policy{Statements: []auth.Statement{{Effect: "maybe"}}})
+	assert.ErrorAs(t, err, &validationErr)
+	_, err = ps.PutPolicy(ctx, "logs", auth.Policy{Statements: []auth.Statement{{
+		Sid: "no-private", Effect: auth.EffectDeny, Principals: []string{"role:readers"}, Actions: []auth.Action{auth.ActionRead}, Resources: []string{"logs/private/*"},
+	}}})
+	require.NoError(t, err)
+
+	key, err := ks.Create(ctx, request.APIKeyRequest{Name: "ci", Roles: []string{"readers"}})
+	require.NoError(t, err)
+
+	// the key is resolved from its principal id
+	allowed, err := ps.Simulate(ctx, request.SimulateRequest{
+		Principal: &auth.Principal{Id: "apikey:" + key.Id},
+		Action:    auth.ActionRead,
+		Resource:  "logs/public/a",
+	})
+	require.NoError(t, err)
+	assert.True(t, allowed.Allowed)
+	assert.Equal(t, []string{"readers"}, allowed.Principal.Roles)
+	assert.Contains(t, allowed.Reasons, "allowed by role readers")
+
+	denied, err := ps.Simulate(ctx, request.SimulateRequest{
+		Principal: &auth.Principal{Id: "apikey:" + key.Id},
+		Action:    auth.ActionRead,
+		Resource:  "logs/private/a",
+	})
+	require.NoError(t, err)
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, auth.EffectDeny, denied.Effect)
+	assert.Contains(t, denied.Reasons[0], `statement "no-private"`)
+
+	_, err = ps.Simulate(ctx, request.SimulateRequest{Action: "fly", Resource: ""})
+	assert.ErrorAs(t, err, &validationErr)
+
+	// simulating requires the admin action on the bucket of the resource
+	callerCtx := auth.WithPrincipal(ctx, &auth.Principal{Id: "apikey:reader", Roles: []string{"readers"}})
+	_, err = ps.Simulate(callerCtx, request.SimulateRequest{Action: auth.ActionRead, Resource: "logs/a"})
+	assert.ErrorIs(t, err, types.ErrForbidden)
+	// before the key is looked up, so that its existence is not disclosed
+	_, err = ps.Simulate(callerCtx, request.SimulateRequest{Principal: &auth.Principal{Id: "apikey:unknown"}, Action: auth.ActionRead, Resource: "logs/a"})
+	assert.ErrorIs(t, err, types.ErrForbidden)
+
+	// the keys of other tenants are reported missing, and the other tenants of a key hidden
+	partner, err := ks.Create(ctx, request.APIKeyRequest{Name: "partner", Tenant: "globex", Roles: []string{"readers"}})
+	require.NoError(t, err)
+	_, err = ps.Simulate(ctx, request.SimulateRequest{Principal: &auth.Principal{Id: "apikey:" + partner.Id}, Action: auth.ActionRead, Resource: "logs/a"})
+	assert.ErrorIs(t, err, types.ErrNoAPIKeyFound)
+	shared, err := ks.Create(ctx, request.APIKeyRequest{Name: "shared", Tenants: []string{"globex"}, Roles: []string{"readers"}})
+	require.NoError(t, err)
+	resolved, err := ps.Simulate(ctx, request.SimulateRequest{Principal: &auth.Principal{Id: "apikey:" + shared.Id}, Action: auth.ActionRead, Resource: "logs/a"})
+	require.NoError(t, err)
+	assert.Empty(t, resolved.Principal.Tenants)
+}
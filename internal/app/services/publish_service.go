@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"bucket_organizer/internal/app/publisher"
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/pkg/logger"
+)
+
+const (
+	publishRetryInterval    = time.Second
+	maxPublishRetryInterval = 30 * time.Second
+)
+
+// PublishService
+//
+// relays the change log to a publisher. Mutations only reach the change log when their
+// transaction commits (see bucket.CommitHook), which makes it a transactional outbox: events
+// of mutations that did not commit are never published. The relay position is kept in
+// cursor and only advanced after the publisher accepted the events.
+type PublishService struct {
+	publisher publisher.Publisher
+	changes   *ChangeService
+	cursor    changes.Cursor
+	caughtUp  func()
+}
+
+func NewPublishService(p publisher.Publisher, cs *ChangeService, cursor changes.Cursor) *PublishService {
+	return &PublishService{
+		publisher: p,
+		changes:   cs,
+		cursor:    cursor,
+		caughtUp:  func() {},
+	}
+}
+
+// OnCaughtUp
+//
+// sets the function called once the relay has published the changes recorded before it
+// started, e.g. to hold readiness during the replay.
+func (s *PublishService) OnCaughtUp(fn func()) {
+	s.caughtUp = fn
+}
+
+// RunRelay
+//
+// publishes recorded changes until ctx is done, retrying failures with backoff. Without a
+// stored cursor it starts from the end of the change log.
+func (s *PublishService) RunRelay(ctx context.Context) {
+	retry := publishRetryInterval
+	wait := func(err error, msg string) {
+		logger.Error(ctx, msg, err, logger.NewLogValue("retryIn", retry.String()))
+		select {
+		case <-ctx.Done():
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, maxPublishRetryInterval)
+	}
+
+	position, err := s.start(ctx)
+	for err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		wait(err, "error loading publish cursor")
+		position, err = s.start(ctx)
+	}
+	head, err := s.changes.repo.LastSequence(ctx)
+	if err != nil {
+		// replaying is not observable then: do not hold readiness on it
+		logger.Error(ctx, "error reading change log head", err)
+	}
+	behind := true
+	for ctx.Err() == nil {
+		if behind && position >= head {
+			behind = false
+			s.caughtUp()
+		}
+		feed, err := s.changes.List(ctx, position, "", "", maxChangesLimit, s.changes.MaxWait())
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			wait(err, "error reading change log")
+			continue
+		}
+		if len(feed.Events) == 0 {
+			continue
+		}
+		if err := s.publisher.Publish(ctx, feed.Events...); err != nil {
+			if ctx.Err() == nil {
+				wait(err, "error publishing changes")
+			}
+			continue
+		}
+		if err := s.cursor.Store(ctx, feed.LastSequence); err != nil {
+			// the events were published: go on and store the position with the next batch
+			logger.Error(ctx, "error storing publish cursor", err)
+		}
+		position = feed.LastSequence
+		retry = publishRetryInterval
+	}
+}
+
+func (s *PublishService) start(ctx context.Context) (uint64, error) {
+	position, ok, err := s.cursor.Load(ctx)
+	if err != nil || ok {
+		return position, err
+	}
+	return s.changes.repo.LastSequence(ctx)
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/publisher"
+	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/pkg/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishServiceRelaysCommittedMutationsOnly(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cs := NewChangeService(changes.NewInMemoryRepo(), configs.Changes{})
+	bs := NewBucketService(bucket.NewInMemoryRepo(bucket.WithCommitHook(cs.Record)), configs.Trash{})
+	bus := publisher.NewChannelBus(16)
+	subscription := bus.Subscribe()
+	cursor := changes.NewInMemoryCursor()
+	// without a stored position the relay would start after the changes recorded so far
+	require.NoError(t, cursor.Store(ctx, 0))
+	go NewPublishService(bus, cs, cursor).RunRelay(ctx)
+
+	_, err := bs.InsertObject(ctx, "bucket", "committed")
+	require.NoError(t, err)
+	err = bs.Atomically(ctx, func(tx *BucketService) error {
+		if _, err := tx.InsertObject(ctx, "bucket", "rolled-back"); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	require.Error(t, err)
+	_, err = bs.InsertObject(ctx, "bucket", "after")
+	require.NoError(t, err)
+
+	published := make([]string, 0)
+	for len(published) < 3 {
+		select {
+		case e := <-subscription.Events():
+			published = append(published, e.Type+" "+e.ObjectId)
+		case <-ctx.Done():
+			t.Fatalf("published only %v", published)
+		}
+	}
+	assert.Equal(t, []string{"bucket.created ", "object.created committed", "object.created after"}, published)
+
+	require.Eventually(t, func() bool {
+		position, ok, _ := cursor.Load(ctx)
+		return ok && position == 3
+	}, time.Second, 10*time.Millisecond)
+}
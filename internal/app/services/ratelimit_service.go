@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/ratelimit"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+)
+
+const (
+	defaultRateLimitKey            = RateLimitByClient
+	defaultRateLimitReloadInterval = 10 * time.Second
+	rateLimitIdle                  = 10 * time.Minute
+	// authFailuresBucket names the bucket of the failed authentications of a client
+	authFailuresBucket = "auth-failures"
+)
+
+// the clients limits are counted by, see configs.RateLimit.
+const (
+	RateLimitByClient = "client"
+	RateLimitByTenant = "tenant"
+	RateLimitByIp     = "ip"
+)
+
+// RateLimitService
+//
+// applies the limits of configs.RateLimit, overridden by the rules file, which is reloaded
+// when it changes without resetting the buckets of the clients, as is the configuration.
+type RateLimitService struct {
+	limiter  *ratelimit.Limiter
+	config   configs.RateLimit
+	mu       sync.RWMutex
+	limits   ratelimit.Limits
+	modified time.Time
+}
+
+func NewRateLimitService(config configs.RateLimit) (*RateLimitService, error) {
+	s := &RateLimitService{limiter: ratelimit.NewLimiter()}
+	if err := s.Configure(context.Background(), config); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Configure
+//
+// applies a reloaded configuration, reading the rules file again; an invalid one leaves the
+// current limits in place. The reload interval only changes on restart.
+func (s *RateLimitService) Configure(ctx context.Context, config configs.RateLimit) error {
+	if config.Key == "" {
+		config.Key = defaultRateLimitKey
+	}
+	if config.Key != RateLimitByClient && config.Key != RateLimitByTenant && config.Key != RateLimitByIp {
+		return fmt.Errorf("unknown RATELIMIT_KEY %q", config.Key)
+	}
+	limits, modified, err := loadLimits(config)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.config, s.limits, s.modified = config, limits, modified
+	s.mu.Unlock()
+	if !modified.IsZero() {
+		logger.Info(ctx, "rate limits loaded", logger.NewLogValue("routes", len(limits.Routes)))
+	}
+	return nil
+}
+
+// Key
+//
+// what clients are identified by: RateLimitByClient, RateLimitByTenant or RateLimitByIp.
+func (s *RateLimitService) Key() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config.Key
+}
+
+func (s *RateLimitService) Limits() ratelimit.Limits {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.limits
+}
+
+// Admit
+//
+// admits a request of client to route, failing with types.ErrRateLimited when it exceeds the
+// rate or the concurrency limit. The returned function must be called once the request is
+// served; the decision is returned for the rate limit headers either way.
+func (s *RateLimitService) Admit(ctx context.Context, client, route, method string) (ratelimit.Decision, func(), error) {
+	name, limit := s.Limits().For(route, method)
+	key := client + " " + name
+	release, ok := s.limiter.Acquire(key, limit)
+	if !ok {
+		logger.Debug(ctx, "too many requests in flight", logger.NewLogValue("client", client))
+		return ratelimit.Decision{Allowed: false, RetryAfter: time.Second}, nil, fmt.Errorf("%w: more than %d requests in flight", types.ErrRateLimited, limit.Concurrency)
+	}
+	decision := s.limiter.Take(key, limit)
+	if !decision.Allowed {
+		release()
+		logger.Debug(ctx, "request rate exceeded", logger.NewLogValue("client", client))
+		return decision, nil, fmt.Errorf("%w: more than %g %s requests per second", types.ErrRateLimited, limit.Rate, name)
+	}
+	return decision, release, nil
+}
+
+// AdmitAuthentication
+//
+// fails with types.ErrRateLimited when client, a client address, has failed to authenticate
+// beyond the limit; see FailAuthentication.
+func (s *RateLimitService) AdmitAuthentication(ctx context.Context, client string) (ratelimit.Decision, error) {
+	limit := s.Limits().AuthFailures
+	decision := s.limiter.Peek(client+" "+authFailuresBucket, limit)
+	if !decision.Allowed {
+		logger.Debug(ctx, "authentication failure rate exceeded", logger.NewLogValue("client", client))
+		return decision, fmt.Errorf("%w: more than %g failed authentications per second", types.ErrRateLimited, limit.Rate)
+	}
+	return decision, nil
+}
+
+// FailAuthentication
+//
+// charges client with a failed authentication.
+func (s *RateLimitService) FailAuthentication(client string) {
+	s.limiter.Take(client+" "+authFailuresBucket, s.Limits().AuthFailures)
+}
+
+// Reload
+//
+// reads the limits again from the configuration and the rules file, reporting whether the
+// file changed since the last load. Invalid files leave the current limits in place.
+func (s *RateLimitService) Reload(ctx context.Context) (bool, error) {
+	s.mu.RLock()
+	config, loaded := s.config, s.modified
+	s.mu.RUnlock()
+	if config.File == "" {
+		return false, nil
+	}
+	info, err := os.Stat(config.File)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if loaded.IsZero() {
+			return false, nil
+		}
+	case err != nil:
+		return false, fmt.Errorf("read rate limits: %w", err)
+	case info.ModTime().Equal(loaded):
+		return false, nil
+	}
+	limits, modified, err := loadLimits(config)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// a concurrent Configure has read the file already
+	if s.config != config {
+		return false, nil
+	}
+	s.limits, s.modified = limits, modified
+	logger.Info(ctx, "rate limits loaded", logger.NewLogValue("routes", len(limits.Routes)))
+	return true, nil
+}
+
+// loadLimits
+//
+// the limits of config overridden by its rules file, and the modification time of the file.
+func loadLimits(config configs.RateLimit) (ratelimit.Limits, time.Time, error) {
+	limits := ratelimit.Limits{
+		Read:         ratelimit.Limit{Rate: config.ReadRate, Burst: config.ReadBurst, Concurrency: config.ReadConcurrency},
+		Write:        ratelimit.Limit{Rate: config.WriteRate, Burst: config.WriteBurst, Concurrency: config.WriteConcurrency},
+		AuthFailures: ratelimit.Limit{Rate: config.AuthFailureRate, Burst: config.AuthFailureBurst},
+	}
+	var modified time.Time
+	if config.File != "" {
+		info, err := os.Stat(config.File)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return limits, modified, fmt.Errorf("read rate limits: %w", err)
+		default:
+			modified = info.ModTime()
+			data, err := os.ReadFile(config.File)
+			if err != nil {
+				return limits, modified, fmt.Errorf("read rate limits: %w", err)
+			}
+			// fields missing from the file keep the configured values
+			if err := json.Unmarshal(data, &limits); err != nil {
+				return limits, modified, fmt.Errorf("read rate limits: %w", err)
+			}
+		}
+	}
+	if params := validateLimits(limits); len(params) > 0 {
+		return limits, modified, fmt.Errorf("read rate limits: %w", types.NewValidationError(params...))
+	}
+	return limits, modified, nil
+}
+
+// RunReloader
+//
+// reloads the rules file every RATELIMIT_RELOAD_INTERVAL until ctx is done, and forgets idle clients.
+func (s *RateLimitService) RunReloader(ctx context.Context) {
+	ticker := time.NewTicker(secondsOr(s.configured().ReloadInterval, defaultRateLimitReloadInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Reload(ctx); err != nil {
+				logger.Error(ctx, "error reloading rate limits", err)
+			}
+			s.limiter.Sweep(rateLimitIdle)
+		}
+	}
+}
+
+func (s *RateLimitService) configured() configs.RateLimit {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+func validateLimits(limits ratelimit.Limits) []types.InvalidParam {
+	params := make([]types.InvalidParam, 0)
+	check := func(name string, limit ratelimit.Limit) {
+		if limit.Rate < 0 || limit.Burst < 0 || limit.Concurrency < 0 {
+			params = append(params, types.InvalidParam{Name: name, Reason: "must not be negative"})
+		}
+	}
+	check("read", limits.Read)
+	check("write", limits.Write)
+	check("authFailures", limits.AuthFailures)
+	for route, limit := range limits.Routes {
+		check("routes."+route, limit)
+	}
+	return params
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitServiceReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "limits.json")
+	rs, err := NewRateLimitService(configs.RateLimit{ReadRate: 100, WriteRate: 1, File: path})
+	require.NoError(t, err)
+	assert.Equal(t, RateLimitByClient, rs.Key())
+
+	_, release, err := rs.Admit(ctx, "ci", "PUT /objects/{bucketId}/{objectId}", "PUT")
+	require.NoError(t, err)
+	release()
+	_, _, err = rs.Admit(ctx, "ci", "PUT /objects/{bucketId}/{objectId}", "PUT")
+	assert.ErrorIs(t, err, types.ErrRateLimited)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"write":{"rate":1000},"routes":{"POST /batch":{"rate":1,"concurrency":1}}}`), 0o600))
+	changed, err := rs.Reload(ctx)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 100.0, rs.Limits().Read.Rate)
+	for range 2 {
+		_, _, err = rs.Admit(ctx, "ops", "PUT /objects/{bucketId}/{objectId}", "PUT")
+		assert.NoError(t, err)
+	}
+	_, _, err = rs.Admit(ctx, "ci", "POST /batch", "POST")
+	require.NoError(t, err)
+	_, _, err = rs.Admit(ctx, "ci", "POST /batch", "POST")
+	assert.ErrorIs(t, err, types.ErrRateLimited)
+
+	changed, err = rs.Reload(ctx)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.WriteFile(path, []byte(`{"read":{"rate":-1}}`), 0o600))
+	require.NoError(t, os.Chtimes(path, later, later))
+	_, err = rs.Reload(ctx)
+	var validationErr *types.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, 100.0, rs.Limits().Read.Rate)
+
+	_, err = NewRateLimitService(configs.RateLimit{Key: "cookie"})
+	assert.Error(t, err)
+}
+
+func TestRateLimitServiceConfigure(t *testing.T) {
+	ctx := context.Background()
+	rs, err := NewRateLimitService(configs.RateLimit{ReadRate: 100, WriteRate: 1})
+	require.NoError(t, err)
+
+	require.NoError(t, rs.Configure(ctx, configs.RateLimit{Key: RateLimitByIp, ReadRate: 5, WriteRate: 2}))
+	assert.Equal(t, RateLimitByIp, rs.Key())
+	assert.Equal(t, 5.0, rs.Limits().Read.Rate)
+	assert.Equal(t, 2.0, rs.Limits().Write.Rate)
+
+	// invalid configurations leave the current one in place
+	assert.Error(t, rs.Configure(ctx, configs.RateLimit{Key: "cookie", ReadRate: 50}))
+	assert.Error(t, rs.Configure(ctx, configs.RateLimit{ReadRate: -1}))
+	assert.Equal(t, RateLimitByIp, rs.Key())
+	assert.Equal(t, 5.0, rs.Limits().Read.Rate)
+}
@@ -1,11 +1,35 @@
 package services
 
 type Services struct {
-	BucketService *BucketService
+	BucketService      *BucketService
+	BatchService       *BatchService
+	IdempotencyService *IdempotencyService
+	ChangeService      *ChangeService
+	WatchService       *WatchService
+	WebhookService     *WebhookService
+	APIKeyService      *APIKeyService
+	TokenService       *TokenService // nil unless JWT authentication is configured
+	PolicyService      *PolicyService
+	RateLimitService   *RateLimitService
+	AdmissionService   *AdmissionService
+	HealthService      *HealthService
+	LogLevelService    *LogLevelService
 }
 
-func NewServices(bs *BucketService) *Services {
+func NewServices(bs *BucketService, batch *BatchService, idempotency *IdempotencyService, changes *ChangeService, watch *WatchService, webhooks *WebhookService, keys *APIKeyService, tokens *TokenService, policies *PolicyService, limits *RateLimitService, admission *AdmissionService, health *HealthService, logLevels *LogLevelService) *Services {
 	return &Services{
-		BucketService: bs,
+		BucketService:      bs,
+		BatchService:       batch,
+		IdempotencyService: idempotency,
+		ChangeService:      changes,
+		WatchService:       watch,
+		WebhookService:     webhooks,
+		APIKeyService:      keys,
+		TokenService:       tokens,
+		PolicyService:      policies,
+		RateLimitService:   limits,
+		AdmissionService:   admission,
+		HealthService:      health,
+		LogLevelService:    logLevels,
 	}
 }
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/types"
+)
+
+const (
+	defaultJWTLeeway           = time.Minute
+	defaultJWTPermissionsClaim = "permissions"
+	defaultJWTRolesClaim       = "roles"
+	defaultJWTTenantClaim      = "tenant"
+)
+
+// TokenService
+//
+// authenticates the JWTs issued by the platform. The permissions claim may hold a list of
+// auth.Permission objects, or "bucket:action" grants as a list or a space separated string
+// (as in an OAuth scope), e.g. "logs:read team-*:admin". The roles claim is a list or a
+// space separated string of role names, and the tenant claim names the tenant of the subject.
+type TokenService struct {
+	jwks     *auth.JWKS
+	issuer   string
+	audience string
+	claim    string
+	roles    string
+	tenant   string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+func NewTokenService(config configs.JWT) *TokenService {
+	s := &TokenService{
+		jwks:     auth.NewJWKS(config.JWKS, time.Duration(config.JWKSRefresh)*time.Second),
+		issuer:   config.Issuer,
+		audience: config.Audience,
+		claim:    config.PermissionsClaim,
+		roles:    config.RolesClaim,
+		tenant:   config.TenantClaim,
+		leeway:   secondsOr(config.Leeway, defaultJWTLeeway),
+		now:      time.Now,
+	}
+	if s.claim == "" {
+		s.claim = defaultJWTPermissionsClaim
+	}
+	if s.roles == "" {
+		s.roles = defaultJWTRolesClaim
+	}
+	if s.tenant == "" {
+		s.tenant = defaultJWTTenantClaim
+	}
+	return s
+}
+
+// Authenticate
+//
+// verifies token and its registered claims, failing with types.ErrUnauthenticated.
+func (s *TokenService) Authenticate(ctx context.Context, token string) (*auth.Principal, error) {
+	header, err := auth.ParseJWTHeader(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", types.ErrUnauthenticated, err)
+	}
+	set, err := s.jwks.KeySet(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := auth.VerifyJWT(token, set)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", types.ErrUnauthenticated, err)
+	}
+	if err := s.validateClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %w", types.ErrUnauthenticated, err)
+	}
+	subject := claims["sub"].(string)
+	tenantId, _ := claims[s.tenant].(string)
+	return &auth.Principal{
+		Id:          "jwt:" + subject,
+		Name:        subject,
+		Tenant:      tenantId,
+		Roles:       rolesFromClaim(claims[s.roles]),
+		Permissions: permissionsFromClaim(claims[s.claim]),
+	}, nil
+}
+
+func (s *TokenService) validateClaims(claims map[string]any) error {
+	now := s.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(s.leeway)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(s.leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token not yet valid")
+	}
+	if subject, _ := claims["sub"].(string); subject == "" {
+		return errors.New("token has no subject")
+	}
+	if s.issuer != "" && claims["iss"] != s.issuer {
+		return errors.New("unexpected token issuer")
+	}
+	if s.audience != "" && !audienceContains(claims["aud"], s.audience) {
+		return errors.New("unexpected token audience")
+	}
+	return nil
+}
+
+// audienceContains
+//
+// aud is either a single string or a list of strings.
+func audienceContains(aud any, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []any:
+		return slices.Contains(v, any(audience))
+	}
+	return false
+}
+
+// permissionsFromClaim
+//
+// maps the permissions claim to auth.Permission, ignoring malformed entries.
+func permissionsFromClaim(claim any) []auth.Permission {
+	var grants []any
+	switch v := claim.(type) {
+	case string:
+		for _, grant := range strings.Fields(v) {
+			grants = append(grants, grant)
+		}
+	case []any:
+		grants = v
+	}
+	permissions := make([]auth.Permission, 0, len(grants))
+	for _, grant := range grants {
+		switch g := grant.(type) {
+		case string:
+			i := strings.LastIndex(g, ":")
+			if i <= 0 {
+				continue
+			}
+			permissions = append(permissions, auth.Permission{Bucket: g[:i], Actions: []auth.Action{auth.Action(g[i+1:])}})
+		case map[string]any:
+			bucketId, _ := g["bucket"].(string)
+			actions, _ := g["actions"].([]any)
+			permission := auth.Permission{Bucket: bucketId}
+			for _, action := range actions {
+				if a, ok := action.(string); ok {
+					permission.Actions = append(permission.Actions, auth.Action(a))
+				}
+			}
+			if bucketId != "" && len(permission.Actions) > 0 {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions
+}
+
+func rolesFromClaim(claim any) []string {
+	var roles []string
+	switch v := claim.(type) {
+	case string:
+		roles = strings.Fields(v)
+	case []any:
+		for _, role := range v {
+			if r, ok := role.(string); ok && r != "" {
+				roles = append(roles, r)
+			}
+		}
+	}
+	return roles
+}
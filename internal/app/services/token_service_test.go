@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/pkg/auth"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signHS256(secret []byte, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": auth.AlgHS256, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestTokenServiceAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwks, []byte(`{"keys":[{"kty":"oct","k":"`+base64.RawURLEncoding.EncodeToString(secret)+`"}]}`), 0o600))
+	ts := NewTokenService(configs.JWT{JWKS: jwks, Issuer: "https://issuer", Audience: "buckets", Leeway: 1})
+
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{
+			"sub":    "alice",
+			"iss":    "https://issuer",
+			"aud":    []string{"other", "buckets"},
+			"exp":    now.Add(time.Hour).Unix(),
+			"roles":  "auditors readers",
+			"tenant": "acme",
+			"permissions": []any{
+				"logs:read",
+				map[string]any{"bucket": "team-*", "actions": []string{"admin"}},
+			},
+		}
+	}
+
+	principal, err := ts.Authenticate(ctx, signHS256(secret, valid()))
+	require.NoError(t, err)
+	assert.Equal(t, "jwt:alice", principal.Id)
+	assert.Equal(t, []string{"auditors", "readers"}, principal.Roles)
+	assert.Equal(t, "acme", principal.HomeTenant())
+	assert.True(t, principal.Allows("logs", auth.ActionRead))
+	assert.False(t, principal.Allows("logs", auth.ActionWrite))
+	assert.True(t, principal.Allows("team-a", auth.ActionDelete))
+
+	for name, mutate := range map[string]func(map[string]any){
+		"expired":       func(c map[string]any) { c["exp"] = now.Add(-time.Minute).Unix() },
+		"no expiry":     func(c map[string]any) { delete(c, "exp") },
+		"not yet valid": func(c map[string]any) { c["nbf"] = now.Add(time.Minute).Unix() },
+		"issuer":        func(c map[string]any) { c["iss"] = "https://elsewhere" },
+		"audience":      func(c map[string]any) { c["aud"] = "other" },
+		"subject":       func(c map[string]any) { delete(c, "sub") },
+	} {
+		claims := valid()
+		mutate(claims)
+		_, err := ts.Authenticate(ctx, signHS256(secret, claims))
+		assert.ErrorIs(t, err, types.ErrUnauthenticated, name)
+	}
+
+	_, err = ts.Authenticate(ctx, signHS256([]byte("another secret"), valid()))
+	assert.ErrorIs(t, err, types.ErrUnauthenticated)
+}
+
+func TestPermissionsFromClaim(t *testing.T) {
+	assert.Equal(t, []auth.Permission{
+		{Bucket: "logs", Actions: []auth.Action{auth.ActionRead}},
+		{Bucket: "*", Actions: []auth.Action{auth.ActionAdmin}},
+	}, permissionsFromClaim("logs:read *:admin invalid"))
+	assert.Empty(t, permissionsFromClaim(nil))
+	assert.Empty(t, permissionsFromClaim([]any{map[string]any{"bucket": "logs"}, 42}))
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+)
+
+const (
+	defaultWatchBuffer    = 256
+	defaultWatchHeartbeat = 15 * time.Second
+	watchHistoryPage      = 100
+)
+
+const (
+	WatchKindCreate = "create"
+	WatchKindUpdate = "update"
+	WatchKindDelete = "delete"
+)
+
+// WatchFilter
+//
+// selects the changes of a bucket of TenantId; empty fields match every tenant or bucket.
+type WatchFilter struct {
+	TenantId string
+	BucketId string
+	Prefix   string
+}
+
+// WatchService
+//
+// fans out recorded changes to live watchers. Every watcher has a bounded buffer: a watcher
+// that falls behind is dropped with types.ErrWatchOverflow and can resume from the last
+// sequence it received.
+type WatchService struct {
+	changes  *ChangeService
+	watchers map[*Watcher]struct{}
+	closing  chan struct{}
+	config   configs.Watch
+	mu       sync.Mutex
+	closed   bool
+}
+
+func NewWatchService(cs *ChangeService, config configs.Watch) *WatchService {
+	s := &WatchService{
+		changes:  cs,
+		config:   config,
+		watchers: make(map[*Watcher]struct{}),
+		closing:  make(chan struct{}),
+	}
+	cs.AddListener(s.publish)
+	return s
+}
+
+// Watch
+//
+// starts watching object changes matching filter. With resume set, the changes recorded
+// after since are replayed from the change log before live ones.
+func (s *WatchService) Watch(ctx context.Context, filter WatchFilter, since uint64, resume bool) (*Watcher, error) {
+	w := &Watcher{
+		service:   s,
+		filter:    filter,
+		events:    make(chan changes.Event, s.buffer()),
+		done:      make(chan struct{}),
+		last:      since,
+		replaying: resume,
+	}
+	if !resume {
+		// live only: anything recorded before now is history
+		last, err := s.changes.repo.LastSequence(ctx)
+		if err != nil {
+			return nil, err
+		}
+		w.last = last
+		if err := s.register(w); err != nil {
+			return nil, err
+		}
+	}
+	logger.Debug(ctx, "watch started", logger.NewLogValue("bucketId", filter.BucketId), logger.NewLogValue("prefix", filter.Prefix))
+	return w, nil
+}
+
+func (s *WatchService) Heartbeat() time.Duration {
+	if s.config.Heartbeat <= 0 {
+		return defaultWatchHeartbeat
+	}
+	return time.Duration(s.config.Heartbeat) * time.Second
+}
+
+// Close
+//
+// ends every watcher with types.ErrWatchClosed and refuses new ones; called when the
+// server starts shutting down so that streaming requests can complete.
+func (s *WatchService) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.closing)
+	for w := range s.watchers {
+		s.drop(w, types.ErrWatchClosed)
+	}
+}
+
+func (s *WatchService) register(w *Watcher) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return types.ErrWatchClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
+	s.watchers[w] = struct{}{}
+	w.registered = true
+	return nil
+}
+
+// Closing
+//
+// is closed when the service is shutting down.
+func (s *WatchService) Closing() <-chan struct{} {
+	return s.closing
+}
+
+func (s *WatchService) publish(events []changes.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for w := range s.watchers {
+		for _, e := range events {
+			if !w.matches(e) {
+				continue
+			}
+			select {
+			case w.events <- e:
+			default:
+				s.drop(w, types.ErrWatchOverflow)
+			}
+		}
+	}
+}
+
+// drop
+//
+// callers must hold s.mu.
+func (s *WatchService) drop(w *Watcher, err error) {
+	if _, ok := s.watchers[w]; !ok {
+		return
+	}
+	delete(s.watchers, w)
+	w.registered = false
+	w.err = err
+	close(w.done)
+}
+
+func (s *WatchService) buffer() int {
+	if s.config.Buffer <= 0 {
+		return defaultWatchBuffer
+	}
+	return s.config.Buffer
+}
+
+// Watcher
+//
+// a single watch stream, not safe for concurrent use.
+type Watcher struct {
+	service   *WatchService
+	events    chan changes.Event
+	done      chan struct{}
+	err       error
+	history   []changes.Event
+	filter    WatchFilter
+	last      uint64
+	replaying bool
+	// registered is set once the watcher receives live changes; a resuming watcher
+	// registers only after catching up, so that the replay cannot overflow its buffer.
+	registered bool
+}
+
+// Next
+//
+// returns the next matching change, blocking until one is available or ctx is done.
+// It fails with types.ErrWatchOverflow once the watcher was dropped for being too slow
+// (after the buffered changes were returned) and with types.ErrWatchClosed on shutdown.
+func (w *Watcher) Next(ctx context.Context) (changes.Event, error) {
+	for {
+		if w.replaying {
+			e, ok, err := w.nextFromHistory(ctx)
+			if err != nil {
+				return changes.Event{}, err
+			}
+			if ok {
+				return e, nil
+			}
+			continue
+		}
+		select {
+		case e := <-w.events:
+			if e, ok := w.accept(e); ok {
+				return e, nil
+			}
+			continue
+		default:
+		}
+		select {
+		case e := <-w.events:
+			if e, ok := w.accept(e); ok {
+				return e, nil
+			}
+		case <-w.done:
+			if len(w.events) > 0 && w.err != types.ErrWatchClosed {
+				continue
+			}
+			return changes.Event{}, w.err
+		case <-ctx.Done():
+			return changes.Event{}, ctx.Err()
+		}
+	}
+}
+
+// LastSequence
+//
+// the sequence of the last change returned by Next, to resume from.
+func (w *Watcher) LastSequence() uint64 {
+	return w.last
+}
+
+func (w *Watcher) Close() {
+	w.service.mu.Lock()
+	defer w.service.mu.Unlock()
+	w.service.drop(w, types.ErrWatchClosed)
+}
+
+func (w *Watcher) live() bool {
+	w.service.mu.Lock()
+	defer w.service.mu.Unlock()
+	return w.registered
+}
+
+func (w *Watcher) nextFromHistory(ctx context.Context) (changes.Event, bool, error) {
+	if len(w.history) == 0 {
+		page, err := w.service.changes.history(ctx, w.last, w.filter.TenantId, w.filter.BucketId, watchHistoryPage)
+		if err != nil {
+			return changes.Event{}, false, err
+		}
+		if len(page) == 0 {
+			if w.live() {
+				w.replaying = false
+				return changes.Event{}, false, nil
+			}
+			// read once more after registering: changes recorded in between are
+			// in the history, later ones in the buffer
+			if err := w.service.register(w); err != nil {
+				return changes.Event{}, false, err
+			}
+			return changes.Event{}, false, nil
+		}
+		w.history = page
+	}
+	e := w.history[0]
+	w.history = w.history[1:]
+	w.last = e.Sequence
+	return e, w.matches(e), nil
+}
+
+// accept
+//
+// skips live changes already returned while replaying the history.
+func (w *Watcher) accept(e changes.Event) (changes.Event, bool) {
+	if e.Sequence <= w.last {
+		return e, false
+	}
+	w.last = e.Sequence
+	return e, true
+}
+
+func (w *Watcher) matches(e changes.Event) bool {
+	if WatchKind(e.Type) == "" {
+		return false
+	}
+	if w.filter.TenantId != "" && e.TenantId != w.filter.TenantId {
+		return false
+	}
+	if w.filter.BucketId != "" && e.BucketId != w.filter.BucketId {
+		return false
+	}
+	return strings.HasPrefix(e.ObjectId, w.filter.Prefix)
+}
+
+// WatchKind
+//
+// maps a change type to the create/update/delete kind pushed to watchers; changes that do
+// not affect live objects map to "".
+func WatchKind(eventType string) string {
+	switch bucket.MutationType(eventType) {
+	case bucket.MutationObjectCreated, bucket.MutationObjectRestored:
+		return WatchKindCreate
+	case bucket.MutationObjectUpdated, bucket.MutationObjectLockChanged:
+		return WatchKindUpdate
+	case bucket.MutationObjectDeleted, bucket.MutationObjectTrashed:
+		return WatchKindDelete
+	default:
+		return ""
+	}
+}
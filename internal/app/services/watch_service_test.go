@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWatchFixture(buffer int) (*WatchService, *bucket.InMemoryRepo) {
+	cs := NewChangeService(changes.NewInMemoryRepo(), configs.Changes{})
+	return NewWatchService(cs, configs.Watch{Buffer: buffer}), bucket.NewInMemoryRepo(bucket.WithCommitHook(cs.Record))
+}
+
+func TestWatchFiltersLiveChanges(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ws, repo := newWatchFixture(0)
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "img/old"))
+
+	watcher, err := ws.Watch(ctx, WatchFilter{BucketId: "bucket", Prefix: "img/"}, 0, false)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	require.NoError(t, repo.InsertObject(ctx, "other", "img/1"))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "doc/1"))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "img/1"))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "img/1"))
+	require.NoError(t, repo.RemoveObject(ctx, "bucket", "img/1"))
+
+	for _, kind := range []string{WatchKindCreate, WatchKindUpdate, WatchKindDelete} {
+		event, err := watcher.Next(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "img/1", event.ObjectId)
+		assert.Equal(t, kind, WatchKind(event.Type))
+	}
+}
+
+func TestWatchOverflowAndResume(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ws, repo := newWatchFixture(2)
+
+	watcher, err := ws.Watch(ctx, WatchFilter{BucketId: "bucket"}, 0, false)
+	require.NoError(t, err)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		require.NoError(t, repo.InsertObject(ctx, "bucket", id))
+	}
+
+	received := make([]string, 0)
+	for {
+		event, err := watcher.Next(ctx)
+		if err != nil {
+			assert.ErrorIs(t, err, types.ErrWatchOverflow)
+			break
+		}
+		received = append(received, event.ObjectId)
+	}
+	assert.Equal(t, []string{"1", "2"}, received)
+
+	resumed, err := ws.Watch(ctx, WatchFilter{BucketId: "bucket"}, watcher.LastSequence(), true)
+	require.NoError(t, err)
+	defer resumed.Close()
+	for _, id := range []string{"3", "4"} {
+		event, err := resumed.Next(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, id, event.ObjectId)
+	}
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "5"))
+	event, err := resumed.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "5", event.ObjectId)
+}
+
+func TestWatchClose(t *testing.T) {
+	ctx := context.Background()
+	ws, _ := newWatchFixture(0)
+	watcher, err := ws.Watch(ctx, WatchFilter{BucketId: "bucket"}, 0, false)
+	require.NoError(t, err)
+
+	go ws.Close()
+	_, err = watcher.Next(ctx)
+	assert.ErrorIs(t, err, types.ErrWatchClosed)
+	_, err = ws.Watch(ctx, WatchFilter{BucketId: "bucket"}, 0, false)
+	assert.ErrorIs(t, err, types.ErrWatchClosed)
+}
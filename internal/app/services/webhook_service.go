@@ -0,0 +1,517 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/app/repository/webhook"
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/dto/response"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/tracing"
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultWebhookMaxAttempts  = 8
+	defaultWebhookBackoff      = time.Second
+	defaultWebhookMaxBackoff   = time.Hour
+	defaultWebhookTimeout      = 10 * time.Second
+	defaultWebhookConcurrency  = 4
+	defaultWebhookLogRetention = 7 * 24 * time.Hour
+	defaultWebhookLogLimit     = 100
+	webhookPollInterval        = time.Second
+	webhookRetryInterval       = 5 * time.Second
+	webhookPurgeInterval       = time.Hour
+	maxWebhookResponseBody     = 4096
+)
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookIdHeader        = "X-Webhook-Id"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookEventHeader     = "X-Webhook-Event"
+)
+
+// WebhookService
+//
+// manages webhook subscriptions and delivers the matching changes. The dispatcher follows
+// the change log from a cursor stored with the outbox, so no change is lost across restarts,
+// and the deliverer sends due outbox entries, retrying failures with exponential backoff.
+type WebhookService struct {
+	repo        webhook.Repository
+	changes     *ChangeService
+	client      *http.Client
+	wake        chan struct{}
+	config      configs.Webhooks
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	maxAttempts int
+}
+
+func NewWebhookService(repo webhook.Repository, cs *ChangeService, config configs.Webhooks) *WebhookService {
+	s := &WebhookService{
+		repo:        repo,
+		changes:     cs,
+		config:      config,
+		wake:        make(chan struct{}, 1),
+		backoff:     secondsOr(config.Backoff, defaultWebhookBackoff),
+		maxBackoff:  secondsOr(config.MaxBackoff, defaultWebhookMaxBackoff),
+		maxAttempts: config.MaxAttempts,
+	}
+	if s.maxAttempts <= 0 {
+		s.maxAttempts = defaultWebhookMaxAttempts
+	}
+	// receivers are checked once resolved, so that a name cannot be rebound to a private
+	// address after validation; with no proxy, the dialed address is the receiver's
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Control: s.controlDial}).DialContext
+	s.client = &http.Client{Timeout: secondsOr(config.Timeout, defaultWebhookTimeout), Transport: transport}
+	return s
+}
+
+// controlDial
+//
+// refuses the connections to receivers without a public or allowed address.
+func (s *WebhookService) controlDial(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !s.allowedAddr(addrPort.Addr()) {
+		return fmt.Errorf("webhook receiver address %s is not public", addrPort.Addr())
+	}
+	return nil
+}
+
+// allowedAddr
+//
+// whether addr is public, or in one of the allowed networks.
+func (s *WebhookService) allowedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if slices.ContainsFunc(s.config.AllowedNetworks, func(network netip.Prefix) bool { return network.Contains(addr) }) {
+		return true
+	}
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// sharedAddressSpace
+//
+// the carrier-grade NAT range of RFC 6598, not routed on the internet either.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func (s *WebhookService) Create(ctx context.Context, bucketId string, req request.WebhookRequest) (*response.WebhookResponse, error) {
+	if err := s.validateWebhook(req); err != nil {
+		return nil, err
+	}
+	since, err := s.changes.repo.LastSequence(ctx)
+	if err != nil {
+		logger.Error(ctx, "error reading change log", err)
+		return nil, err
+	}
+	secret := req.Secret
+	if secret == "" {
+		secret = newWebhookSecret()
+	}
+	hook := webhook.Webhook{
+		CreatedAt:  time.Now().UTC(),
+		Id:         uuid.NewString(),
+		TenantId:   tenant.FromContext(ctx),
+		BucketId:   bucketId,
+		Url:        req.Url,
+		Prefix:     req.Prefix,
+		Secret:     secret,
+		EventTypes: req.EventTypes,
+		Since:      since,
+	}
+	if err := s.repo.CreateWebhook(ctx, hook); err != nil {
+		logger.Error(ctx, "error creating webhook", err)
+		return nil, err
+	}
+	created := newWebhookResponse(hook)
+	created.Secret = hook.Secret
+	return &created, nil
+}
+
+func (s *WebhookService) List(ctx context.Context, bucketId string) ([]response.WebhookResponse, error) {
+	hooks, err := s.repo.ListWebhooks(ctx, bucketId)
+	if err != nil {
+		logger.Error(ctx, "error listing webhooks", err)
+		return nil, err
+	}
+	webhooks := make([]response.WebhookResponse, 0, len(hooks))
+	for _, hook := range hooks {
+		webhooks = append(webhooks, newWebhookResponse(hook))
+	}
+	return webhooks, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, bucketId, webhookId string) error {
+	if err := s.repo.DeleteWebhook(ctx, bucketId, webhookId); err != nil {
+		logger.Error(ctx, "error deleting webhook", err)
+		return err
+	}
+	return nil
+}
+
+// Deliveries
+//
+// returns the delivery log of a webhook, most recent first.
+func (s *WebhookService) Deliveries(ctx context.Context, bucketId, webhookId string, limit int) ([]response.WebhookDeliveryResponse, error) {
+	if _, err := s.repo.GetWebhook(ctx, bucketId, webhookId); err != nil {
+		logger.Error(ctx, "error getting webhook", err)
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultWebhookLogLimit
+	}
+	deliveries, err := s.repo.ListDeliveries(ctx, webhookId, min(limit, maxChangesLimit))
+	if err != nil {
+		logger.Error(ctx, "error listing webhook deliveries", err)
+		return nil, err
+	}
+	log := make([]response.WebhookDeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		log = append(log, newWebhookDeliveryResponse(d))
+	}
+	return log, nil
+}
+
+// RunDispatcher
+//
+// moves recorded changes into the outbox, one delivery per matching webhook, until ctx is done.
+func (s *WebhookService) RunDispatcher(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := s.dispatch(ctx); err != nil && ctx.Err() == nil {
+			logger.Error(ctx, "error dispatching webhook deliveries", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(webhookRetryInterval):
+			}
+		}
+	}
+}
+
+func (s *WebhookService) dispatch(ctx context.Context) error {
+	cursor, err := s.repo.Cursor(ctx)
+	if err != nil {
+		return err
+	}
+	feed, err := s.changes.List(ctx, cursor, "", "", maxChangesLimit, s.changes.MaxWait())
+	if err != nil || len(feed.Events) == 0 {
+		return err
+	}
+	// the webhooks of each tenant, listed when one of its changes first shows up
+	hooksByTenant := make(map[string][]webhook.Webhook)
+	now := time.Now().UTC()
+	deliveries := make([]webhook.Delivery, 0)
+	for _, e := range feed.Events {
+		hooks, ok := hooksByTenant[e.TenantId]
+		if !ok {
+			if hooks, err = s.repo.ListWebhooks(tenant.WithTenant(ctx, e.TenantId), ""); err != nil {
+				return err
+			}
+			hooksByTenant[e.TenantId] = hooks
+		}
+		for _, hook := range hooks {
+			if !webhookMatches(hook, e) {
+				continue
+			}
+			deliveries = append(deliveries, webhook.Delivery{
+				CreatedAt:     now,
+				NextAttemptAt: now,
+				Event:         e,
+				Id:            uuid.NewString(),
+				WebhookId:     hook.Id,
+				Status:        webhook.DeliveryPending,
+			})
+		}
+	}
+	if err := s.repo.Enqueue(ctx, feed.LastSequence, deliveries...); err != nil {
+		return err
+	}
+	if len(deliveries) > 0 {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// RunDeliverer
+//
+// sends the due deliveries of the outbox until ctx is done, periodically purging the
+// delivery log.
+func (s *WebhookService) RunDeliverer(ctx context.Context) {
+	poll := time.NewTicker(webhookPollInterval)
+	defer poll.Stop()
+	purge := time.NewTicker(webhookPurgeInterval)
+	defer purge.Stop()
+	for {
+		s.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-poll.C:
+		case now := <-purge.C:
+			retention := secondsOr(s.config.LogRetention, defaultWebhookLogRetention)
+			if _, err := s.repo.PurgeDeliveries(ctx, now.Add(-retention)); err != nil {
+				logger.Error(ctx, "error purging webhook deliveries", err)
+			}
+		}
+	}
+}
+
+func (s *WebhookService) deliverDue(ctx context.Context) {
+	concurrency := s.config.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultWebhookConcurrency
+	}
+	for ctx.Err() == nil {
+		due, err := s.repo.DueDeliveries(ctx, time.Now(), concurrency)
+		if err != nil {
+			logger.Error(ctx, "error listing due webhook deliveries", err)
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+		var wg sync.WaitGroup
+		var recorded atomic.Int32
+		for _, d := range due {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if s.attempt(ctx, d) {
+					recorded.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		// the deliveries whose outcome could not be recorded are still due: sending them
+		// again at once would flood their receivers, so they wait for the next round
+		if recorded.Load() == 0 {
+			return
+		}
+	}
+}
+
+// attempt
+//
+// sends d once and records the outcome, scheduling a retry on failure; it reports whether
+// d is no longer due, delivered, rescheduled or gone.
+func (s *WebhookService) attempt(ctx context.Context, d webhook.Delivery) bool {
+	ctx = tenant.WithTenant(ctx, d.Event.TenantId)
+	hook, err := s.repo.GetWebhook(ctx, d.Event.BucketId, d.WebhookId)
+	if err != nil {
+		// deleted meanwhile, together with its deliveries
+		return errors.Is(err, types.ErrNoWebhookFound)
+	}
+	now := time.Now().UTC()
+	d.Attempts++
+	d.LastAttemptAt = now
+	d.ResponseStatus, err = s.send(ctx, hook, d, now)
+	switch {
+	case err == nil:
+		d.Status = webhook.DeliveryDelivered
+		d.LastError = ""
+	case ctx.Err() != nil:
+		// shutting down: the attempt does not count
+		return false
+	case d.Attempts >= s.maxAttempts:
+		d.Status = webhook.DeliveryFailed
+		d.LastError = err.Error()
+	default:
+		d.LastError = err.Error()
+		d.NextAttemptAt = now.Add(s.retryDelay(d.Attempts))
+	}
+	logger.Debug(ctx, "webhook delivery attempted", logger.NewLogValue("delivery", d.Id), logger.NewLogValue("status", d.Status), logger.NewLogValue("attempts", d.Attempts))
+	if err := s.repo.UpdateDelivery(ctx, d); err != nil && !errors.Is(err, types.ErrNoDeliveryFound) {
+		logger.Error(ctx, "error updating webhook delivery", err)
+		return false
+	}
+	return true
+}
+
+// send
+//
+// posts the delivery to the receiver, propagating the trace of its span in the traceparent header.
+func (s *WebhookService) send(ctx context.Context, hook webhook.Webhook, d webhook.Delivery, now time.Time) (int, error) {
+	ctx, span := tracing.StartKind(ctx, tracing.KindClient, "webhook.deliver",
+		tracing.String("webhook.id", hook.Id), tracing.String("webhook.delivery.id", d.Id), tracing.Int("webhook.delivery.attempt", d.Attempts))
+	defer span.End()
+	body, err := json.Marshal(response.WebhookPayload{DeliveryId: d.Id, WebhookId: hook.Id, Event: d.Event})
+	if err != nil {
+		return 0, span.Fail(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, span.Fail(err)
+	}
+	tracing.Inject(ctx, req.Header)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIdHeader, hook.Id)
+	req.Header.Set(WebhookDeliveryHeader, d.Id)
+	req.Header.Set(WebhookEventHeader, d.Event.Type)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(hook.Secret, now, body))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, span.Fail(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxWebhookResponseBody))
+	span.SetAttributes(tracing.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, span.Fail(fmt.Errorf("receiver answered %s", resp.Status))
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelay
+//
+// the backoff after the given number of failed attempts: doubling from the base, capped.
+func (s *WebhookService) retryDelay(attempts int) time.Duration {
+	delay := s.backoff
+	for i := 1; i < attempts && delay < s.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.maxBackoff)
+}
+
+// SignWebhook
+//
+// returns the signature header value of a delivery: "t=<unix seconds>,v1=<hex HMAC-SHA256>",
+// where the HMAC covers "<unix seconds>.<body>" keyed with the webhook secret.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookMatches(hook webhook.Webhook, e changes.Event) bool {
+	if e.Sequence <= hook.Since || e.BucketId != hook.BucketId {
+		return false
+	}
+	if len(hook.EventTypes) > 0 && !slices.Contains(hook.EventTypes, e.Type) {
+		return false
+	}
+	return strings.HasPrefix(e.ObjectId, hook.Prefix)
+}
+
+// validateWebhook
+//
+// receivers named by address must have an allowed one; the addresses of the others are
+// checked when delivering.
+func (s *WebhookService) validateWebhook(req request.WebhookRequest) error {
+	params := make([]types.InvalidParam, 0)
+	u, err := url.Parse(req.Url)
+	switch {
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		params = append(params, types.InvalidParam{Name: "url", Reason: "must be an absolute http or https URL"})
+	default:
+		addr, err := netip.ParseAddr(u.Hostname())
+		if strings.EqualFold(u.Hostname(), "localhost") {
+			addr, err = netip.AddrFrom4([4]byte{127, 0, 0, 1}), nil
+		}
+		if err == nil && !s.allowedAddr(addr) {
+			params = append(params, types.InvalidParam{Name: "url", Reason: "must be a public address"})
+		}
+	}
+	for _, eventType := range req.EventTypes {
+		if !slices.Contains(webhookEventTypes, bucket.MutationType(eventType)) {
+			params = append(params, types.InvalidParam{Name: "eventTypes", Reason: fmt.Sprintf("unknown event type %q", eventType)})
+		}
+	}
+	if len(params) > 0 {
+		return types.NewValidationError(params...)
+	}
+	return nil
+}
+
+var webhookEventTypes = []bucket.MutationType{
+	bucket.MutationBucketCreated,
+	bucket.MutationObjectCreated,
+	bucket.MutationObjectUpdated,
+	bucket.MutationObjectDeleted,
+	bucket.MutationObjectTrashed,
+	bucket.MutationObjectRestored,
+	bucket.MutationObjectPurged,
+	bucket.MutationObjectLockChanged,
+}
+
+func newWebhookSecret() string {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	return hex.EncodeToString(secret)
+}
+
+func newWebhookResponse(hook webhook.Webhook) response.WebhookResponse {
+	eventTypes := hook.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	return response.WebhookResponse{
+		CreatedAt:  hook.CreatedAt,
+		Id:         hook.Id,
+		BucketId:   hook.BucketId,
+		Url:        hook.Url,
+		Prefix:     hook.Prefix,
+		EventTypes: eventTypes,
+	}
+}
+
+func newWebhookDeliveryResponse(d webhook.Delivery) response.WebhookDeliveryResponse {
+	delivery := response.WebhookDeliveryResponse{
+		CreatedAt:      d.CreatedAt,
+		Event:          d.Event,
+		Id:             d.Id,
+		Status:         string(d.Status),
+		LastError:      d.LastError,
+		Attempts:       d.Attempts,
+		ResponseStatus: d.ResponseStatus,
+	}
+	if !d.Finished() {
+		delivery.NextAttemptAt = &d.NextAttemptAt
+	}
+	if d.Attempts > 0 {
+		delivery.LastAttemptAt = &d.LastAttemptAt
+	}
+	return delivery
+}
+
+func secondsOr(seconds int, fallback time.Duration) time.Duration {
+	if seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
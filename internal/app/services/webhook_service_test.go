@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bucket_organizer/internal/app/repository/bucket"
+	"bucket_organizer/internal/app/repository/changes"
+	"bucket_organizer/internal/app/repository/webhook"
+	"bucket_organizer/internal/app/server/dto/request"
+	"bucket_organizer/internal/app/server/dto/response"
+	"bucket_organizer/internal/pkg/configs"
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookReceiver struct {
+	payloads []response.WebhookPayload
+	failures int
+	mu       sync.Mutex
+}
+
+func (rcv *webhookReceiver) handler(t *testing.T, secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload response.WebhookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		if rcv.failures > 0 {
+			rcv.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var timestamp int64
+		var signature string
+		_, err := fmt.Sscanf(r.Header.Get(WebhookSignatureHeader), "t=%d,v1=%s", &timestamp, &signature)
+		require.NoError(t, err)
+		assert.Equal(t, SignWebhook(secret, time.Unix(timestamp, 0), body), r.Header.Get(WebhookSignatureHeader))
+		assert.Equal(t, payload.Event.Type, r.Header.Get(WebhookEventHeader))
+		rcv.payloads = append(rcv.payloads, payload)
+	}
+}
+
+func (rcv *webhookReceiver) received() []response.WebhookPayload {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]response.WebhookPayload{}, rcv.payloads...)
+}
+
+func newWebhookFixture(t *testing.T, repo webhook.Repository) (*WebhookService, *bucket.InMemoryRepo) {
+	cs := NewChangeService(changes.NewInMemoryRepo(), configs.Changes{})
+	// the receivers of the tests listen on the loopback interface
+	ws := NewWebhookService(repo, cs, configs.Webhooks{MaxAttempts: 3, AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}})
+	ws.backoff = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go ws.RunDispatcher(ctx)
+	go ws.RunDeliverer(ctx)
+	return ws, bucket.NewInMemoryRepo(bucket.WithCommitHook(cs.Record))
+}
+
+func TestWebhookDeliversMatchingChangesWithRetries(t *testing.T) {
+	ctx := context.Background()
+	ws, repo := newWebhookFixture(t, webhook.NewInMemoryRepo())
+	rcv := &webhookReceiver{failures: 2}
+	const secret = "s3cr3t"
+	server := httptest.NewServer(rcv.handler(t, secret))
+	defer server.Close()
+
+	hook, err := ws.Create(ctx, "bucket", request.WebhookRequest{
+		Url:        server.URL,
+		Prefix:     "img/",
+		Secret:     secret,
+		EventTypes: []string{string(bucket.MutationObjectCreated)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, secret, hook.Secret)
+
+	require.NoError(t, repo.InsertObject(ctx, "other", "img/1"))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "doc/1"))
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "img/1"))
+	require.NoError(t, repo.RemoveObject(ctx, "bucket", "img/1"))
+
+	require.Eventually(t, func() bool { return len(rcv.received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	payload := rcv.received()[0]
+	assert.Equal(t, "img/1", payload.Event.ObjectId)
+	assert.Equal(t, hook.Id, payload.WebhookId)
+
+	log, err := ws.Deliveries(ctx, "bucket", hook.Id, 0)
+	require.NoError(t, err)
+	require.Len(t, log, 1)
+	assert.Equal(t, string(webhook.DeliveryDelivered), log[0].Status)
+	assert.Equal(t, 3, log[0].Attempts)
+	assert.Equal(t, http.StatusOK, log[0].ResponseStatus)
+}
+
+func TestWebhookGivesUpAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	ws, repo := newWebhookFixture(t, webhook.NewInMemoryRepo())
+	rcv := &webhookReceiver{failures: 10}
+	server := httptest.NewServer(rcv.handler(t, ""))
+	defer server.Close()
+
+	require.NoError(t, repo.InsertObject(ctx, "bucket", "1"))
+	hook, err := ws.Create(ctx, "bucket", request.WebhookRequest{Url: server.URL})
+	require.NoError(t, err)
+	assert.NotEmpty(t, hook.Secret)
+	require.NoError(t, repo.RemoveObject(ctx, "bucket", "1"))
+
+	require.Eventually(t, func() bool {
+		log, err := ws.Deliveries(ctx, "bucket", hook.Id, 0)
+		return err == nil && len(log) == 1 && log[0].Status == string(webhook.DeliveryFailed)
+	}, 5*time.Second, 10*time.Millisecond)
+	log, _ := ws.Deliveries(ctx, "bucket", hook.Id, 0)
+	assert.Equal(t, 3, log[0].Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, log[0].ResponseStatus)
+	assert.Nil(t, log[0].NextAttemptAt)
+}
+
+func TestWebhookValidation(t *testing.T) {
+	ctx := context.Background()
+	ws, _ := newWebhookFixture(t, webhook.NewInMemoryRepo())
+
+	_, err := ws.Create(ctx, "bucket", request.WebhookRequest{Url: "ftp://example.com", EventTypes: []string{"object.exploded"}})
+	var validationErr *types.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Params, 2)
+
+	_, err = ws.Deliveries(ctx, "bucket", "missing", 0)
+	assert.ErrorIs(t, err, types.ErrNoWebhookFound)
+
+	for _, target := range []string{"http://169.254.169.254/latest", "https://10.0.0.1", "http://[::1]:8080", "http://[fe80::1]", "http://[::ffff:192.168.1.1]"} {
+		_, err = ws.Create(ctx, "bucket", request.WebhookRequest{Url: target})
+		assert.ErrorAs(t, err, &validationErr, target)
+	}
+	_, err = ws.Create(ctx, "bucket", request.WebhookRequest{Url: "http://127.0.0.1:9000"})
+	assert.NoError(t, err, "allowed network")
+}
+
+func TestWebhookRefusesPrivateReceivers(t *testing.T) {
+	ws := NewWebhookService(webhook.NewInMemoryRepo(), nil, configs.Webhooks{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	assert.Error(t, ws.validateWebhook(request.WebhookRequest{Url: "http://LocalHost:8080"}))
+	// a name resolving to a private address, as after DNS rebinding, is refused once dialed
+	hook := webhook.Webhook{Id: "hook", Url: strings.Replace(server.URL, "127.0.0.1", "localhost", 1)}
+	_, err := ws.send(context.Background(), hook, webhook.Delivery{Id: "delivery"}, time.Now())
+	assert.ErrorContains(t, err, "is not public")
+}
+
+// unrecordedDeliveries
+//
+// a webhook repository failing to record the outcome of deliveries, e.g. on fsync errors.
+type unrecordedDeliveries struct {
+	*webhook.InMemoryRepo
+}
+
+func (r unrecordedDeliveries) UpdateDelivery(context.Context, webhook.Delivery) error {
+	return errors.New("sync failed")
+}
+
+func TestWebhookDeliverDueStopsWhenNothingIsRecorded(t *testing.T) {
+	ctx := context.Background()
+	repo := unrecordedDeliveries{webhook.NewInMemoryRepo()}
+	ws := NewWebhookService(repo, nil, configs.Webhooks{AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}})
+	var sent atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { sent.Add(1) }))
+	defer server.Close()
+
+	require.NoError(t, repo.CreateWebhook(ctx, webhook.Webhook{Id: "hook", BucketId: "bucket", Url: server.URL}))
+	now := time.Now()
+	require.NoError(t, repo.Enqueue(ctx, 1, webhook.Delivery{
+		Id: "delivery", WebhookId: "hook", CreatedAt: now, NextAttemptAt: now, Status: webhook.DeliveryPending,
+		Event: changes.Event{BucketId: "bucket", TenantId: tenant.Default},
+	}))
+
+	// the delivery stays due, but is sent once per round rather than in a loop
+	ws.deliverDue(ctx)
+	assert.Equal(t, int32(1), sent.Load())
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	ws := NewWebhookService(webhook.NewInMemoryRepo(), nil, configs.Webhooks{Backoff: 1, MaxBackoff: 5})
+	assert.Equal(t, time.Second, ws.retryDelay(1))
+	assert.Equal(t, 2*time.Second, ws.retryDelay(2))
+	assert.Equal(t, 4*time.Second, ws.retryDelay(3))
+	assert.Equal(t, 5*time.Second, ws.retryDelay(4))
+}
@@ -15,6 +15,7 @@ func GracefulShutdown(ctx context.Context, timeout time.Duration, server *server
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c
 	logger.Info(ctx, "Shutting down server.", logger.NewLogValue("timeout", timeout.String()))
+	server.Drain(ctx)
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer func() {
 		_ = logger.Sync()
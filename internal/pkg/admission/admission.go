@@ -0,0 +1,158 @@
+package admission
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Priority
+//
+// the class of a request; when the server nears its limit, lower priorities are shed first.
+type Priority int
+
+const (
+	PriorityDebug Priority = iota
+	PriorityRead
+	PriorityWrite
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityDebug:
+		return "debug"
+	case PriorityRead:
+		return "read"
+	default:
+		return "write"
+	}
+}
+
+// shares
+//
+// the fraction of the limit each priority may fill: reads leave room for writes, which are not
+// safely retried by every client, and debug traffic never takes more than half.
+var shares = map[Priority]float64{
+	PriorityDebug: 0.5,
+	PriorityRead:  0.9,
+	PriorityWrite: 1,
+}
+
+const (
+	shortWindow = 10
+	longWindow  = 100
+	// tolerance of the short RTT over the long one before the limit shrinks
+	tolerance = 1.5
+	smoothing = 0.2
+)
+
+// Stats
+//
+// a snapshot of the Controller state; latencies are in seconds.
+type Stats struct {
+	Limit    float64           `json:"limit"`
+	InFlight int               `json:"inFlight"`
+	ShortRtt float64           `json:"shortRtt"`
+	LongRtt  float64           `json:"longRtt"`
+	Admitted map[string]uint64 `json:"admitted"`
+	Rejected map[string]uint64 `json:"rejected"`
+}
+
+// Controller
+//
+// limits the requests in flight to an adaptive limit, following the gradient of the latency:
+// while the recent (short) average RTT stays close to the long-term one the limit grows by
+// its square root, when requests queue up and the short RTT rises it shrinks proportionally.
+type Controller struct {
+	mu       sync.Mutex
+	limit    float64
+	min      float64
+	max      float64
+	inFlight int
+	shortRtt float64
+	longRtt  float64
+	admitted map[Priority]uint64
+	rejected map[Priority]uint64
+	now      func() time.Time
+}
+
+func NewController(initial, min, max int) *Controller {
+	return &Controller{
+		limit:    float64(initial),
+		min:      float64(min),
+		max:      float64(max),
+		admitted: make(map[Priority]uint64),
+		rejected: make(map[Priority]uint64),
+		now:      time.Now,
+	}
+}
+
+// Acquire
+//
+// admits a request of priority p, returning the function to call once it is served; ok is
+// false when the request must be rejected.
+func (c *Controller) Acquire(p Priority) (done func(), ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if float64(c.inFlight) >= max(1, c.limit*shares[p]) {
+		c.rejected[p]++
+		return nil, false
+	}
+	c.admitted[p]++
+	c.inFlight++
+	inFlight := c.inFlight
+	start := c.now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.inFlight--
+			c.sample(c.now().Sub(start), inFlight)
+		})
+	}, true
+}
+
+func (c *Controller) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := Stats{
+		Limit:    c.limit,
+		InFlight: c.inFlight,
+		ShortRtt: c.shortRtt,
+		LongRtt:  c.longRtt,
+		Admitted: make(map[string]uint64),
+		Rejected: make(map[string]uint64),
+	}
+	for p, n := range c.admitted {
+		stats.Admitted[p.String()] = n
+	}
+	for p, n := range c.rejected {
+		stats.Rejected[p.String()] = n
+	}
+	return stats
+}
+
+// sample
+//
+// updates the limit with the rtt of a request served while inFlight requests were running.
+// Callers must hold c.mu.
+func (c *Controller) sample(rtt time.Duration, inFlight int) {
+	s := rtt.Seconds()
+	if c.longRtt == 0 {
+		c.shortRtt, c.longRtt = s, s
+	}
+	c.shortRtt += (s - c.shortRtt) / shortWindow
+	c.longRtt += (s - c.longRtt) / longWindow
+	// a long RTT far above the short one follows a past overload: let it recover faster
+	if c.longRtt > 2*c.shortRtt {
+		c.longRtt *= 0.95
+	}
+	// the latency says nothing about a limit the traffic does not reach
+	if float64(inFlight) < c.limit/2 || c.shortRtt == 0 {
+		return
+	}
+	gradient := max(0.5, min(1, tolerance*c.longRtt/c.shortRtt))
+	next := c.limit*gradient + math.Sqrt(c.limit)
+	c.limit = min(c.max, max(c.min, c.limit*(1-smoothing)+next*smoothing))
+}
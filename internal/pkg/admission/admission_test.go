@@ -0,0 +1,70 @@
+package admission
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControllerShedsLowPrioritiesFirst(t *testing.T) {
+	c := NewController(10, 1, 100)
+	for range 5 {
+		_, ok := c.Acquire(PriorityDebug)
+		require.True(t, ok)
+	}
+	_, ok := c.Acquire(PriorityDebug)
+	assert.False(t, ok)
+	for range 4 {
+		_, ok := c.Acquire(PriorityRead)
+		require.True(t, ok)
+	}
+	_, ok = c.Acquire(PriorityRead)
+	assert.False(t, ok)
+	done, ok := c.Acquire(PriorityWrite)
+	require.True(t, ok)
+	_, ok = c.Acquire(PriorityWrite)
+	assert.False(t, ok)
+
+	done()
+	done()
+	stats := c.Stats()
+	assert.Equal(t, 9, stats.InFlight)
+	assert.Equal(t, map[string]uint64{"debug": 5, "read": 4, "write": 1}, stats.Admitted)
+	assert.Equal(t, map[string]uint64{"debug": 1, "read": 1, "write": 1}, stats.Rejected)
+}
+
+func TestControllerFollowsLatency(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewController(10, 2, 20)
+	c.now = func() time.Time { return now }
+	serve := func(concurrency int, rtt time.Duration) {
+		dones := make([]func(), 0, concurrency)
+		for range concurrency {
+			done, ok := c.Acquire(PriorityWrite)
+			require.True(t, ok)
+			dones = append(dones, done)
+		}
+		now = now.Add(rtt)
+		for _, done := range dones {
+			done()
+		}
+	}
+
+	for range 20 {
+		serve(int(c.Stats().Limit), 10*time.Millisecond)
+	}
+	assert.Equal(t, 20.0, c.Stats().Limit)
+
+	// queueing: the recent latency rises well above the long-term one
+	for range 5 {
+		serve(int(c.Stats().Limit), time.Second)
+	}
+	assert.Less(t, c.Stats().Limit, 10.0)
+
+	// an idle server keeps its limit
+	limit := c.Stats().Limit
+	serve(1, time.Minute)
+	assert.Equal(t, limit, c.Stats().Limit)
+}
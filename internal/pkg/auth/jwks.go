@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"bucket_organizer/pkg/logger"
+)
+
+const (
+	defaultJWKSTTL        = time.Hour
+	jwksMinRefresh        = 30 * time.Second
+	jwksFetchTimeout      = 10 * time.Second
+	maxJWKSDocumentLength = 1 << 20
+)
+
+// JWKS
+//
+// a key set read from a file or an http(s) URL. It is reused for ttl and reloaded sooner
+// when a token names an unknown key, e.g. after the issuer rotated its keys, though at
+// most once every minRefresh, failed loads included. When a reload fails the previous set
+// is kept. A single load runs at a time: stale sets are served while reloading, only the
+// requests lacking their key wait for it.
+type JWKS struct {
+	location   string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+	mu         sync.RWMutex
+	set        *KeySet
+	// err is the error of the last load, returned as long as no set was loaded
+	err error
+	// fetchedAt is the end of the last load, successful or not
+	fetchedAt time.Time
+	// loading is closed once the load in progress ends, nil when none is
+	loading chan struct{}
+}
+
+func NewJWKS(location string, ttl time.Duration) *JWKS {
+	if ttl <= 0 {
+		ttl = defaultJWKSTTL
+	}
+	return &JWKS{location: location, ttl: ttl, minRefresh: jwksMinRefresh, client: &http.Client{Timeout: jwksFetchTimeout}}
+}
+
+// KeySet
+//
+// returns the current set, reloading it first when missing the key for kid and alg, or in
+// the background when stale.
+func (j *JWKS) KeySet(ctx context.Context, kid, alg string) (*KeySet, error) {
+	j.mu.RLock()
+	set, err, age := j.set, j.err, time.Since(j.fetchedAt)
+	j.mu.RUnlock()
+	switch {
+	case set != nil && set.Has(kid, alg):
+		if age >= j.ttl {
+			j.reload(ctx)
+		}
+		return set, nil
+	case age < j.minRefresh:
+		if set == nil {
+			return nil, err
+		}
+		return set, nil
+	}
+	select {
+	case <-j.reload(ctx):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if j.set == nil {
+		return nil, j.err
+	}
+	return j.set, nil
+}
+
+// reload
+//
+// starts loading the set unless a load is in progress, returning the channel closed once
+// the load ends.
+func (j *JWKS) reload(ctx context.Context) <-chan struct{} {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.loading != nil {
+		return j.loading
+	}
+	loading := make(chan struct{})
+	j.loading = loading
+	// shared by every waiting request, so not canceled with the one starting it
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer close(loading)
+		set, err := j.load(ctx)
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.fetchedAt, j.loading, j.err = time.Now(), nil, err
+		switch {
+		case err == nil:
+			j.set = set
+		case j.set != nil:
+			logger.Error(ctx, "error reloading JWKS, keeping the previous keys", err)
+		}
+	}()
+	return loading
+}
+
+func (j *JWKS) load(ctx context.Context) (*KeySet, error) {
+	if !strings.HasPrefix(j.location, "http://") && !strings.HasPrefix(j.location, "https://") {
+		data, err := os.ReadFile(j.location)
+		if err != nil {
+			return nil, fmt.Errorf("read JWKS: %w", err)
+		}
+		return ParseKeySet(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSDocumentLength))
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	return ParseKeySet(data)
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgHS256 = "HS256"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// JWK
+//
+// a JSON Web Key as found in a key set; only RSA, P-256 EC and symmetric keys are used.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+type verificationKey struct {
+	kid string
+	alg string
+	key any
+}
+
+// KeySet
+//
+// the verification keys of a JSON Web Key Set.
+type KeySet struct {
+	keys []verificationKey
+}
+
+// ParseKeySet
+//
+// parses a JWKS document, skipping the keys that cannot verify RS256, ES256 or HS256
+// signatures (e.g. encryption keys or other curves).
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+	set := &KeySet{}
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, alg, err := jwk.verificationKey()
+		if err != nil {
+			continue
+		}
+		if jwk.Alg != "" && jwk.Alg != alg {
+			continue
+		}
+		set.keys = append(set.keys, verificationKey{kid: jwk.Kid, alg: alg, key: key})
+	}
+	return set, nil
+}
+
+// Has
+//
+// reports whether the set holds a key for kid and alg, any key for alg when kid is empty.
+func (s *KeySet) Has(kid, alg string) bool {
+	return len(s.candidates(kid, alg)) > 0
+}
+
+func (s *KeySet) candidates(kid, alg string) []verificationKey {
+	keys := make([]verificationKey, 0, 1)
+	for _, k := range s.keys {
+		if k.alg == alg && (kid == "" || k.kid == kid) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (jwk JWK) verificationKey() (any, string, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, "", errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, AlgRS256, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, "", errors.New("unsupported curve")
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+		y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if errX != nil || errY != nil {
+			return nil, "", errors.New("invalid EC key")
+		}
+		x, y = leftPad(x, 32), leftPad(y, 32)
+		// ecdh rejects points that are not on the curve
+		if _, err := ecdh.P256().NewPublicKey(append([]byte{4}, append(x, y...)...)); err != nil {
+			return nil, "", err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, AlgES256, nil
+	case "oct":
+		k, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil || len(k) == 0 {
+			return nil, "", errors.New("invalid symmetric key")
+		}
+		return k, AlgHS256, nil
+	default:
+		return nil, "", errors.New("unsupported key type")
+	}
+}
+
+// JWTHeader
+//
+// the fields of a JOSE header used to select the verification key.
+type JWTHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// ParseJWTHeader
+//
+// decodes the header of a compact JWS without verifying anything.
+func ParseJWTHeader(token string) (JWTHeader, error) {
+	var header JWTHeader
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return header, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return header, err
+	}
+	return header, nil
+}
+
+// VerifyJWT
+//
+// checks the signature of token against the keys of set and returns its claims. Only the
+// algorithm of each key is accepted, so a token cannot pick a weaker one (or "none").
+// Registered claims such as exp are left to the caller.
+func VerifyJWT(token string, set *KeySet) (map[string]any, error) {
+	header, err := ParseJWTHeader(token)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(token, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	keys := set.candidates(header.Kid, header.Alg)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no key for kid %q and alg %q", ErrInvalidToken, header.Kid, header.Alg)
+	}
+	for _, k := range keys {
+		if verifySignature(k, signed, signature) {
+			var claims map[string]any
+			if err := decodeSegment(parts[1], &claims); err != nil {
+				return nil, err
+			}
+			return claims, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+}
+
+func verifySignature(k verificationKey, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+	switch key := k.key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS carries the raw r || s pair, not ASN.1
+		if len(signature) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key, digest[:], r, s)
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	}
+	return false
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+	return nil
+}
+
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
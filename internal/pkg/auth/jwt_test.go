@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var b64 = base64.RawURLEncoding
+
+func signTestJWT(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+	return signed + "." + b64.EncodeToString(signature)
+}
+
+func testJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey, secret []byte) []byte {
+	keys := []JWK{
+		{Kty: "RSA", Kid: "rsa", N: b64.EncodeToString(rsaKey.N.Bytes()), E: b64.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{Kty: "EC", Kid: "ec", Crv: "P-256", X: b64.EncodeToString(ecKey.X.Bytes()), Y: b64.EncodeToString(ecKey.Y.Bytes())},
+		{Kty: "oct", Kid: "hmac", K: b64.EncodeToString(secret)},
+		{Kty: "RSA", Kid: "enc", Use: "enc", N: "AQAB", E: "AQAB"},
+	}
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+func TestVerifyJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	set, err := ParseKeySet(testJWKS(t, rsaKey, ecKey, secret))
+	require.NoError(t, err)
+	claims := map[string]any{"sub": "alice"}
+
+	for _, tt := range []struct {
+		alg, kid string
+		key      any
+	}{
+		{AlgRS256, "rsa", rsaKey},
+		{AlgES256, "ec", ecKey},
+		{AlgHS256, "hmac", secret},
+		{AlgHS256, "", secret},
+	} {
+		verified, err := VerifyJWT(signTestJWT(t, tt.alg, tt.kid, tt.key, claims), set)
+		require.NoError(t, err, tt.alg)
+		assert.Equal(t, "alice", verified["sub"])
+	}
+
+	// a token cannot choose an algorithm its key was not published for
+	_, err = VerifyJWT(signTestJWT(t, AlgHS256, "rsa", rsaKey.N.Bytes(), claims), set)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = VerifyJWT(signTestJWT(t, "none", "rsa", []byte{}, claims), set)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	token := signTestJWT(t, AlgRS256, "rsa", rsaKey, claims)
+	parts := strings.Split(token, ".")
+	forged, _ := json.Marshal(map[string]any{"sub": "mallory"})
+	_, err = VerifyJWT(parts[0]+"."+b64.EncodeToString(forged)+"."+parts[2], set)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = VerifyJWT("not-a-token", set)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestJWKSReloadsForUnknownKeys(t *testing.T) {
+	first := []byte(`{"keys":[{"kty":"oct","kid":"one","k":"c2VjcmV0"}]}`)
+	second := []byte(`{"keys":[{"kty":"oct","kid":"one","k":"c2VjcmV0"},{"kty":"oct","kid":"two","k":"c2VjcmV0"}]}`)
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) == 1 {
+			_, _ = w.Write(first)
+			return
+		}
+		_, _ = w.Write(second)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	jwks := NewJWKS(srv.URL, 0)
+	set, err := jwks.KeySet(ctx, "one", AlgHS256)
+	require.NoError(t, err)
+	assert.False(t, set.Has("two", AlgHS256))
+
+	// unknown keys do not trigger a reload before minRefresh
+	_, err = jwks.KeySet(ctx, "two", AlgHS256)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	jwks.minRefresh = 0
+	set, err = jwks.KeySet(ctx, "two", AlgHS256)
+	require.NoError(t, err)
+	assert.True(t, set.Has("two", AlgHS256))
+	assert.Equal(t, int32(2), fetches.Load())
+
+	// known keys are served from the cache
+	_, err = jwks.KeySet(ctx, "one", AlgHS256)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), fetches.Load())
+
+	srv.Close()
+	set, err = jwks.KeySet(ctx, "three", AlgHS256)
+	require.NoError(t, err, "the previous set is kept when a reload fails")
+	assert.True(t, set.Has("one", AlgHS256))
+}
+
+func TestJWKSLoadsOnceAtATime(t *testing.T) {
+	keys := []byte(`{"keys":[{"kty":"oct","kid":"one","k":"c2VjcmV0"}]}`)
+	var fetches atomic.Int32
+	var down atomic.Bool
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		<-release
+		_, _ = w.Write(keys)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	// failed initial loads are retried at most once every minRefresh too
+	down.Store(true)
+	jwks := NewJWKS(srv.URL, 0)
+	_, err := jwks.KeySet(ctx, "one", AlgHS256)
+	assert.Error(t, err)
+	_, err = jwks.KeySet(ctx, "one", AlgHS256)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// the requests waiting for the keys share a single load
+	down.Store(false)
+	jwks.minRefresh = 0
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			set, err := jwks.KeySet(ctx, "one", AlgHS256)
+			assert.NoError(t, err)
+			assert.True(t, set.Has("one", AlgHS256))
+		}()
+	}
+	require.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), fetches.Load())
+
+	// stale keys are served while reloading
+	jwks.ttl = time.Nanosecond
+	down.Store(true)
+	set, err := jwks.KeySet(ctx, "one", AlgHS256)
+	require.NoError(t, err)
+	assert.True(t, set.Has("one", AlgHS256))
+	require.Eventually(t, func() bool { return fetches.Load() == 3 }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"bucket_organizer/internal/pkg/types"
+)
+
+type Effect string
+
+const (
+	EffectAllow Effect = "allow"
+	EffectDeny  Effect = "deny"
+	// EffectImplicitDeny is the decision when nothing allows a request.
+	EffectImplicitDeny Effect = "implicit-deny"
+)
+
+// Policy
+//
+// the statements attached to a bucket.
+type Policy struct {
+	Statements []Statement `json:"statements"`
+}
+
+// Statement
+//
+// allows or denies Actions on Resources to Principals, when Conditions hold.
+//
+// Principals are principal ids ("apikey:<id>", "jwt:<subject>"), roles ("role:<name>") or
+// "*" for every authenticated caller. Resources are "<bucket>" for requests on a bucket
+// and "<bucket>/<object>" for requests on an object. Principals and resources may contain
+// "*" wildcards. Like in permissions, ActionAdmin stands for every action, as does "*".
+type Statement struct {
+	Sid        string     `json:"sid,omitempty"`
+	Effect     Effect     `json:"effect"`
+	Principals []string   `json:"principals"`
+	Actions    []Action   `json:"actions"`
+	Resources  []string   `json:"resources"`
+	Conditions Conditions `json:"conditions,omitempty"`
+}
+
+// Conditions
+//
+// every non-empty condition must hold: SourceIp lists addresses or CIDR ranges the caller
+// must connect from, Prefix the prefixes the object id (or the listed prefix) must start with.
+type Conditions struct {
+	SourceIp []string `json:"sourceIp,omitempty"`
+	Prefix   []string `json:"prefix,omitempty"`
+}
+
+// Request
+//
+// an authorization request. BucketId is AllBuckets for global endpoints, which bucket
+// policies do not apply to; ObjectId is empty for requests on a bucket.
+type Request struct {
+	Principal *Principal
+	SourceIp  netip.Addr
+	Action    Action
+	BucketId  string
+	ObjectId  string
+	Prefix    string
+}
+
+func (r Request) Resource() string {
+	if r.ObjectId == "" {
+		return r.BucketId
+	}
+	return r.BucketId + "/" + r.ObjectId
+}
+
+// Decision
+//
+// the outcome of an authorization request, with the reasons that led to it.
+type Decision struct {
+	Effect  Effect   `json:"effect"`
+	Reasons []string `json:"reasons"`
+}
+
+func (d Decision) Allowed() bool {
+	return d.Effect == EffectAllow
+}
+
+// Evaluator
+//
+// decides authorization requests, see Evaluate.
+type Evaluator interface {
+	Evaluate(ctx context.Context, req Request) Decision
+}
+
+// Evaluate
+//
+// decides req given the permissions of the principal roles and the policy of the bucket
+// (nil when none). An explicit deny of the policy wins; otherwise the request is allowed by
+// the principal permissions, those of its roles or an allow statement, and denied when
+// nothing allows it.
+func Evaluate(req Request, roles map[string][]Permission, policy *Policy) Decision {
+	decision := Decision{Effect: EffectImplicitDeny, Reasons: make([]string, 0)}
+	if policy != nil && req.BucketId != AllBuckets {
+		for i, s := range policy.Statements {
+			if s.Effect == EffectDeny && s.applies(req) {
+				decision.Effect = EffectDeny
+				decision.Reasons = append(decision.Reasons, fmt.Sprintf("denied by %s of the policy of bucket %s", s.name(i), req.BucketId))
+			}
+		}
+		if decision.Effect == EffectDeny {
+			return decision
+		}
+	}
+
+	allow := func(reason string) {
+		decision.Effect = EffectAllow
+		decision.Reasons = append(decision.Reasons, reason)
+	}
+	if req.Principal.Allows(req.BucketId, req.Action) {
+		allow("allowed by the permissions of " + req.Principal.Id)
+	}
+	for _, role := range req.Principal.Roles {
+		rolePrincipal := &Principal{Permissions: roles[role]}
+		if rolePrincipal.Allows(req.BucketId, req.Action) {
+			allow("allowed by role " + role)
+		}
+	}
+	if policy != nil && req.BucketId != AllBuckets {
+		for i, s := range policy.Statements {
+			if s.Effect == EffectAllow && s.applies(req) {
+				allow(fmt.Sprintf("allowed by %s of the policy of bucket %s", s.name(i), req.BucketId))
+			}
+		}
+	}
+	if decision.Effect == EffectImplicitDeny {
+		decision.Reasons = append(decision.Reasons, fmt.Sprintf("nothing allows %s on %s to %s", req.Action, req.Resource(), req.Principal.Id))
+	}
+	return decision
+}
+
+func (s Statement) name(i int) string {
+	if s.Sid != "" {
+		return fmt.Sprintf("statement %q", s.Sid)
+	}
+	return fmt.Sprintf("statement %d", i)
+}
+
+func (s Statement) applies(req Request) bool {
+	return s.matchesPrincipal(req.Principal) &&
+		(slices.Contains(s.Actions, req.Action) || slices.Contains(s.Actions, ActionAdmin) || slices.Contains(s.Actions, "*")) &&
+		slices.ContainsFunc(s.Resources, func(pattern string) bool { return wildcardMatch(pattern, req.Resource()) }) &&
+		s.Conditions.hold(req)
+}
+
+func (s Statement) matchesPrincipal(p *Principal) bool {
+	for _, pattern := range s.Principals {
+		if role, ok := strings.CutPrefix(pattern, "role:"); ok {
+			if slices.ContainsFunc(p.Roles, func(r string) bool { return wildcardMatch(role, r) }) {
+				return true
+			}
+			continue
+		}
+		if wildcardMatch(pattern, p.Id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Conditions) hold(req Request) bool {
+	if len(c.SourceIp) > 0 && !slices.ContainsFunc(c.SourceIp, func(cidr string) bool { return containsAddr(cidr, req.SourceIp) }) {
+		return false
+	}
+	if len(c.Prefix) > 0 {
+		subject := req.ObjectId
+		if subject == "" {
+			subject = req.Prefix
+		}
+		if !slices.ContainsFunc(c.Prefix, func(prefix string) bool { return subject != "" && strings.HasPrefix(subject, prefix) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAddr(cidr string, addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	if prefix, err := netip.ParsePrefix(cidr); err == nil {
+		return prefix.Contains(addr)
+	}
+	if ip, err := netip.ParseAddr(cidr); err == nil {
+		return ip.Unmap() == addr
+	}
+	return false
+}
+
+// wildcardMatch
+//
+// matches s against pattern, where "*" matches any sequence of characters, "/" included.
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+// ValidatePolicy
+//
+// reports the malformed statements of policy as invalid params.
+func ValidatePolicy(policy Policy) []types.InvalidParam {
+	params := make([]types.InvalidParam, 0)
+	for i, s := range policy.Statements {
+		name := fmt.Sprintf("statements[%d]", i)
+		report := func(reason string) {
+			params = append(params, types.InvalidParam{Name: name, Reason: reason})
+		}
+		if s.Effect != EffectAllow && s.Effect != EffectDeny {
+			report("effect must be allow or deny")
+		}
+		if len(s.Principals) == 0 {
+			report("at least one principal is required")
+		}
+		if len(s.Resources) == 0 {
+			report("at least one resource is required")
+		}
+		if len(s.Actions) == 0 {
+			report("at least one action is required")
+		}
+		for _, action := range s.Actions {
+			if action != "*" && !slices.Contains(Actions, action) {
+				report("unknown action " + string(action))
+			}
+		}
+		for _, cidr := range s.Conditions.SourceIp {
+			if _, err := netip.ParsePrefix(cidr); err != nil {
+				if _, err := netip.ParseAddr(cidr); err != nil {
+					report("invalid sourceIp " + cidr)
+				}
+			}
+		}
+	}
+	return params
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluate(t *testing.T) {
+	reader := &Principal{Id: "apikey:reader", Roles: []string{"auditors"}}
+	roles := map[string][]Permission{"auditors": {{Bucket: "logs", Actions: []Action{ActionRead}}}}
+	policy := &Policy{Statements: []Statement{
+		{
+			Sid:        "deny-secrets",
+			Effect:     EffectDeny,
+			Principals: []string{"*"},
+			Actions:    []Action{"*"},
+			Resources:  []string{"logs/secret-*"},
+		},
+		{
+			Effect:     EffectAllow,
+			Principals: []string{"role:audit*"},
+			Actions:    []Action{ActionWrite},
+			Resources:  []string{"logs/*"},
+			Conditions: Conditions{SourceIp: []string{"10.0.0.0/8"}, Prefix: []string{"reports/"}},
+		},
+	}}
+	office := netip.MustParseAddr("10.1.2.3")
+
+	tests := []struct {
+		name   string
+		req    Request
+		effect Effect
+	}{
+		{"role permission", Request{Action: ActionRead, BucketId: "logs", ObjectId: "a"}, EffectAllow},
+		{"explicit deny wins", Request{Action: ActionRead, BucketId: "logs", ObjectId: "secret-1"}, EffectDeny},
+		{"conditions hold", Request{Action: ActionWrite, BucketId: "logs", ObjectId: "reports/q1", SourceIp: office}, EffectAllow},
+		{"wrong source ip", Request{Action: ActionWrite, BucketId: "logs", ObjectId: "reports/q1", SourceIp: netip.MustParseAddr("192.0.2.1")}, EffectImplicitDeny},
+		{"wrong prefix", Request{Action: ActionWrite, BucketId: "logs", ObjectId: "other", SourceIp: office}, EffectImplicitDeny},
+		{"other bucket", Request{Action: ActionRead, BucketId: "other"}, EffectImplicitDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Principal = reader
+			decision := Evaluate(tt.req, roles, policy)
+			assert.Equal(t, tt.effect, decision.Effect)
+			assert.NotEmpty(t, decision.Reasons)
+		})
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	assert.Empty(t, ValidatePolicy(Policy{Statements: []Statement{{
+		Effect: EffectAllow, Principals: []string{"*"}, Actions: []Action{ActionRead}, Resources: []string{"logs"},
+	}}}))
+	params := ValidatePolicy(Policy{Statements: []Statement{{
+		Effect: "maybe", Actions: []Action{"fly"}, Conditions: Conditions{SourceIp: []string{"nowhere"}},
+	}}})
+	assert.Len(t, params, 5)
+}
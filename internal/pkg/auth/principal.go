@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"bucket_organizer/internal/pkg/tenant"
+	"bucket_organizer/internal/pkg/types"
+)
+
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionWrite  Action = "write"
+	ActionDelete Action = "delete"
+	// ActionAdmin grants every action, plus the administrative ones.
+	ActionAdmin Action = "admin"
+)
+
+var Actions = []Action{ActionRead, ActionWrite, ActionDelete, ActionAdmin}
+
+// AllBuckets
+//
+// as Permission.Bucket matches every bucket; as the bucket passed to Allows it asks for a
+// permission covering every bucket, as needed by global endpoints.
+const AllBuckets = "*"
+
+// Permission
+//
+// grants actions on Bucket: an id, a prefix followed by "*" or AllBuckets.
+type Permission struct {
+	Bucket  string   `json:"bucket"`
+	Actions []Action `json:"actions"`
+}
+
+func (p Permission) matches(bucketId string) bool {
+	if p.Bucket == AllBuckets {
+		return true
+	}
+	if bucketId == AllBuckets {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(p.Bucket, "*"); ok {
+		return strings.HasPrefix(bucketId, prefix)
+	}
+	return p.Bucket == bucketId
+}
+
+// Principal
+//
+// the authenticated caller of a request. Roles grant the permissions they group, resolved
+// at evaluation time. Tenant is the tenant the principal belongs to (tenant.Default when
+// empty); Tenants explicitly grants access to other tenants, by id or "*" wildcard pattern.
+type Principal struct {
+	Id          string       `json:"id"`
+	Name        string       `json:"name,omitempty"`
+	Tenant      string       `json:"tenant,omitempty"`
+	Tenants     []string     `json:"tenants,omitempty"`
+	Roles       []string     `json:"roles,omitempty"`
+	Permissions []Permission `json:"permissions,omitempty"`
+}
+
+func (p *Principal) HomeTenant() string {
+	if p.Tenant == "" {
+		return tenant.Default
+	}
+	return p.Tenant
+}
+
+// CanAccessTenant
+//
+// whether the principal may act within tenantId, its own tenant or a granted one.
+func (p *Principal) CanAccessTenant(tenantId string) bool {
+	if tenantId == p.HomeTenant() {
+		return true
+	}
+	return slices.ContainsFunc(p.Tenants, func(pattern string) bool { return wildcardMatch(pattern, tenantId) })
+}
+
+func (p *Principal) Allows(bucketId string, action Action) bool {
+	for _, permission := range p.Permissions {
+		if !permission.matches(bucketId) {
+			continue
+		}
+		if slices.Contains(permission.Actions, action) || slices.Contains(permission.Actions, ActionAdmin) {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+type evaluatorKey struct{}
+
+type sourceIpKey struct{}
+
+// WithEvaluator
+//
+// makes Authorize decide with e, which accounts for roles and bucket policies, instead of
+// the principal permissions alone.
+func WithEvaluator(ctx context.Context, e Evaluator) context.Context {
+	return context.WithValue(ctx, evaluatorKey{}, e)
+}
+
+// WithSourceIp
+//
+// records the address the request comes from, for the sourceIp policy condition.
+func WithSourceIp(ctx context.Context, addr netip.Addr) context.Context {
+	return context.WithValue(ctx, sourceIpKey{}, addr)
+}
+
+// Authorize
+//
+// fails with types.ErrForbidden unless the principal of ctx may perform action on bucketId.
+// Without a principal, i.e. with authentication disabled, everything is allowed.
+func Authorize(ctx context.Context, bucketId string, action Action) error {
+	return AuthorizeRequest(ctx, Request{BucketId: bucketId, Action: action})
+}
+
+// AuthorizeRequest
+//
+// like Authorize, for requests on an object or a prefix. The principal and source address
+// of req are taken from ctx.
+func AuthorizeRequest(ctx context.Context, req Request) error {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	req.Principal = p
+	req.SourceIp, _ = ctx.Value(sourceIpKey{}).(netip.Addr)
+	var decision Decision
+	if e, ok := ctx.Value(evaluatorKey{}).(Evaluator); ok {
+		decision = e.Evaluate(ctx, req)
+	} else {
+		decision = Evaluate(req, nil, nil)
+	}
+	if !decision.Allowed() {
+		return types.ErrForbidden
+	}
+	return nil
+}
+
+// ValidatePermissions
+//
+// reports empty buckets and unknown actions as invalid params named after field.
+func ValidatePermissions(field string, permissions []Permission) []types.InvalidParam {
+	params := make([]types.InvalidParam, 0)
+	if len(permissions) == 0 {
+		params = append(params, types.InvalidParam{Name: field, Reason: "at least one permission is required"})
+	}
+	for _, p := range permissions {
+		if p.Bucket == "" {
+			params = append(params, types.InvalidParam{Name: field, Reason: "bucket is required"})
+		}
+		if len(p.Actions) == 0 {
+			params = append(params, types.InvalidParam{Name: field, Reason: "at least one action is required"})
+		}
+		for _, action := range p.Actions {
+			if !slices.Contains(Actions, action) {
+				params = append(params, types.InvalidParam{Name: field, Reason: "unknown action " + string(action)})
+			}
+		}
+	}
+	return params
+}
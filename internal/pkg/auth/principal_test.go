@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"bucket_organizer/internal/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipalAllows(t *testing.T) {
+	p := &Principal{Permissions: []Permission{
+		{Bucket: "logs", Actions: []Action{ActionRead, ActionWrite}},
+		{Bucket: "team-*", Actions: []Action{ActionAdmin}},
+	}}
+	tests := []struct {
+		bucketId string
+		action   Action
+		allowed  bool
+	}{
+		{"logs", ActionRead, true},
+		{"logs", ActionWrite, true},
+		{"logs", ActionDelete, false},
+		{"logs", ActionAdmin, false},
+		{"team-a", ActionDelete, true},
+		{"team-a", ActionAdmin, true},
+		{"other", ActionRead, false},
+		{AllBuckets, ActionRead, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, p.Allows(tt.bucketId, tt.action), "%s %s", tt.action, tt.bucketId)
+	}
+
+	global := &Principal{Permissions: []Permission{{Bucket: AllBuckets, Actions: []Action{ActionRead}}}}
+	assert.True(t, global.Allows(AllBuckets, ActionRead))
+	assert.True(t, global.Allows("logs", ActionRead))
+	assert.False(t, global.Allows("logs", ActionWrite))
+}
+
+func TestAuthorize(t *testing.T) {
+	ctx := context.Background()
+	assert.NoError(t, Authorize(ctx, "logs", ActionAdmin))
+
+	ctx = WithPrincipal(ctx, &Principal{Permissions: []Permission{{Bucket: "logs", Actions: []Action{ActionRead}}}})
+	assert.NoError(t, Authorize(ctx, "logs", ActionRead))
+	assert.ErrorIs(t, Authorize(ctx, "logs", ActionWrite), types.ErrForbidden)
+}
+
+func TestValidatePermissions(t *testing.T) {
+	assert.Empty(t, ValidatePermissions("permissions", []Permission{{Bucket: "logs", Actions: []Action{ActionRead}}}))
+	assert.Len(t, ValidatePermissions("permissions", nil), 1)
+	assert.Len(t, ValidatePermissions("permissions", []Permission{{Actions: []Action{"fly"}}}), 2)
+}
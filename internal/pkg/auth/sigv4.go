@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SigV4Algorithm  = "AWS4-HMAC-SHA256"
+	SigV4Service    = "s3"
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// SigV4MaxExpires is the longest validity of a presigned URL, as on AWS.
+	SigV4MaxExpires = 7 * 24 * time.Hour
+
+	sigV4DateFormat    = "20060102T150405Z"
+	sigV4ScopeFormat   = "20060102"
+	sigV4Terminator    = "aws4_request"
+	sigV4MaxClockSkew  = 15 * time.Minute
+	amzDateHeader      = "X-Amz-Date"
+	amzContentHeader   = "X-Amz-Content-Sha256"
+	amzSignatureParam  = "X-Amz-Signature"
+	amzAlgorithmParam  = "X-Amz-Algorithm"
+	amzCredentialParam = "X-Amz-Credential"
+	amzExpiresParam    = "X-Amz-Expires"
+	amzSignedParam     = "X-Amz-SignedHeaders"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// SigV4Request
+//
+// the signature of a request signed with AWS Signature Version 4, carried either by the
+// Authorization header or, for presigned URLs, by the query string.
+type SigV4Request struct {
+	Date          time.Time
+	AccessKeyId   string
+	Region        string
+	Service       string
+	Signature     string
+	SignedHeaders []string
+	// Expires is only set for presigned URLs.
+	Expires time.Duration
+}
+
+func (s *SigV4Request) Presigned() bool {
+	return s.Expires > 0
+}
+
+// ParseSigV4
+//
+// extracts the signature of r, returning nil, nil when r is not signed with SigV4.
+func ParseSigV4(r *http.Request) (*SigV4Request, error) {
+	query := r.URL.Query()
+	if query.Has(amzAlgorithmParam) {
+		return parsePresigned(query)
+	}
+	header := r.Header.Get("Authorization")
+	params, ok := strings.CutPrefix(header, SigV4Algorithm+" ")
+	if !ok {
+		return nil, nil
+	}
+	fields := make(map[string]string)
+	for _, field := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		fields[key] = value
+	}
+	date, err := time.Parse(sigV4DateFormat, r.Header.Get(amzDateHeader))
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing or malformed %s", ErrInvalidSignature, amzDateHeader)
+	}
+	s := &SigV4Request{Date: date, Signature: fields["Signature"], SignedHeaders: strings.Split(fields["SignedHeaders"], ";")}
+	if err := s.parseCredential(fields["Credential"]); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(s.SignedHeaders, strings.ToLower(amzDateHeader)) {
+		return nil, fmt.Errorf("%w: %s must be signed", ErrInvalidSignature, amzDateHeader)
+	}
+	return s, s.validate()
+}
+
+func parsePresigned(query url.Values) (*SigV4Request, error) {
+	if query.Get(amzAlgorithmParam) != SigV4Algorithm {
+		return nil, fmt.Errorf("%w: unsupported algorithm", ErrInvalidSignature)
+	}
+	date, err := time.Parse(sigV4DateFormat, query.Get(amzDateHeader))
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing or malformed %s", ErrInvalidSignature, amzDateHeader)
+	}
+	expires, err := strconv.Atoi(query.Get(amzExpiresParam))
+	if err != nil || expires <= 0 || time.Duration(expires)*time.Second > SigV4MaxExpires {
+		return nil, fmt.Errorf("%w: %s must be between 1 and %d seconds", ErrInvalidSignature, amzExpiresParam, int(SigV4MaxExpires.Seconds()))
+	}
+	s := &SigV4Request{
+		Date:          date,
+		Signature:     query.Get(amzSignatureParam),
+		SignedHeaders: strings.Split(query.Get(amzSignedParam), ";"),
+		Expires:       time.Duration(expires) * time.Second,
+	}
+	if err := s.parseCredential(query.Get(amzCredentialParam)); err != nil {
+		return nil, err
+	}
+	return s, s.validate()
+}
+
+// parseCredential
+//
+// reads <access key id>/<date>/<region>/<service>/aws4_request.
+func (s *SigV4Request) parseCredential(credential string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[0] == "" || parts[4] != sigV4Terminator {
+		return fmt.Errorf("%w: malformed credential", ErrInvalidSignature)
+	}
+	if parts[1] != s.Date.Format(sigV4ScopeFormat) {
+		return fmt.Errorf("%w: credential date does not match %s", ErrInvalidSignature, amzDateHeader)
+	}
+	s.AccessKeyId, s.Region, s.Service = parts[0], parts[2], parts[3]
+	return nil
+}
+
+func (s *SigV4Request) validate() error {
+	if s.Signature == "" {
+		return fmt.Errorf("%w: missing signature", ErrInvalidSignature)
+	}
+	if !slices.Contains(s.SignedHeaders, "host") {
+		return fmt.Errorf("%w: host must be signed", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// VerifySigV4
+//
+// checks that s signs r with secret for region, and that it is valid at now. Outside
+// presigned URLs the payload hash is checked against the body, unless declared unsigned.
+func VerifySigV4(r *http.Request, s *SigV4Request, secret, region string, now time.Time) error {
+	if s.Region != region || s.Service != SigV4Service {
+		return fmt.Errorf("%w: credential scope must be %s/%s", ErrInvalidSignature, region, SigV4Service)
+	}
+	if s.Presigned() {
+		if now.Add(sigV4MaxClockSkew).Before(s.Date) {
+			return fmt.Errorf("%w: not yet valid", ErrInvalidSignature)
+		}
+		if now.After(s.Date.Add(s.Expires)) {
+			return fmt.Errorf("%w: expired", ErrInvalidSignature)
+		}
+	} else if now.Sub(s.Date).Abs() > sigV4MaxClockSkew {
+		return fmt.Errorf("%w: request time too skewed", ErrInvalidSignature)
+	}
+
+	payloadHash := UnsignedPayload
+	if !s.Presigned() {
+		var err error
+		if payloadHash, err = bodyHash(r); err != nil {
+			return err
+		}
+	}
+	expected := sigV4Signature(secret, s, canonicalRequest(r, r.Method, s.SignedHeaders, payloadHash))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(s.Signature))) {
+		return fmt.Errorf("%w: signature does not match", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// bodyHash
+//
+// the payload hash covered by the signature: the declared X-Amz-Content-Sha256, checked
+// against the body unless UNSIGNED-PAYLOAD, or the hash of the body. The body is restored
+// for the next handler.
+func bodyHash(r *http.Request) (string, error) {
+	declared := r.Header.Get(amzContentHeader)
+	if declared == UnsignedPayload {
+		return declared, nil
+	}
+	body := []byte{}
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	sum := sha256.Sum256(body)
+	actual := hex.EncodeToString(sum[:])
+	if declared != "" && !strings.EqualFold(declared, actual) {
+		return "", fmt.Errorf("%w: %s does not match the body", ErrInvalidSignature, amzContentHeader)
+	}
+	return actual, nil
+}
+
+// PresignSigV4
+//
+// returns u with the query parameters of a presigned URL letting method be called for
+// expires from t, signed with accessKeyId and secret. Only the host header is signed.
+func PresignSigV4(method string, u *url.URL, accessKeyId, secret, region string, t time.Time, expires time.Duration) *url.URL {
+	t = t.UTC()
+	s := &SigV4Request{Date: t, AccessKeyId: accessKeyId, Region: region, Service: SigV4Service, SignedHeaders: []string{"host"}, Expires: expires}
+	signed := *u
+	query := signed.Query()
+	query.Set(amzAlgorithmParam, SigV4Algorithm)
+	query.Set(amzCredentialParam, s.credential())
+	query.Set(amzDateHeader, t.Format(sigV4DateFormat))
+	query.Set(amzExpiresParam, strconv.Itoa(int(expires.Seconds())))
+	query.Set(amzSignedParam, "host")
+	signed.RawQuery = query.Encode()
+	r := &http.Request{Method: method, URL: &signed, Host: signed.Host, Header: http.Header{}}
+	query.Set(amzSignatureParam, sigV4Signature(secret, s, canonicalRequest(r, method, s.SignedHeaders, UnsignedPayload)))
+	signed.RawQuery = query.Encode()
+	return &signed
+}
+
+// SignSigV4
+//
+// signs r in place with an Authorization header covering host, X-Amz-Date and
+// X-Amz-Content-Sha256, as AWS clients do.
+func SignSigV4(r *http.Request, accessKeyId, secret, region string, t time.Time) error {
+	t = t.UTC()
+	r.Header.Set(amzDateHeader, t.Format(sigV4DateFormat))
+	r.Header.Del(amzContentHeader)
+	payloadHash, err := bodyHash(r)
+	if err != nil {
+		return err
+	}
+	r.Header.Set(amzContentHeader, payloadHash)
+	s := &SigV4Request{
+		Date:          t,
+		AccessKeyId:   accessKeyId,
+		Region:        region,
+		Service:       SigV4Service,
+		SignedHeaders: []string{"host", strings.ToLower(amzContentHeader), strings.ToLower(amzDateHeader)},
+	}
+	signature := sigV4Signature(secret, s, canonicalRequest(r, r.Method, s.SignedHeaders, payloadHash))
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s, SignedHeaders=%s, Signature=%s",
+		SigV4Algorithm, s.credential(), strings.Join(s.SignedHeaders, ";"), signature))
+	return nil
+}
+
+func (s *SigV4Request) scope() string {
+	return strings.Join([]string{s.Date.Format(sigV4ScopeFormat), s.Region, s.Service, sigV4Terminator}, "/")
+}
+
+func (s *SigV4Request) credential() string {
+	return s.AccessKeyId + "/" + s.scope()
+}
+
+func sigV4Signature(secret string, s *SigV4Request, canonical string) string {
+	digest := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{SigV4Algorithm, s.Date.Format(sigV4DateFormat), s.scope(), hex.EncodeToString(digest[:])}, "\n")
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{s.Date.Format(sigV4ScopeFormat), s.Region, s.Service, sigV4Terminator} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func canonicalRequest(r *http.Request, method string, signedHeaders []string, payloadHash string) string {
+	segments := strings.Split(r.URL.Path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	path := strings.Join(segments, "/")
+	if path == "" {
+		path = "/"
+	}
+
+	pairs := make([][2]string, 0)
+	for key, values := range r.URL.Query() {
+		if key == amzSignatureParam {
+			continue
+		}
+		for _, value := range values {
+			pairs = append(pairs, [2]string{uriEncode(key), uriEncode(value)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	query := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		query = append(query, pair[0]+"="+pair[1])
+	}
+
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		value := strings.Join(r.Header.Values(name), ",")
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	return strings.Join([]string{method, path, strings.Join(query, "&"), headers.String(), strings.Join(signedHeaders, ";"), payloadHash}, "\n")
+}
+
+// uriEncode
+//
+// percent-encodes everything but the RFC 3986 unreserved characters, as SigV4 requires.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -1,115 +1,238 @@
 package configs
 
-import (
-	"errors"
-	"fmt"
-	"os"
-	"reflect"
-	"strconv"
-	"time"
-)
-
-var config *Config
+import "net/netip"
 
+// Global
+//
+// the current configuration; it is replaced, never modified, on reload.
 func Global() *Config {
-	return config
+	if l := current.Load(); l != nil {
+		return l.config
+	}
+	return nil
 }
 
+// Config
+//
+// The configuration is reloaded on SIGHUP and when the configuration file changes, checking
+// every ReloadInterval seconds (0 disables the check). Fields tagged reload:"live" take
+// effect at once; changes to the others are reported and wait for a restart.
 type Config struct {
-	Logger      Logger
-	Environment string `env:"ENV"`
-	ServiceName string `env:"SERVICE_NAME"`
-	Server      Server
-}
-
-func IsDevelopment() bool {
-	return config.Environment == "prod" || config.Environment == "production"
+	Logger         Logger
+	Environment    Profile `env:"ENV" default:"production"`
+	ServiceName    string  `env:"SERVICE_NAME" default:"bucket_organizer" required:"true"`
+	ReloadInterval int     `env:"CONFIG_RELOAD_INTERVAL" default:"10" min:"0"`
+	Server         Server
+	Trash          Trash
+	Batch          Batch
+	Idempotency    Idempotency
+	Changes        Changes
+	Watch          Watch
+	Webhooks       Webhooks
+	Publish        Publish
+	Auth           Auth
+	RateLimit      RateLimit
+	Admission      Admission
+	Tracing        Tracing
+	Health         Health
 }
 
+// Server
+//
+// CorsOrigins lists the origins allowed to call the API from a browser, "*" allowing any;
+// when empty, those of the profile, any in development and test, none otherwise.
 type Server struct {
-	Port     int `env:"SERVER_PORT"`
-	Timeouts Timeouts
+	Port        int `env:"SERVER_PORT" default:"8080" min:"1" max:"65535"`
+	Timeouts    Timeouts
+	CorsOrigins []string `env:"SERVER_CORS_ORIGINS" reload:"live"`
 }
 
 type Timeouts struct {
-	Read        int `env:"SERVER_TIMEOUT_READ"`
-	Write       int `env:"SERVER_TIMEOUT_WRITE"`
-	Shutdown    int `env:"SERVER_TIMEOUT_SHUTDOWN"`
-	ReadHeaders int `env:"SERVER_TIMEOUT_READ_HEADERS"`
-	Idle        int `env:"SERVER_TIMEOUT_IDLE"`
+	Read        int `env:"SERVER_TIMEOUT_READ" default:"30" min:"0"`
+	Write       int `env:"SERVER_TIMEOUT_WRITE" default:"30" min:"0"`
+	Shutdown    int `env:"SERVER_TIMEOUT_SHUTDOWN" default:"10" min:"0"`
+	ReadHeaders int `env:"SERVER_TIMEOUT_READ_HEADERS" default:"30" min:"0"`
+	Idle        int `env:"SERVER_TIMEOUT_IDLE" default:"30" min:"0"`
 }
 
-type Logger struct {
-	Level string `env:"LOG_LEVEL"`
+// Trash
+//
+// Retention and PurgeInterval are expressed in seconds, like Timeouts.
+type Trash struct {
+	Enabled       bool `env:"TRASH_ENABLED" reload:"live"`
+	Retention     int  `env:"TRASH_RETENTION" default:"604800" reload:"live"`
+	PurgeInterval int  `env:"TRASH_PURGE_INTERVAL" default:"60"`
 }
 
-func LoadConfig() error {
-	config = &Config{}
-	return populateStruct(config)
+type Batch struct {
+	Concurrency   int `env:"BATCH_CONCURRENCY" default:"8" min:"1"`
+	MaxOperations int `env:"BATCH_MAX_OPERATIONS" default:"1000" min:"1"`
 }
 
-func populateStruct(s interface{}) error {
-	durationType := reflect.TypeOf(time.Duration(0))
-	v := reflect.ValueOf(s)
-	if v.Kind() != reflect.Ptr || v.IsNil() {
-		return errors.New("expected a non-nil pointer to struct")
-	}
+// Idempotency
+//
+// TTL is expressed in seconds. MaxBodySize (bytes) caps the bodies of the requests carrying
+// an idempotency key, which are read whole to fingerprint them.
+type Idempotency struct {
+	TTL         int   `env:"IDEMPOTENCY_TTL" default:"86400"`
+	MaxBodySize int64 `env:"IDEMPOTENCY_MAX_BODY_SIZE" default:"1048576" min:"1"`
+}
 
-	v = v.Elem()
-	if v.Kind() != reflect.Struct {
-		return errors.New("expected a pointer to struct")
-	}
+// Changes
+//
+// File enables the durable change log; MaxWait (seconds) caps long polling. MaxEvents bounds
+// the events kept, the oldest being purged beyond it (0 keeps them all): consumers resuming
+// from a purged sequence continue from the oldest event kept.
+type Changes struct {
+	File      string `env:"CHANGES_FILE"`
+	MaxWait   int    `env:"CHANGES_MAX_WAIT" default:"30"`
+	MaxEvents int    `env:"CHANGES_MAX_EVENTS" default:"1000000" min:"0"`
+}
 
-	t := v.Type()
-
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldType := t.Field(i)
-
-		if field.Kind() == reflect.Struct {
-			if err := populateStruct(field.Addr().Interface()); err != nil {
-				return err
-			}
-			continue
-		}
-
-		envKey := fieldType.Tag.Get("env")
-		if envKey == "" {
-			continue
-		}
-
-		envVal, ok := os.LookupEnv(envKey)
-		if !ok {
-			continue
-		}
-
-		if field.Type() == durationType {
-			d, err := time.ParseDuration(envVal)
-			if err != nil {
-				return fmt.Errorf("invalid duration for %s: %v", envKey, err)
-			}
-			field.Set(reflect.ValueOf(d))
-			continue
-		}
-
-		switch field.Kind() {
-		case reflect.String:
-			field.SetString(envVal)
-		case reflect.Int:
-			val, err := strconv.Atoi(envVal)
-			if err != nil {
-				return fmt.Errorf("invalid int for %s: %v", envKey, err)
-			}
-			field.SetInt(int64(val))
-		case reflect.Bool:
-			val, err := strconv.ParseBool(envVal)
-			if err != nil {
-				return fmt.Errorf("invalid bool for %s: %v", envKey, err)
-			}
-			field.SetBool(val)
-		default:
-			return fmt.Errorf("unsupported kind %s for field %s", field.Kind(), fieldType.Name)
-		}
-	}
-	return nil
+// Watch
+//
+// Buffer is the number of changes queued per watcher before it is dropped,
+// Heartbeat (seconds) the keep-alive interval of idle streams.
+type Watch struct {
+	Buffer    int `env:"WATCH_BUFFER" default:"256" min:"1"`
+	Heartbeat int `env:"WATCH_HEARTBEAT" default:"15"`
+}
+
+// Webhooks
+//
+// File enables the durable webhook outbox and requires Changes.File. Failed deliveries are retried up to
+// MaxAttempts times, waiting Backoff seconds doubled at every attempt up to MaxBackoff;
+// Timeout (seconds) bounds a single attempt and LogRetention how long finished
+// deliveries stay in the delivery log. Receivers must have public addresses, unless in one
+// of AllowedNetworks, e.g. 10.0.0.0/8.
+type Webhooks struct {
+	File            string         `env:"WEBHOOKS_FILE"`
+	MaxAttempts     int            `env:"WEBHOOKS_MAX_ATTEMPTS" default:"8" min:"1"`
+	Backoff         int            `env:"WEBHOOKS_BACKOFF" default:"1" atMost:"MaxBackoff"`
+	MaxBackoff      int            `env:"WEBHOOKS_MAX_BACKOFF" default:"3600"`
+	Timeout         int            `env:"WEBHOOKS_TIMEOUT" default:"10"`
+	Concurrency     int            `env:"WEBHOOKS_CONCURRENCY" default:"4" min:"1"`
+	LogRetention    int            `env:"WEBHOOKS_LOG_RETENTION" default:"604800"`
+	AllowedNetworks []netip.Prefix `env:"WEBHOOKS_ALLOWED_NETWORKS"`
+}
+
+// Publish
+//
+// Driver selects where committed changes are published: "channel", "nats" or "file"; empty
+// disables publishing. Url is the NATS server (nats://[user:pass@]host[:port]), Subject the
+// subject prefix and File the NDJSON sink. CursorFile keeps the relay position across
+// restarts and requires Changes.File; Timeout (seconds) bounds a NATS round trip.
+type Publish struct {
+	Driver     string `env:"PUBLISH_DRIVER" oneof:"channel nats file"`
+	Url        Secret `env:"PUBLISH_URL" secret:"true"`
+	Subject    string `env:"PUBLISH_SUBJECT" default:"buckets"`
+	File       string `env:"PUBLISH_FILE"`
+	CursorFile string `env:"PUBLISH_CURSOR_FILE"`
+	Timeout    int    `env:"PUBLISH_TIMEOUT" default:"10"`
+}
+
+// Auth
+//
+// Enabled requires credentials, an API key, a SigV4 signature or a JWT, on every endpoint;
+// it must be set outside the development and test environments, and the administration
+// endpoints are not served without it.
+// AdminKeyHash is the hex SHA-256 of a bootstrap key holding every permission, so that no
+// plaintext secret sits in the environment; KeysFile makes the issued keys durable, and
+// PoliciesFile the bucket policies and roles.
+// SigningSecret derives the secret access keys signing requests with AWS Signature Version 4
+// (a random one is generated when empty, so signatures do not survive restarts) and Region
+// is the SigV4 region, us-east-1 by default.
+type Auth struct {
+	Enabled       bool   `env:"AUTH_ENABLED"`
+	AdminKeyHash  string `env:"AUTH_ADMIN_KEY_HASH"`
+	KeysFile      string `env:"AUTH_KEYS_FILE"`
+	PoliciesFile  string `env:"AUTH_POLICIES_FILE"`
+	SigningSecret Secret `env:"AUTH_SIGNING_SECRET" secret:"true"`
+	Region        string `env:"AUTH_REGION" default:"us-east-1"`
+	JWT           JWT
+}
+
+// JWT
+//
+// JWKS is the path or http(s) URL of the key set verifying bearer tokens; empty disables
+// JWT authentication. Tokens must be issued by Issuer and for Audience when set. Leeway
+// (seconds) tolerates clock skew, JWKSRefresh (seconds) is how long a loaded key set is
+// reused, PermissionsClaim names the claim mapped to bucket permissions, RolesClaim the
+// one listing the roles of the subject and TenantClaim the one naming its tenant.
+type JWT struct {
+	JWKS             string `env:"AUTH_JWT_JWKS"`
+	Issuer           string `env:"AUTH_JWT_ISSUER"`
+	Audience         string `env:"AUTH_JWT_AUDIENCE"`
+	Leeway           int    `env:"AUTH_JWT_LEEWAY" default:"60"`
+	JWKSRefresh      int    `env:"AUTH_JWT_JWKS_REFRESH" default:"3600"`
+	PermissionsClaim string `env:"AUTH_JWT_PERMISSIONS_CLAIM" default:"permissions"`
+	RolesClaim       string `env:"AUTH_JWT_ROLES_CLAIM" default:"roles"`
+	TenantClaim      string `env:"AUTH_JWT_TENANT_CLAIM" default:"tenant"`
+}
+
+// RateLimit
+//
+// Requests are limited per client, identified according to Key: "client" (the API key or
+// token subject, the client IP for anonymous requests), "tenant" or "ip". ReadRate and
+// WriteRate (requests per second, 0 disables) refill token buckets holding ReadBurst and
+// WriteBurst requests, for safe and unsafe methods; ReadConcurrency and WriteConcurrency cap
+// the requests in flight. AuthFailureRate and AuthFailureBurst limit the failed
+// authentications of every client IP, anonymous requests being charged before authenticating.
+// File overrides these limits and sets per-route ones; it is reloaded when changed, checking
+// every ReloadInterval seconds.
+type RateLimit struct {
+	Key              string  `env:"RATELIMIT_KEY" default:"client" oneof:"client tenant ip" reload:"live"`
+	ReadRate         float64 `env:"RATELIMIT_READ_RATE" min:"0" reload:"live"`
+	ReadBurst        int     `env:"RATELIMIT_READ_BURST" min:"0" reload:"live"`
+	ReadConcurrency  int     `env:"RATELIMIT_READ_CONCURRENCY" min:"0" reload:"live"`
+	WriteRate        float64 `env:"RATELIMIT_WRITE_RATE" min:"0" reload:"live"`
+	WriteBurst       int     `env:"RATELIMIT_WRITE_BURST" min:"0" reload:"live"`
+	WriteConcurrency int     `env:"RATELIMIT_WRITE_CONCURRENCY" min:"0" reload:"live"`
+	AuthFailureRate  float64 `env:"RATELIMIT_AUTH_FAILURE_RATE" default:"1" min:"0" reload:"live"`
+	AuthFailureBurst int     `env:"RATELIMIT_AUTH_FAILURE_BURST" default:"20" min:"0" reload:"live"`
+	File             string  `env:"RATELIMIT_FILE" reload:"live"`
+	ReloadInterval   int     `env:"RATELIMIT_RELOAD_INTERVAL" default:"10"`
+}
+
+// Admission
+//
+// Enabled sheds load beyond an adaptive limit of requests in flight, starting at InitialLimit
+// and kept between MinLimit and MaxLimit as the latency of the requests rises or falls.
+type Admission struct {
+	Enabled      bool `env:"ADMISSION_ENABLED" default:"true"`
+	InitialLimit int  `env:"ADMISSION_INITIAL_LIMIT" default:"100" min:"1" atMost:"MaxLimit"`
+	MinLimit     int  `env:"ADMISSION_MIN_LIMIT" default:"10" min:"1" atMost:"MaxLimit"`
+	MaxLimit     int  `env:"ADMISSION_MAX_LIMIT" default:"1000" min:"1"`
+}
+
+// Tracing
+//
+// OTLPEndpoint is the base URL of the OpenTelemetry collector receiving the spans with
+// OTLP/HTTP, e.g. http://localhost:4318; spans are not exported when empty. OTLPHeaders are
+// sent with every export, set as "key=value" pairs separated by commas. SampleRatio is the fraction
+// of new traces sampled, none when 0; OTLPTimeout is expressed in seconds.
+type Tracing struct {
+	OTLPEndpoint string            `env:"TRACING_OTLP_ENDPOINT"`
+	OTLPHeaders  map[string]string `env:"TRACING_OTLP_HEADERS" secret:"true"`
+	OTLPTimeout  int               `env:"TRACING_OTLP_TIMEOUT" default:"10" min:"0"`
+	SampleRatio  float64           `env:"TRACING_SAMPLE_RATIO" default:"1" min:"0" max:"1"`
+}
+
+// Health
+//
+// CheckTimeout bounds every health check; ShutdownDelay is how long the server keeps serving
+// once readiness fails on shutdown, for the load balancers to notice. Both are expressed in
+// seconds.
+type Health struct {
+	CheckTimeout  int `env:"HEALTH_CHECK_TIMEOUT" default:"2"`
+	ShutdownDelay int `env:"HEALTH_SHUTDOWN_DELAY" min:"0"`
+}
+
+// Logger
+//
+// Level is debug, info, warn or error; when empty, that of the profile, debug in development
+// and info otherwise.
+// It can also be changed at runtime with PUT /admin/log-level, until LOG_LEVEL itself changes.
+type Logger struct {
+	Level string `env:"LOG_LEVEL" reload:"live"`
 }
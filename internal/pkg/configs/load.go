@@ -0,0 +1,419 @@
+package configs
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv
+//
+// names the configuration file when --config is not given.
+const ConfigFileEnv = "CONFIG_FILE"
+
+// Source
+//
+// the layer a value was taken from, by increasing precedence.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// field
+//
+// a value of Config: path holds the names of the fields leading to it, origin the variable,
+// flag or file it was last set from.
+type field struct {
+	path   []string
+	tag    reflect.StructTag
+	value  reflect.Value
+	source Source
+	origin string
+}
+
+var (
+	printRequested bool
+	lookupEnv      = os.LookupEnv
+)
+
+// LoadConfig
+//
+// builds the configuration from, by increasing precedence: the default tags, the configuration
+// file named by --config or CONFIG_FILE, the environment variables named by the env tags and
+// the command line flags named after them (--server-port for SERVER_PORT). The file is YAML,
+// JSON or TOML according to its extension, its keys the field names (server.timeouts.read).
+// Fields with a secret tag are also read from the files mounted in --secrets-dir or
+// SECRETS_DIR, below the environment, and from the files named by their variable suffixed
+// with _FILE, in place of the variable.
+// With --print-config the caller is expected to Print the result and exit.
+func LoadConfig(arguments ...string) error {
+	l, print, err := build(arguments, lookupEnv)
+	if err != nil {
+		return err
+	}
+	current.Store(l)
+	args, printRequested = arguments, print
+	return nil
+}
+
+// PrintRequested
+//
+// whether --print-config was given.
+func PrintRequested() bool {
+	return printRequested
+}
+
+func load(args []string, lookupEnv func(string) (string, bool)) (*Config, []*field, bool, error) {
+	l, print, err := build(args, lookupEnv)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return l.config, l.fields, print, nil
+}
+
+func build(args []string, lookupEnv func(string) (string, bool)) (*loaded, bool, error) {
+	c := &Config{}
+	fields := collect(reflect.ValueOf(c).Elem(), nil)
+
+	flags := flag.NewFlagSet("bucket_organizer", flag.ContinueOnError)
+	file := flags.String("config", "", "configuration file, YAML, JSON or TOML; overrides "+ConfigFileEnv)
+	print := flags.Bool("print-config", false, "print the effective configuration and exit")
+	secrets := flags.String("secrets-dir", "", "directory of mounted secret files; overrides "+SecretsDirEnv)
+	overrides := make(map[*field]string)
+	for _, f := range fields {
+		flags.Var(&flagValue{
+			boolean: f.value.Kind() == reflect.Bool,
+			set:     func(v string) error { overrides[f] = v; return nil },
+		}, f.flag(), "overrides "+f.env())
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, false, err
+	}
+	if flags.NArg() > 0 {
+		return nil, false, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	// every invalid value is reported, not only the first one
+	var errs []error
+	for _, f := range fields {
+		if value, ok := f.tag.Lookup("default"); ok {
+			errs = append(errs, f.set(value, SourceDefault, ""))
+		}
+	}
+	path := *file
+	if path == "" {
+		path, _ = lookupEnv(ConfigFileEnv)
+	}
+	if path != "" {
+		errs = append(errs, loadFile(fields, path)...)
+	}
+	dir := *secrets
+	if dir == "" {
+		dir, _ = lookupEnv(SecretsDirEnv)
+	}
+	if dir != "" {
+		errs = append(errs, loadSecrets(fields, dir)...)
+	}
+	for _, f := range fields {
+		if value, ok := lookupEnv(f.env()); ok {
+			errs = append(errs, f.set(value, SourceEnv, f.env()))
+		}
+	}
+	errs = append(errs, loadSecretFiles(fields, lookupEnv)...)
+	for _, f := range fields {
+		if value, ok := overrides[f]; ok {
+			errs = append(errs, f.set(value, SourceFlag, "--"+f.flag()))
+		}
+	}
+	for _, f := range fields {
+		errs = append(errs, f.validate(), f.compare(fields))
+	}
+	errs = append(errs, c.checkProfile()...)
+	if err := errors.Join(errs...); err != nil {
+		return nil, false, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return &loaded{config: c, fields: fields, file: path}, *print, nil
+}
+
+// collect
+//
+// the fields of v, a struct, and of the structs it holds; only those with an env tag are
+// configurable.
+func collect(v reflect.Value, path []string) []*field {
+	var fields []*field
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		structField := t.Field(i)
+		fieldPath := append(append([]string(nil), path...), structField.Name)
+		env := structField.Tag.Get("env")
+		if env == "" && v.Field(i).Kind() == reflect.Struct {
+			fields = append(fields, collect(v.Field(i), fieldPath)...)
+			continue
+		}
+		if env == "" {
+			continue
+		}
+		fields = append(fields, &field{path: fieldPath, tag: structField.Tag, value: v.Field(i)})
+	}
+	return fields
+}
+
+func (f *field) env() string {
+	return f.tag.Get("env")
+}
+
+// flag
+//
+// the command line flag setting the field, the env tag in kebab case.
+func (f *field) flag() string {
+	return strings.ReplaceAll(strings.ToLower(f.env()), "_", "-")
+}
+
+// key
+//
+// the key of the field in configuration files, e.g. "server.timeouts.read".
+func (f *field) key() string {
+	keys := make([]string, len(f.path))
+	for i, name := range f.path {
+		keys[i] = lowerCamel(name)
+	}
+	return strings.Join(keys, ".")
+}
+
+// secret
+//
+// whether the value must not be printed nor logged; secrets may also be read from files.
+func (f *field) secret() bool {
+	secret, _ := strconv.ParseBool(f.tag.Get("secret"))
+	return secret
+}
+
+func (f *field) set(value string, source Source, origin string) error {
+	if origin == "" {
+		origin = f.key()
+	}
+	v, err := parse(f.value.Type(), value)
+	if err != nil {
+		return fmt.Errorf("invalid %s for %s: %w", typeName(f.value.Type()), origin, err)
+	}
+	f.value.Set(v)
+	f.source, f.origin = source, origin
+	return nil
+}
+
+// flagValue
+//
+// defers setting the field until the lower precedence layers are applied.
+type flagValue struct {
+	boolean bool
+	set     func(string) error
+}
+
+func (v *flagValue) String() string {
+	return ""
+}
+
+func (v *flagValue) Set(value string) error {
+	return v.set(value)
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.boolean
+}
+
+// loadFile
+//
+// sets the fields found in the configuration file at path; unknown keys are rejected, so that
+// typos do not go unnoticed.
+func loadFile(fields []*field, path string) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("read config file: %w", err)}
+	}
+	var tree map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".json":
+		// JSON is YAML too
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		tree, err = parseTOML(data)
+	default:
+		return []error{fmt.Errorf("config file %s: unsupported extension %q, expected .yaml, .yml, .json or .toml", path, ext)}
+	}
+	if err != nil {
+		return []error{fmt.Errorf("config file %s: %w", path, err)}
+	}
+
+	// the tables of map fields are values, not nested keys
+	maps := make(map[string]bool)
+	for _, f := range fields {
+		if f.value.Kind() == reflect.Map {
+			maps[strings.ToLower(f.key())] = true
+		}
+	}
+	values := make(map[string]any)
+	flatten(tree, "", maps, values)
+
+	var errs []error
+	for _, f := range fields {
+		key := strings.ToLower(f.key())
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		delete(values, key)
+		origin := filepath.Base(path) + ":" + f.key()
+		if value == nil {
+			f.value.Set(reflect.Zero(f.value.Type()))
+			f.source, f.origin = SourceFile, origin
+			continue
+		}
+		if _, list := value.([]any); list && f.value.Kind() != reflect.Slice {
+			errs = append(errs, fmt.Errorf("invalid %s for %s: a list is not a single value", typeName(f.value.Type()), origin))
+			continue
+		}
+		errs = append(errs, f.set(fileValue(value), SourceFile, origin))
+	}
+	if len(values) > 0 {
+		unknown := make([]string, 0, len(values))
+		for key := range values {
+			unknown = append(unknown, key)
+		}
+		sort.Strings(unknown)
+		errs = append(errs, fmt.Errorf("config file %s: unknown keys %s", path, strings.Join(unknown, ", ")))
+	}
+	return errs
+}
+
+// flatten
+//
+// collects the values of tree by lower case dotted key; the tables under the keys of maps are
+// kept whole.
+func flatten(tree map[string]any, prefix string, maps map[string]bool, values map[string]any) {
+	for key, value := range tree {
+		key = prefix + strings.ToLower(key)
+		if table, ok := value.(map[string]any); ok && !maps[key] {
+			flatten(table, key+".", maps, values)
+			continue
+		}
+		values[key] = value
+	}
+}
+
+// fileValue
+//
+// the value of a configuration file in the syntax of the environment variables: lists are
+// separated by commas, and tables are lists of key=value pairs.
+func fileValue(value any) string {
+	switch v := value.(type) {
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fileValue(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]any:
+		pairs := make([]string, 0, len(v))
+		for key, item := range v {
+			pairs = append(pairs, key+"="+fileValue(item))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// lowerCamel
+//
+// lower cases the leading capitals of name, but for the one starting the next word:
+// ServiceName, TTL and OTLPEndpoint become serviceName, ttl and otlpEndpoint.
+func lowerCamel(name string) string {
+	runes := []rune(name)
+	n := 0
+	for n < len(runes) && unicode.IsUpper(runes[n]) {
+		n++
+	}
+	if n > 1 && n < len(runes) {
+		n--
+	}
+	for i := range n {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// Print
+//
+// writes the effective configuration as YAML, usable as a configuration file, noting where
+// every value comes from; secrets are redacted.
+func Print(w io.Writer) error {
+	l := current.Load()
+	if l == nil {
+		return errors.New("configuration not loaded")
+	}
+	return printFields(w, l.fields)
+}
+
+func printFields(w io.Writer, fields []*field) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range fields {
+		parent := root
+		for _, name := range f.path[:len(f.path)-1] {
+			parent = child(parent, lowerCamel(name))
+		}
+		value := &yaml.Node{Kind: yaml.ScalarNode}
+		switch {
+		case f.secret() && !f.value.IsZero():
+			value.Value, value.Tag = redacted, "!!str"
+		default:
+			value.Value = format(f.value)
+			if _, ok := number(f.value); !ok && f.value.Kind() != reflect.Bool {
+				value.Tag = "!!str"
+			}
+		}
+		if f.source != "" {
+			value.LineComment = string(f.source)
+			if f.source != SourceDefault {
+				value.LineComment += " " + f.origin
+			}
+		}
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: lowerCamel(f.path[len(f.path)-1])}, value)
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// child
+//
+// the mapping under key in parent, added when missing.
+func child(parent *yaml.Node, key string) *yaml.Node {
+	for i := 0; i < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == key {
+			return parent.Content[i+1]
+		}
+	}
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, node)
+	return node
+}
@@ -0,0 +1,312 @@
+package configs
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// env
+//
+// looks values up as environment variables; ENV is test unless given, the production profile
+// requiring authentication.
+func env(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := values[key]
+		if !ok && key == "ENV" {
+			return string(Test), true
+		}
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, _, print, err := load(nil, env(nil))
+	require.NoError(t, err)
+	assert.False(t, print)
+	assert.Equal(t, 8080, c.Server.Port)
+	assert.Equal(t, 10, c.Server.Timeouts.Shutdown)
+	assert.Equal(t, "us-east-1", c.Auth.Region)
+	assert.Equal(t, 1.0, c.Tracing.SampleRatio)
+	assert.True(t, c.Admission.Enabled)
+	assert.Empty(t, c.Changes.File)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+serviceName: organizer
+server:
+  port: 9000
+  timeouts:
+    read: 5
+    write: 6
+rateLimit:
+  readRate: 2.5
+tracing:
+  otlpEndpoint: http://collector:4318
+auth:
+  region:
+`)
+	c, fields, print, err := load(
+		[]string{"--server-timeout-write=8", "--auth-enabled", "--print-config"},
+		env(map[string]string{ConfigFileEnv: path, "SERVER_PORT": "9100", "SERVER_TIMEOUT_WRITE": "7"}),
+	)
+	require.NoError(t, err)
+	assert.True(t, print)
+	assert.Equal(t, "organizer", c.ServiceName)
+	assert.Equal(t, 9100, c.Server.Port)
+	assert.Equal(t, 5, c.Server.Timeouts.Read)
+	assert.Equal(t, 8, c.Server.Timeouts.Write)
+	assert.Equal(t, 30, c.Server.Timeouts.Idle)
+	assert.Equal(t, 2.5, c.RateLimit.ReadRate)
+	assert.Equal(t, "http://collector:4318", c.Tracing.OTLPEndpoint)
+	assert.True(t, c.Auth.Enabled)
+	// an empty value in the file overrides the default
+	assert.Empty(t, c.Auth.Region)
+
+	var out strings.Builder
+	require.NoError(t, printFields(&out, fields))
+	printed := out.String()
+	assert.Contains(t, printed, "serviceName: organizer # file config.yaml:serviceName\n")
+	assert.Contains(t, printed, "server:\n  port: 9100 # env SERVER_PORT\n  timeouts:\n    read: 5 # file config.yaml:server.timeouts.read\n    write: 8 # flag --server-timeout-write\n")
+	assert.Contains(t, printed, "    idle: 30 # default\n")
+	assert.Contains(t, printed, "  readRate: 2.5 # file config.yaml:rateLimit.readRate\n")
+	assert.Contains(t, printed, "  otlpEndpoint: http://collector:4318 # file")
+	assert.Contains(t, printed, "  jwt:\n    jwks: \"\"\n")
+}
+
+func TestLoadFileFormats(t *testing.T) {
+	for name, content := range map[string]string{
+		"config.json": `{"server": {"port": 9000, "timeouts": {"read": 5}}, "publish": {"driver": "nats"}, "auth": {"signingSecret": "s3cret"}}`,
+		"config.toml": `
+# comments are allowed
+[server]
+port = 9_000 # inline too
+timeouts.read = 5
+
+[publish]
+driver = "nats"
+
+[auth]
+signingSecret = 's3cret'
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, fields, _, err := load([]string{"--config", writeFile(t, name, content)}, env(nil))
+			require.NoError(t, err)
+			assert.Equal(t, 9000, c.Server.Port)
+			assert.Equal(t, 5, c.Server.Timeouts.Read)
+			assert.Equal(t, "nats", c.Publish.Driver)
+			assert.Equal(t, "s3cret", c.Auth.SigningSecret.Value())
+
+			var out strings.Builder
+			require.NoError(t, printFields(&out, fields))
+			assert.NotContains(t, out.String(), "s3cret")
+			assert.Contains(t, out.String(), "signingSecret: <redacted>")
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for name, args := range map[string][]string{
+		"unknown key":    {"--config", writeFile(t, "typo.yaml", "server:\n  prot: 9000\n")},
+		"invalid value":  {"--config", writeFile(t, "invalid.yaml", "server:\n  port: nine\n")},
+		"list":           {"--config", writeFile(t, "list.yaml", "server:\n  port: [1, 2]\n")},
+		"extension":      {"--config", writeFile(t, "config.ini", "port=1\n")},
+		"toml array":     {"--config", writeFile(t, "array.toml", "[server]\nport = [1]\n")},
+		"toml duplicate": {"--config", writeFile(t, "duplicate.toml", "[server]\nport = 1\nport = 2\n")},
+		"missing file":   {"--config", filepath.Join(t.TempDir(), "missing.yaml")},
+		"invalid flag":   {"--server-port=nine"},
+		"unknown flag":   {"--server-prot=9000"},
+		"argument":       {"serve"},
+	} {
+		_, _, _, err := load(args, env(nil))
+		assert.Error(t, err, name)
+	}
+
+	_, _, _, err := load([]string{"--config", writeFile(t, "typo.yaml", "server:\n  prot: 9000\n  port: 1\nextra: true\n")}, env(nil))
+	assert.ErrorContains(t, err, "unknown keys extra, server.prot")
+	_, _, _, err = load(nil, env(map[string]string{"SERVER_PORT": "nine"}))
+	assert.ErrorContains(t, err, "invalid int for SERVER_PORT")
+}
+
+func TestLowerCamel(t *testing.T) {
+	for name, key := range map[string]string{
+		"Port":         "port",
+		"ServiceName":  "serviceName",
+		"TTL":          "ttl",
+		"JWT":          "jwt",
+		"JWKSRefresh":  "jwksRefresh",
+		"OTLPEndpoint": "otlpEndpoint",
+	} {
+		assert.Equal(t, key, lowerCamel(name))
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	_, _, _, err := load(
+		[]string{"--server-port=70000", "--tracing-sample-ratio=1.5"},
+		env(map[string]string{
+			"SERVICE_NAME": "", "RATELIMIT_KEY": "user", "BATCH_CONCURRENCY": "0", "WATCH_BUFFER": "many",
+			"ADMISSION_MIN_LIMIT": "50", "ADMISSION_MAX_LIMIT": "20", "WEBHOOKS_BACKOFF": "7200",
+		}),
+	)
+	require.Error(t, err)
+	// every violation is reported at once
+	for _, violation := range []string{
+		"serviceName (SERVICE_NAME): is required",
+		"server.port (SERVER_PORT): must be at most 65535",
+		"tracing.sampleRatio (TRACING_SAMPLE_RATIO): must be at most 1",
+		`rateLimit.key (RATELIMIT_KEY): "user" is not one of client, tenant, ip`,
+		"batch.concurrency (BATCH_CONCURRENCY): must be at least 1",
+		"invalid int for WATCH_BUFFER",
+		"admission.minLimit (ADMISSION_MIN_LIMIT): must be at most admission.maxLimit (ADMISSION_MAX_LIMIT), 20",
+		"admission.initialLimit (ADMISSION_INITIAL_LIMIT): must be at most admission.maxLimit (ADMISSION_MAX_LIMIT), 20",
+		"webhooks.backoff (WEBHOOKS_BACKOFF): must be at most webhooks.maxBackoff (WEBHOOKS_MAX_BACKOFF), 3600",
+	} {
+		assert.ErrorContains(t, err, violation)
+	}
+
+	// oneof is not checked on empty values
+	c, _, _, err := load(nil, env(map[string]string{"PUBLISH_DRIVER": ""}))
+	require.NoError(t, err)
+	assert.Empty(t, c.Publish.Driver)
+	_, _, _, err = load(nil, env(map[string]string{"PUBLISH_DRIVER": "kafka"}))
+	assert.ErrorContains(t, err, `"kafka" is not one of channel, nats, file`)
+}
+
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level %q", text)
+	}
+	return nil
+}
+
+func (l level) MarshalText() ([]byte, error) {
+	return []byte(map[level]string{1: "low", 2: "high"}[l]), nil
+}
+
+type kinds struct {
+	Float    float32           `env:"FLOAT" min:"0.5"`
+	Uint     uint16            `env:"UINT" max:"100"`
+	Duration time.Duration     `env:"DURATION" min:"1s"`
+	Names    []string          `env:"NAMES" required:"true" max:"3"`
+	Ports    []int             `env:"PORTS"`
+	Labels   map[string]string `env:"LABELS"`
+	Weights  map[string]int    `env:"WEIGHTS"`
+	Endpoint url.URL           `env:"ENDPOINT"`
+	Level    level             `env:"LEVEL" oneof:"low high"`
+}
+
+func TestFieldKinds(t *testing.T) {
+	var k kinds
+	fields := collect(reflect.ValueOf(&k).Elem(), nil)
+	values := map[string]string{
+		"FLOAT":    "2.5",
+		"UINT":     "80",
+		"DURATION": "1m30s",
+		"NAMES":    "a, b",
+		"PORTS":    "80,443",
+		"LABELS":   "team=ops, tier = gold",
+		"WEIGHTS":  "a=1,b=2",
+		"ENDPOINT": "https://example.com:8443/api?x=1",
+		"LEVEL":    "high",
+	}
+	for _, f := range fields {
+		require.NoError(t, f.set(values[f.env()], SourceEnv, f.env()))
+		require.NoError(t, f.validate())
+		assert.Equal(t, strings.ReplaceAll(strings.ReplaceAll(values[f.env()], ", ", ","), " = ", "="), format(f.value), f.env())
+	}
+	assert.Equal(t, float32(2.5), k.Float)
+	assert.Equal(t, uint16(80), k.Uint)
+	assert.Equal(t, 90*time.Second, k.Duration)
+	assert.Equal(t, []string{"a", "b"}, k.Names)
+	assert.Equal(t, []int{80, 443}, k.Ports)
+	assert.Equal(t, map[string]string{"team": "ops", "tier": "gold"}, k.Labels)
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, k.Weights)
+	assert.Equal(t, "example.com:8443", k.Endpoint.Host)
+	assert.Equal(t, level(2), k.Level)
+
+	byEnv := make(map[string]*field)
+	for _, f := range fields {
+		byEnv[f.env()] = f
+	}
+	for env, value := range map[string]string{
+		"FLOAT":    "fast",
+		"UINT":     "-1",
+		"DURATION": "90",
+		"PORTS":    "80,http",
+		"LABELS":   "team",
+		"ENDPOINT": "://",
+		"LEVEL":    "medium",
+	} {
+		assert.Error(t, byEnv[env].set(value, SourceEnv, env), env)
+	}
+	for env, value := range map[string]string{
+		"FLOAT":    "0.25",
+		"UINT":     "101",
+		"DURATION": "500ms",
+		"NAMES":    "a,b,c,d",
+	} {
+		require.NoError(t, byEnv[env].set(value, SourceEnv, env))
+		assert.Error(t, byEnv[env].validate(), env)
+	}
+	require.NoError(t, byEnv["NAMES"].set("", SourceEnv, "NAMES"))
+	assert.ErrorContains(t, byEnv["NAMES"].validate(), "names (NAMES): is required")
+}
+
+func TestLoadFileLists(t *testing.T) {
+	for name, content := range map[string]string{
+		"config.yaml": "tracing:\n  otlpHeaders:\n    Authorization: Bearer abc\n    X-Scope: ops\n",
+		"config.toml": "[tracing.otlpHeaders]\nAuthorization = \"Bearer abc\"\nX-Scope = 'ops'\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, _, _, err := load([]string{"--config", writeFile(t, name, content)}, env(nil))
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"Authorization": "Bearer abc", "X-Scope": "ops"}, c.Tracing.OTLPHeaders)
+		})
+	}
+
+	table, err := parseTOML([]byte(`list = ["a,b", 'c', 3, ] # comment`))
+	require.NoError(t, err)
+	assert.Equal(t, []any{"a,b", "c", int64(3)}, table["list"])
+	_, err = parseTOML([]byte("list = [[1]]"))
+	assert.Error(t, err)
+}
+
+func TestFieldCompare(t *testing.T) {
+	var b struct {
+		Low   time.Duration `env:"LOW" atMost:"High"`
+		High  time.Duration `env:"HIGH"`
+		Typo  int           `env:"TYPO" atMost:"Hihg"`
+		Names []string      `env:"NAMES" atMost:"High"`
+	}
+	fields := collect(reflect.ValueOf(&b).Elem(), nil)
+	b.Low, b.High = time.Second, time.Minute
+	assert.NoError(t, fields[0].compare(fields))
+	b.Low = time.Hour
+	assert.EqualError(t, fields[0].compare(fields), "low (LOW): must be at most high (HIGH), 1m0s")
+	assert.ErrorContains(t, fields[2].compare(fields), `invalid atMost tag "Hihg": no such field`)
+	assert.ErrorContains(t, fields[3].compare(fields), "list values cannot be compared")
+}
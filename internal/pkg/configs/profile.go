@@ -0,0 +1,106 @@
+package configs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Profile
+//
+// the environment the server runs in, set by ENV; it selects the defaults of the settings
+// depending on it, see ProfileSettings. It is production unless set, so that a deployment
+// missing ENV does not expose the development settings; local setups opt into development.
+type Profile string
+
+const (
+	Development Profile = "development"
+	Staging     Profile = "staging"
+	Production  Profile = "production"
+	Test        Profile = "test"
+)
+
+// ProfileSettings
+//
+// LogLevel applies when LOG_LEVEL is empty and CorsOrigins when SERVER_CORS_ORIGINS is;
+// ConsoleLogs writes human readable logs instead of JSON, StacktraceLevel is the lowest
+// level of the log entries carrying a stack trace and Pprof exposes /debug/pprof.
+// Authentication refuses to start the server with AUTH_ENABLED false.
+type ProfileSettings struct {
+	LogLevel        string
+	ConsoleLogs     bool
+	StacktraceLevel string
+	Pprof           bool
+	CorsOrigins     []string
+	Authentication  bool
+}
+
+var profiles = map[Profile]ProfileSettings{
+	Development: {LogLevel: "debug", ConsoleLogs: true, StacktraceLevel: "warn", Pprof: true, CorsOrigins: []string{"*"}},
+	Test:        {LogLevel: "info", ConsoleLogs: true, StacktraceLevel: "error", CorsOrigins: []string{"*"}},
+	Staging:     {LogLevel: "info", StacktraceLevel: "error", Pprof: true, Authentication: true},
+	Production:  {LogLevel: "info", StacktraceLevel: "dpanic", Authentication: true},
+}
+
+// profileAliases
+//
+// the short names accepted for the profiles.
+var profileAliases = map[string]Profile{
+	"local": Development,
+	"dev":   Development,
+	"stage": Staging,
+	"prod":  Production,
+}
+
+func (p *Profile) UnmarshalText(text []byte) error {
+	name := strings.ToLower(strings.TrimSpace(string(text)))
+	if alias, ok := profileAliases[name]; ok {
+		*p = alias
+		return nil
+	}
+	if _, ok := profiles[Profile(name)]; !ok {
+		return fmt.Errorf("unknown environment %q, expected %s, %s, %s or %s", text, Development, Staging, Production, Test)
+	}
+	*p = Profile(name)
+	return nil
+}
+
+// Settings
+//
+// the settings of the profile, those of Production for unknown profiles.
+func (p Profile) Settings() ProfileSettings {
+	if settings, ok := profiles[p]; ok {
+		return settings
+	}
+	return profiles[Production]
+}
+
+// LogLevel
+//
+// LOG_LEVEL, or the level of the profile when empty.
+func (c *Config) LogLevel() string {
+	if c.Logger.Level != "" {
+		return c.Logger.Level
+	}
+	return c.Environment.Settings().LogLevel
+}
+
+// CorsOrigins
+//
+// SERVER_CORS_ORIGINS, or the origins of the profile when empty.
+func (c *Config) CorsOrigins() []string {
+	if len(c.Server.CorsOrigins) > 0 {
+		return c.Server.CorsOrigins
+	}
+	return c.Environment.Settings().CorsOrigins
+}
+
+// checkProfile
+//
+// the settings the profile of c does not allow.
+func (c *Config) checkProfile() []error {
+	var errs []error
+	if c.Environment.Settings().Authentication && !c.Auth.Enabled {
+		errs = append(errs, fmt.Errorf("auth.enabled (AUTH_ENABLED): must be true in the %s environment", c.Environment))
+	}
+	return errs
+}
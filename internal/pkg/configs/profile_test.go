@@ -0,0 +1,61 @@
+package configs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfile(t *testing.T) {
+	tests := []struct {
+		env         string
+		profile     Profile
+		logLevel    string
+		corsOrigins []string
+	}{
+		{"", Production, "info", nil},
+		{"local", Development, "debug", []string{"*"}},
+		{"Stage", Staging, "info", nil},
+		{"PROD", Production, "info", nil},
+		{"test", Test, "info", []string{"*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			values := map[string]string{"AUTH_ENABLED": "true"}
+			if tt.env != "" {
+				values["ENV"] = tt.env
+			}
+			config, _, _, err := load(nil, func(key string) (string, bool) {
+				v, ok := values[key]
+				return v, ok
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.profile, config.Environment)
+			assert.Equal(t, tt.logLevel, config.LogLevel())
+			assert.Equal(t, tt.corsOrigins, config.CorsOrigins())
+		})
+	}
+
+	config, _, _, err := load(nil, env(map[string]string{"ENV": "production", "AUTH_ENABLED": "true", "LOG_LEVEL": "warn", "SERVER_CORS_ORIGINS": "https://app.example.com"}))
+	require.NoError(t, err)
+	assert.Equal(t, "warn", config.LogLevel())
+	assert.Equal(t, []string{"https://app.example.com"}, config.CorsOrigins())
+	assert.False(t, config.Environment.Settings().Pprof)
+	assert.Equal(t, profiles[Production], Profile("").Settings())
+
+	_, _, _, err = load(nil, env(map[string]string{"ENV": "qa", "SERVER_PORT": "0"}))
+	assert.ErrorContains(t, err, `invalid profile for ENV: unknown environment "qa"`)
+	assert.ErrorContains(t, err, "must be at least 1")
+}
+
+func TestProfileAuthentication(t *testing.T) {
+	for _, profile := range []Profile{Staging, Production} {
+		_, _, _, err := load(nil, env(map[string]string{"ENV": string(profile), "AUTH_ENABLED": "false"}))
+		assert.ErrorContains(t, err, "auth.enabled (AUTH_ENABLED): must be true in the "+string(profile)+" environment")
+	}
+	for _, profile := range []Profile{Development, Test} {
+		_, _, _, err := load(nil, env(map[string]string{"ENV": string(profile), "AUTH_ENABLED": "false"}))
+		assert.NoError(t, err, profile)
+	}
+}
@@ -0,0 +1,115 @@
+package configs
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// loaded
+//
+// a configuration and the fields it was built from, swapped as a whole on reload.
+type loaded struct {
+	config *Config
+	fields []*field
+	file   string
+}
+
+var (
+	current     atomic.Pointer[loaded]
+	args        []string
+	reloadMu    sync.Mutex
+	subscribers []func(*Config)
+	subscribeMu sync.Mutex
+)
+
+// Update
+//
+// the keys of the fields a reload changed: Applied are live, with a reload tag, and now in
+// effect; Restart keep their running value until the server restarts.
+type Update struct {
+	Applied []string
+	Restart []string
+}
+
+// Reload
+//
+// builds the configuration again from the same layers and flags as LoadConfig. An invalid
+// configuration leaves the current one in place; otherwise it is swapped atomically and the
+// subscribers are notified when a live field changed.
+func Reload() (Update, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	if current.Load() == nil {
+		return Update{}, errors.New("configuration not loaded")
+	}
+	reloaded, _, err := build(args, lookupEnv)
+	if err != nil {
+		return Update{}, err
+	}
+	update := merge(current.Load().fields, reloaded.fields)
+	current.Store(reloaded)
+	if len(update.Applied) > 0 {
+		notify(reloaded.config)
+	}
+	return update, nil
+}
+
+// merge
+//
+// compares the reloaded fields to the running ones, which they mirror one for one, and
+// restores the running value of the fields that cannot change live.
+func merge(running, reloaded []*field) Update {
+	var update Update
+	for i, f := range reloaded {
+		old := running[i]
+		if reflect.DeepEqual(old.value.Interface(), f.value.Interface()) {
+			continue
+		}
+		if f.live() {
+			update.Applied = append(update.Applied, f.key())
+			continue
+		}
+		update.Restart = append(update.Restart, f.key())
+		f.value.Set(old.value)
+		f.source, f.origin = old.source, old.origin
+	}
+	return update
+}
+
+// Subscribe
+//
+// calls fn with the new configuration after every reload changing a live field.
+func Subscribe(fn func(*Config)) {
+	subscribeMu.Lock()
+	defer subscribeMu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+func notify(c *Config) {
+	subscribeMu.Lock()
+	fns := slices.Clone(subscribers)
+	subscribeMu.Unlock()
+	for _, fn := range fns {
+		fn(c)
+	}
+}
+
+// File
+//
+// the configuration file in use, empty when there is none.
+func File() string {
+	if l := current.Load(); l != nil {
+		return l.file
+	}
+	return ""
+}
+
+// live
+//
+// whether the field can change without a restart, with reload:"live".
+func (f *field) live() bool {
+	return f.tag.Get("reload") == "live"
+}
@@ -0,0 +1,60 @@
+package configs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	t.Cleanup(func() {
+		current.Store(nil)
+		lookupEnv, subscribers = os.LookupEnv, nil
+	})
+	lookupEnv = env(map[string]string{"RATELIMIT_WRITE_RATE": "3"})
+	_, err := Reload()
+	assert.Error(t, err)
+
+	path := writeFile(t, "config.yaml", "server:\n  port: 9000\nrateLimit:\n  readRate: 5\n")
+	require.NoError(t, LoadConfig("--config", path))
+	assert.Equal(t, path, File())
+	running := Global()
+
+	var notified []*Config
+	Subscribe(func(c *Config) { notified = append(notified, c) })
+
+	update, err := Reload()
+	require.NoError(t, err)
+	assert.Empty(t, update.Applied)
+	assert.Empty(t, update.Restart)
+	assert.Empty(t, notified)
+
+	require.NoError(t, os.WriteFile(path, []byte("server:\n  port: 9100\n  corsOrigins: [https://app.example.com]\nrateLimit:\n  readRate: 50\n"), 0o600))
+	update, err = Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"server.corsOrigins", "rateLimit.readRate"}, update.Applied)
+	assert.Equal(t, []string{"server.port"}, update.Restart)
+	require.Len(t, notified, 1)
+	assert.Same(t, Global(), notified[0])
+	assert.Equal(t, 50.0, Global().RateLimit.ReadRate)
+	assert.Equal(t, 3.0, Global().RateLimit.WriteRate)
+	assert.Equal(t, []string{"https://app.example.com"}, Global().Server.CorsOrigins)
+	// the port keeps its running value until a restart
+	assert.Equal(t, 9000, Global().Server.Port)
+	// the previous configuration is replaced, not modified
+	assert.Equal(t, 5.0, running.RateLimit.ReadRate)
+
+	// the restart is reported again as long as it is pending
+	update, err = Reload()
+	require.NoError(t, err)
+	assert.Empty(t, update.Applied)
+	assert.Equal(t, []string{"server.port"}, update.Restart)
+
+	require.NoError(t, os.WriteFile(path, []byte("rateLimit:\n  readRate: -1\n"), 0o600))
+	_, err = Reload()
+	assert.ErrorContains(t, err, "rateLimit.readRate (RATELIMIT_READ_RATE): must be at least 0")
+	assert.Equal(t, 50.0, Global().RateLimit.ReadRate)
+	assert.Len(t, notified, 1)
+}
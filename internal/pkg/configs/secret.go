@@ -0,0 +1,140 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SecretsDirEnv
+//
+// names the directory of mounted secret files when --secrets-dir is not given.
+const SecretsDirEnv = "SECRETS_DIR"
+
+const redacted = "<redacted>"
+
+// Secret
+//
+// a string redacting itself when formatted with fmt, marshalled to JSON or logged, so that
+// it cannot leak by accident; Value reveals it.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return fmt.Sprintf("configs.Secret(%q)", s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// readSecret
+//
+// the content of a secret file, without the trailing line break editors and "echo" add.
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// loadSecrets
+//
+// sets the fields with a secret tag from the files mounted in dir, named after their
+// environment variable in lower case (/run/secrets/auth_signing_secret for
+// AUTH_SIGNING_SECRET); missing files are skipped.
+func loadSecrets(fields []*field, dir string) []error {
+	var errs []error
+	for _, f := range fields {
+		if !f.secret() {
+			continue
+		}
+		path := filepath.Join(dir, strings.ToLower(f.env()))
+		value, err := readSecret(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read secret %s: %w", f.env(), err))
+			continue
+		}
+		errs = append(errs, f.set(value, SourceFile, path))
+	}
+	return errs
+}
+
+// loadSecretFiles
+//
+// sets the fields with a secret tag from the files named by their environment variable
+// suffixed with _FILE, e.g. AUTH_SIGNING_SECRET_FILE; setting both variables is an error.
+func loadSecretFiles(fields []*field, lookupEnv func(string) (string, bool)) []error {
+	var errs []error
+	for _, f := range fields {
+		if !f.secret() {
+			continue
+		}
+		name := f.env() + "_FILE"
+		path, ok := lookupEnv(name)
+		if !ok {
+			continue
+		}
+		if _, set := lookupEnv(f.env()); set {
+			errs = append(errs, fmt.Errorf("%s and %s are both set", f.env(), name))
+			continue
+		}
+		value, err := readSecret(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read secret %s: %w", name, err))
+			continue
+		}
+		errs = append(errs, f.set(value, SourceEnv, name))
+	}
+	return errs
+}
+
+// Redacted
+//
+// the effective configuration keyed like configuration files, with the secrets redacted;
+// the view to log.
+func Redacted() map[string]any {
+	l := current.Load()
+	if l == nil {
+		return nil
+	}
+	return redactFields(l.fields)
+}
+
+func redactFields(fields []*field) map[string]any {
+	view := make(map[string]any)
+	for _, f := range fields {
+		parent := view
+		for _, name := range f.path[:len(f.path)-1] {
+			key := lowerCamel(name)
+			next, ok := parent[key].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				parent[key] = next
+			}
+			parent = next
+		}
+		var value any = f.value.Interface()
+		if f.secret() && !f.empty() {
+			value = redacted
+		}
+		parent[lowerCamel(f.path[len(f.path)-1])] = value
+	}
+	return view
+}
@@ -0,0 +1,202 @@
+package configs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML
+//
+// parses the subset of TOML configuration files need: tables, dotted keys, comments and
+// basic or literal strings, integers, floats, booleans and single-line arrays of those. Nested
+// arrays, inline tables, multi-line strings and dates are rejected.
+func parseTOML(data []byte) (map[string]any, error) {
+	root := make(map[string]any)
+	table := root
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			end := strings.IndexByte(line, ']')
+			if strings.HasPrefix(line, "[[") || end < 0 || !isComment(line[end+1:]) {
+				return nil, fmt.Errorf("line %d: invalid table header", n)
+			}
+			var err error
+			if table, err = tomlTable(root, line[1:end]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			continue
+		}
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		value, err := tomlValue(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		keys, err := tomlKeys(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		parent := table
+		if len(keys) > 1 {
+			if parent, err = tomlTable(table, strings.Join(keys[:len(keys)-1], ".")); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+		}
+		last := keys[len(keys)-1]
+		if _, exists := parent[last]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", n, strings.TrimSpace(key))
+		}
+		parent[last] = value
+	}
+	return root, scanner.Err()
+}
+
+// tomlTable
+//
+// the table at the dotted key under parent, created when missing.
+func tomlTable(parent map[string]any, key string) (map[string]any, error) {
+	keys, err := tomlKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	table := parent
+	for _, k := range keys {
+		switch v := table[k].(type) {
+		case nil:
+			next := make(map[string]any)
+			table[k] = next
+			table = next
+		case map[string]any:
+			table = v
+		default:
+			return nil, fmt.Errorf("key %q is not a table", k)
+		}
+	}
+	return table, nil
+}
+
+// tomlKeys
+//
+// splits a dotted key made of bare keys.
+func tomlKeys(key string) ([]string, error) {
+	keys := strings.Split(key, ".")
+	for i, k := range keys {
+		k = strings.TrimSpace(k)
+		if k == "" || strings.IndexFunc(k, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+		}) >= 0 {
+			return nil, fmt.Errorf("invalid key %q", strings.TrimSpace(key))
+		}
+		keys[i] = k
+	}
+	return keys, nil
+}
+
+func tomlValue(s string) (any, error) {
+	switch {
+	case strings.HasPrefix(s, `"""`), strings.HasPrefix(s, "'''"):
+		return nil, errors.New("multi-line strings are not supported")
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				if !isComment(s[i+1:]) {
+					return nil, fmt.Errorf("unexpected %q after string", s[i+1:])
+				}
+				return strconv.Unquote(s[:i+1])
+			}
+		}
+		return nil, errors.New("unterminated string")
+	case strings.HasPrefix(s, "'"):
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, errors.New("unterminated string")
+		}
+		if !isComment(s[end+2:]) {
+			return nil, fmt.Errorf("unexpected %q after string", s[end+2:])
+		}
+		return s[1 : end+1], nil
+	case strings.HasPrefix(s, "["):
+		return tomlArray(s)
+	case strings.HasPrefix(s, "{"):
+		return nil, errors.New("inline tables are not supported")
+	}
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "":
+		return nil, errors.New("missing value")
+	}
+	number := strings.ReplaceAll(s, "_", "")
+	if i, err := strconv.ParseInt(number, 0, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported value %q", s)
+}
+
+// tomlArray
+//
+// parses an array of scalars, splitting it on the commas outside of strings.
+func tomlArray(s string) ([]any, error) {
+	var items []string
+	start, quote := 1, byte(0)
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			return nil, errors.New("nested arrays are not supported")
+		case c == ',' || c == ']':
+			if item := strings.TrimSpace(s[start:i]); item != "" || c == ',' {
+				items = append(items, item)
+			}
+			start = i + 1
+			if c == ']' {
+				if !isComment(s[i+1:]) {
+					return nil, fmt.Errorf("unexpected %q after array", s[i+1:])
+				}
+				values := make([]any, len(items))
+				for j, item := range items {
+					value, err := tomlValue(item)
+					if err != nil {
+						return nil, fmt.Errorf("array item %d: %w", j, err)
+					}
+					values[j] = value
+				}
+				return values, nil
+			}
+		}
+	}
+	return nil, errors.New("unterminated array")
+}
+
+func isComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || strings.HasPrefix(s, "#")
+}
@@ -0,0 +1,317 @@
+package configs
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	urlType             = reflect.TypeOf(url.URL{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// parse
+//
+// the value of type t written as s: durations as "1m30s", lists separated by commas and maps
+// as "key=value" pairs separated by commas; types implementing encoding.TextUnmarshaler parse
+// themselves.
+func parse(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch {
+	case t == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(int64(d))
+		return v, nil
+	case t == urlType:
+		u, err := url.Parse(s)
+		if err != nil {
+			return v, err
+		}
+		v.Set(reflect.ValueOf(*u))
+		return v, nil
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		return v, err
+	}
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := split(s)
+		v.Set(reflect.MakeSlice(t, 0, len(items)))
+		for i, item := range items {
+			elem, err := parse(t.Elem(), item)
+			if err != nil {
+				return v, fmt.Errorf("item %d: %w", i, err)
+			}
+			v.Set(reflect.Append(v, elem))
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMap(t))
+		for _, pair := range split(s) {
+			k, e, ok := strings.Cut(pair, "=")
+			if !ok {
+				return v, fmt.Errorf("%q is not a key=value pair", pair)
+			}
+			key, err := parse(t.Key(), strings.TrimSpace(k))
+			if err != nil {
+				return v, fmt.Errorf("key %q: %w", k, err)
+			}
+			elem, err := parse(t.Elem(), strings.TrimSpace(e))
+			if err != nil {
+				return v, fmt.Errorf("value of %q: %w", k, err)
+			}
+			v.SetMapIndex(key, elem)
+		}
+	default:
+		return v, fmt.Errorf("unsupported type %s", t)
+	}
+	return v, nil
+}
+
+// split
+//
+// the trimmed items of a list separated by commas, none when s is blank.
+func split(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	items := strings.Split(s, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+// typeName
+//
+// names t in the errors of parse.
+func typeName(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "duration"
+	case t == urlType:
+		return "url"
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return strings.ToLower(t.Name())
+	case t.Kind() == reflect.Slice:
+		return "list"
+	case t.Kind() == reflect.Map:
+		return "map"
+	case t.Kind() == reflect.Float32, t.Kind() == reflect.Float64:
+		return "float"
+	}
+	return t.Kind().String()
+}
+
+// format
+//
+// writes v as parse reads it.
+func format(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Type() == urlType:
+		u := v.Interface().(url.URL)
+		return u.String()
+	case v.Type().Implements(textMarshalerType), reflect.PointerTo(v.Type()).Implements(textMarshalerType):
+		m, ok := v.Interface().(encoding.TextMarshaler)
+		if !ok {
+			m = addressable(v).Addr().Interface().(encoding.TextMarshaler)
+		}
+		text, err := m.MarshalText()
+		if err != nil {
+			return fmt.Sprint(v.Interface())
+		}
+		return string(text)
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = format(v.Index(i))
+		}
+		return strings.Join(items, ",")
+	case reflect.Map:
+		pairs := make([]string, 0, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			pairs = append(pairs, format(it.Key())+"="+format(it.Value()))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
+// validate
+//
+// checks the value against the required, min, max and oneof tags of the field. Numbers are
+// compared to min and max, durations too, and strings, lists and maps by their length; oneof
+// lists the allowed values separated by spaces and is not checked on empty values.
+func (f *field) validate() error {
+	var errs []error
+	violation := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s (%s): %s", f.key(), f.env(), fmt.Sprintf(format, args...)))
+	}
+	if required, _ := strconv.ParseBool(f.tag.Get("required")); required && f.empty() {
+		violation("is required")
+	}
+	for _, bound := range []string{"min", "max"} {
+		limit, ok := f.tag.Lookup(bound)
+		if !ok {
+			continue
+		}
+		actual, expected, err := f.measure(limit)
+		if err != nil {
+			violation("invalid %s tag %q: %v", bound, limit, err)
+			continue
+		}
+		noun := ""
+		if f.sized() {
+			noun = " in length"
+		}
+		if bound == "min" && actual < expected {
+			violation("must be at least %s%s", limit, noun)
+		}
+		if bound == "max" && actual > expected {
+			violation("must be at most %s%s", limit, noun)
+		}
+	}
+	if options, ok := f.tag.Lookup("oneof"); ok && !f.empty() {
+		allowed := strings.Fields(options)
+		value := format(f.value)
+		found := false
+		for _, option := range allowed {
+			found = found || option == value
+		}
+		if !found {
+			violation("%q is not one of %s", value, strings.Join(allowed, ", "))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compare
+//
+// checks the value against that of the sibling field named by the atMost tag, so that a
+// minimum does not exceed its maximum.
+func (f *field) compare(fields []*field) error {
+	name, ok := f.tag.Lookup("atMost")
+	if !ok {
+		return nil
+	}
+	parent := f.path[:len(f.path)-1]
+	for _, other := range fields {
+		if !slices.Equal(other.path, append(slices.Clone(parent), name)) {
+			continue
+		}
+		actual, ok := number(f.value)
+		limit, comparable := number(other.value)
+		if !ok || !comparable {
+			return fmt.Errorf("%s (%s): invalid atMost tag %q: %s values cannot be compared", f.key(), f.env(), name, typeName(f.value.Type()))
+		}
+		if actual > limit {
+			return fmt.Errorf("%s (%s): must be at most %s (%s), %s", f.key(), f.env(), other.key(), other.env(), format(other.value))
+		}
+		return nil
+	}
+	return fmt.Errorf("%s (%s): invalid atMost tag %q: no such field", f.key(), f.env(), name)
+}
+
+func (f *field) empty() bool {
+	if f.sized() {
+		return f.value.Len() == 0
+	}
+	return f.value.IsZero()
+}
+
+// sized
+//
+// whether the field is bounded by its length rather than its value.
+func (f *field) sized() bool {
+	switch f.value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// measure
+//
+// the value of the field and the limit of a min or max tag, as comparable numbers.
+func (f *field) measure(limit string) (float64, float64, error) {
+	if f.sized() {
+		n, err := strconv.Atoi(limit)
+		return float64(f.value.Len()), float64(n), err
+	}
+	bound, err := parse(f.value.Type(), limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	actual, ok := number(f.value)
+	expected, _ := number(bound)
+	if !ok {
+		return 0, 0, fmt.Errorf("%s values cannot be compared", typeName(f.value.Type()))
+	}
+	return actual, expected, nil
+}
+
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
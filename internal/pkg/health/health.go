@@ -0,0 +1,145 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Probe
+//
+// the question a check answers: Liveness whether the process works at all and should be
+// restarted otherwise, Readiness whether it can serve requests right now.
+type Probe string
+
+const (
+	Liveness  Probe = "liveness"
+	Readiness Probe = "readiness"
+)
+
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+)
+
+// Check
+//
+// returns nil when the component is healthy; it must honor the cancellation of ctx.
+type Check func(ctx context.Context) error
+
+// Checker
+//
+// implemented by the components that can check their own dependencies, e.g. file backed
+// repositories and publishers.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+type Result struct {
+	Status    Status  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report
+//
+// Status fails when any check fails.
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type check struct {
+	name string
+	run  Check
+}
+
+// Registry
+//
+// the checks of every probe, run concurrently, each bounded by the timeout.
+type Registry struct {
+	timeout time.Duration
+	mu      sync.RWMutex
+	checks  map[Probe][]check
+}
+
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		timeout: timeout,
+		checks:  make(map[Probe][]check),
+	}
+}
+
+// Register
+//
+// panics when probe already has a check with the same name, like metrics.Registry.
+func (r *Registry) Register(probe Probe, name string, run Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.checks[probe] {
+		if c.name == name {
+			panic(fmt.Sprintf("health: %s check %q already registered", probe, name))
+		}
+	}
+	r.checks[probe] = append(r.checks[probe], check{name: name, run: run})
+	sort.Slice(r.checks[probe], func(i, j int) bool { return r.checks[probe][i].name < r.checks[probe][j].name })
+}
+
+// Run
+//
+// runs the checks of probe; a check still running when its timeout expires fails.
+func (r *Registry) Run(ctx context.Context, probe Probe) Report {
+	r.mu.RLock()
+	checks := r.checks[probe]
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, c.run)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusPass, Checks: make(map[string]Result, len(checks))}
+	for i, c := range checks {
+		if results[i].Status == StatusFail {
+			report.Status = StatusFail
+		}
+		report.Checks[c.name] = results[i]
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, run Check) Result {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// the check does not honor ctx: report it without waiting any longer
+		err = fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+	result := Result{
+		Status:    StatusPass,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status, result.Error = StatusFail, err.Error()
+	}
+	return result
+}
@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryRun(t *testing.T) {
+	reg := NewRegistry(50 * time.Millisecond)
+	reg.Register(Readiness, "changes", func(ctx context.Context) error { return nil })
+	reg.Register(Liveness, "worker", func(ctx context.Context) error { return nil })
+	assert.Panics(t, func() { reg.Register(Readiness, "changes", func(ctx context.Context) error { return nil }) })
+
+	report := reg.Run(context.Background(), Readiness)
+	assert.Equal(t, StatusPass, report.Status)
+	assert.Equal(t, []string{"changes"}, keys(report))
+
+	reg.Register(Readiness, "publisher", func(ctx context.Context) error { return errors.New("connection refused") })
+	// a check ignoring ctx fails on timeout, without holding the report
+	reg.Register(Readiness, "stuck", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	start := time.Now()
+	report = reg.Run(context.Background(), Readiness)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, StatusPass, report.Checks["changes"].Status)
+	assert.Equal(t, Result{Status: StatusFail, LatencyMs: report.Checks["publisher"].LatencyMs, Error: "connection refused"}, report.Checks["publisher"])
+	assert.Equal(t, StatusFail, report.Checks["stuck"].Status)
+	assert.Contains(t, report.Checks["stuck"].Error, "timed out")
+	assert.GreaterOrEqual(t, report.Checks["stuck"].LatencyMs, float64(50))
+
+	assert.Equal(t, StatusPass, reg.Run(context.Background(), Liveness).Status)
+}
+
+func keys(report Report) []string {
+	names := make([]string, 0, len(report.Checks))
+	for name := range report.Checks {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,329 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind
+//
+// the TYPE of a metric family in the Prometheus text format.
+type Kind string
+
+const (
+	KindCounter   Kind = "counter"
+	KindGauge     Kind = "gauge"
+	KindHistogram Kind = "histogram"
+)
+
+// DefaultBuckets
+//
+// the upper bounds, in seconds, of the latency histograms.
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Collector
+//
+// a metric family, written in the Prometheus text exposition format (version 0.0.4).
+type Collector interface {
+	Name() string
+	Collect(w *Writer)
+}
+
+// Registry
+//
+// the collectors exposed together; safe for concurrent use.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Default
+//
+// the registry of the process, exposed at GET /metrics.
+var Default = NewRegistry()
+
+// RepositoryOperations
+//
+// the latency of the operations of the repositories, by repository and operation.
+var RepositoryOperations = NewHistogramVec("repository_operation_duration_seconds",
+	"Latency of repository operations.", DefaultBuckets, "repository", "operation")
+
+func init() {
+	Default.MustRegister(RuntimeCollectors()...)
+	Default.MustRegister(RepositoryOperations)
+}
+
+// MustRegister
+//
+// adds collectors to r, panicking when a name is already taken: metrics are registered once,
+// at startup.
+func (r *Registry) MustRegister(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range collectors {
+		if _, ok := r.collectors[c.Name()]; ok {
+			panic("metrics: duplicate metric " + c.Name())
+		}
+		r.collectors[c.Name()] = c
+	}
+}
+
+// Write
+//
+// writes every family to out, sorted by name.
+func (r *Registry) Write(out io.Writer) error {
+	r.mu.RLock()
+	collectors := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mu.RUnlock()
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].Name() < collectors[j].Name() })
+	w := &Writer{buf: bufio.NewWriter(out)}
+	for _, c := range collectors {
+		c.Collect(w)
+	}
+	return w.buf.Flush()
+}
+
+// Writer
+//
+// writes the samples of the families; write errors surface on Registry.Write.
+type Writer struct {
+	buf *bufio.Writer
+}
+
+func (w *Writer) header(name, help string, kind Kind) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample
+//
+// writes one line; labels alternate names and values.
+func (w *Writer) sample(name string, value float64, labels ...string) {
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString(labels[i])
+			w.buf.WriteString(`="`)
+			w.buf.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1]))
+			w.buf.WriteByte('"')
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(formatFloat(value))
+	w.buf.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) Name() string {
+	return d.name
+}
+
+// pairs
+//
+// zips the label names of d with values.
+func (d desc) pairs(values []string, extra ...string) []string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	pairs := make([]string, 0, 2*len(values)+len(extra))
+	for i, v := range values {
+		pairs = append(pairs, d.labels[i], v)
+	}
+	return append(pairs, extra...)
+}
+
+// seriesKey
+//
+// identifies the series of label values; \xff never occurs in valid UTF-8.
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// Vec
+//
+// a counter or gauge family with a series for every combination of label values.
+type Vec struct {
+	desc
+	kind   Kind
+	mu     sync.Mutex
+	series map[string]*vecSeries
+}
+
+type vecSeries struct {
+	labels []string
+	value  float64
+}
+
+func NewCounterVec(name, help string, labels ...string) *Vec {
+	return &Vec{desc: desc{name: name, help: help, labels: labels}, kind: KindCounter, series: make(map[string]*vecSeries)}
+}
+
+func NewGaugeVec(name, help string, labels ...string) *Vec {
+	return &Vec{desc: desc{name: name, help: help, labels: labels}, kind: KindGauge, series: make(map[string]*vecSeries)}
+}
+
+func (v *Vec) Inc(labels ...string) {
+	v.Add(1, labels...)
+}
+
+// Add
+//
+// adds delta to the series of labels; counters only ever grow, so negative deltas are
+// reserved to gauges.
+func (v *Vec) Add(delta float64, labels ...string) {
+	v.update(labels, func(s *vecSeries) { s.value += delta })
+}
+
+func (v *Vec) Set(value float64, labels ...string) {
+	v.update(labels, func(s *vecSeries) { s.value = value })
+}
+
+func (v *Vec) update(labels []string, change func(s *vecSeries)) {
+	v.pairs(labels)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key := seriesKey(labels)
+	s, ok := v.series[key]
+	if !ok {
+		s = &vecSeries{labels: append([]string(nil), labels...)}
+		v.series[key] = s
+	}
+	change(s)
+}
+
+func (v *Vec) Collect(w *Writer) {
+	w.header(v.name, v.help, v.kind)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, key := range sortedKeys(v.series) {
+		s := v.series[key]
+		w.sample(v.name, s.value, v.pairs(s.labels)...)
+	}
+}
+
+// HistogramVec
+//
+// a histogram family with a series for every combination of label values.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{desc: desc{name: name, help: help, labels: labels}, buckets: buckets, series: make(map[string]*histogramSeries)}
+}
+
+func (h *HistogramVec) Observe(value float64, labels ...string) {
+	h.pairs(labels)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := seriesKey(labels)
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{labels: append([]string(nil), labels...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+// Since
+//
+// observes the seconds elapsed since start, e.g. deferred at the beginning of an operation.
+func (h *HistogramVec) Since(start time.Time, labels ...string) {
+	h.Observe(time.Since(start).Seconds(), labels...)
+}
+
+func (h *HistogramVec) Collect(w *Writer) {
+	w.header(h.name, h.help, KindHistogram)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		cumulative := uint64(0)
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			w.sample(h.name+"_bucket", float64(cumulative), h.pairs(s.labels, "le", formatFloat(bound))...)
+		}
+		w.sample(h.name+"_bucket", float64(s.count), h.pairs(s.labels, "le", "+Inf")...)
+		w.sample(h.name+"_sum", s.sum, h.pairs(s.labels)...)
+		w.sample(h.name+"_count", float64(s.count), h.pairs(s.labels)...)
+	}
+}
+
+// Func
+//
+// a counter or gauge family whose samples are computed at every scrape, for values
+// maintained elsewhere.
+type Func struct {
+	desc
+	kind    Kind
+	collect func(emit func(value float64, labels ...string))
+}
+
+func NewFunc(kind Kind, name, help string, labels []string, collect func(emit func(value float64, labels ...string))) *Func {
+	return &Func{desc: desc{name: name, help: help, labels: labels}, kind: kind, collect: collect}
+}
+
+func (f *Func) Collect(w *Writer) {
+	w.header(f.name, f.help, f.kind)
+	f.collect(func(value float64, labels ...string) {
+		w.sample(f.name, value, f.pairs(labels)...)
+	})
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWritesTextFormat(t *testing.T) {
+	reg := NewRegistry()
+	requests := NewCounterVec("requests_total", "Requests.\nServed.", "route", "status")
+	inFlight := NewGaugeVec("in_flight", "In flight.")
+	latency := NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	tenants := NewFunc(KindGauge, "tenants", "Tenants.", []string{"tenant"}, func(emit func(float64, ...string)) {
+		emit(2, `a"b\c`)
+	})
+	reg.MustRegister(requests, inFlight, latency, tenants)
+	assert.Panics(t, func() { reg.MustRegister(NewGaugeVec("in_flight", "Again.")) })
+
+	requests.Inc("GET /b", "200")
+	requests.Add(2, "GET /a", "500")
+	inFlight.Add(1)
+	inFlight.Add(-1)
+	latency.Observe(0.05, "GET /a")
+	latency.Observe(0.5, "GET /a")
+	latency.Observe(5, "GET /a")
+	assert.Panics(t, func() { requests.Inc("GET /a") })
+
+	var out strings.Builder
+	require.NoError(t, reg.Write(&out))
+	assert.Equal(t, `# HELP in_flight In flight.
+# TYPE in_flight gauge
+in_flight 0
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="GET /a",le="0.1"} 1
+latency_seconds_bucket{route="GET /a",le="1"} 2
+latency_seconds_bucket{route="GET /a",le="+Inf"} 3
+latency_seconds_sum{route="GET /a"} 5.55
+latency_seconds_count{route="GET /a"} 3
+# HELP requests_total Requests.\nServed.
+# TYPE requests_total counter
+requests_total{route="GET /a",status="500"} 2
+requests_total{route="GET /b",status="200"} 1
+# HELP tenants Tenants.
+# TYPE tenants gauge
+tenants{tenant="a\"b\\c"} 2
+`, out.String())
+}
+
+func TestDefaultRegistryExposesRuntime(t *testing.T) {
+	var out strings.Builder
+	require.NoError(t, Default.Write(&out))
+	assert.Contains(t, out.String(), "\ngo_goroutines ")
+	assert.Contains(t, out.String(), "# TYPE go_gc_cycles_total counter\n")
+	assert.Contains(t, out.String(), "# TYPE repository_operation_duration_seconds histogram\n")
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"runtime"
+	"time"
+)
+
+var startTime = time.Now()
+
+// RuntimeCollectors
+//
+// the Go runtime and process statistics, with the names used by the official Prometheus client.
+func RuntimeCollectors() []Collector {
+	memStats := func(read func(m *runtime.MemStats) float64) func(emit func(value float64, labels ...string)) {
+		return func(emit func(value float64, labels ...string)) {
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			emit(read(&m))
+		}
+	}
+	return []Collector{
+		NewFunc(KindGauge, "go_info", "Information about the Go environment.", []string{"version"}, func(emit func(float64, ...string)) {
+			emit(1, runtime.Version())
+		}),
+		NewFunc(KindGauge, "go_goroutines", "Number of goroutines that currently exist.", nil, func(emit func(float64, ...string)) {
+			emit(float64(runtime.NumGoroutine()))
+		}),
+		NewFunc(KindGauge, "go_threads", "Number of OS threads created.", nil, func(emit func(float64, ...string)) {
+			threads, _ := runtime.ThreadCreateProfile(nil)
+			emit(float64(threads))
+		}),
+		NewFunc(KindGauge, "go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", nil,
+			memStats(func(m *runtime.MemStats) float64 { return float64(m.HeapAlloc) })),
+		NewFunc(KindGauge, "go_memstats_heap_objects", "Number of allocated objects.", nil,
+			memStats(func(m *runtime.MemStats) float64 { return float64(m.HeapObjects) })),
+		NewFunc(KindGauge, "go_memstats_sys_bytes", "Number of bytes obtained from system.", nil,
+			memStats(func(m *runtime.MemStats) float64 { return float64(m.Sys) })),
+		NewFunc(KindCounter, "go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", nil,
+			memStats(func(m *runtime.MemStats) float64 { return float64(m.TotalAlloc) })),
+		NewFunc(KindCounter, "go_gc_cycles_total", "Number of completed GC cycles.", nil,
+			memStats(func(m *runtime.MemStats) float64 { return float64(m.NumGC) })),
+		NewFunc(KindCounter, "go_gc_pause_seconds_total", "Total time the world was stopped by the GC.", nil,
+			memStats(func(m *runtime.MemStats) float64 { return time.Duration(m.PauseTotalNs).Seconds() })),
+		NewFunc(KindGauge, "process_start_time_seconds", "Start time of the process since unix epoch in seconds.", nil, func(emit func(float64, ...string)) {
+			emit(float64(startTime.UnixNano()) / 1e9)
+		}),
+	}
+}
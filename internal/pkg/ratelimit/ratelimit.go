@@ -0,0 +1,187 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limit
+//
+// Rate tokens per second refill a bucket holding Burst requests (Rate rounded up when zero),
+// and Concurrency caps the requests in flight. Zero values disable the respective limit.
+type Limit struct {
+	Rate        float64 `json:"rate"`
+	Burst       int     `json:"burst,omitempty"`
+	Concurrency int     `json:"concurrency,omitempty"`
+}
+
+func (l Limit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return max(1, math.Ceil(l.Rate))
+}
+
+// Limits
+//
+// Read applies to safe methods and Write to the others, each sharing one bucket per client;
+// Routes override them with a bucket of their own, by ServeMux pattern (e.g. "PUT /objects/{bucketId}/{objectId}").
+// AuthFailures limits the failed authentications of every client address.
+type Limits struct {
+	Read         Limit            `json:"read"`
+	Write        Limit            `json:"write"`
+	Routes       map[string]Limit `json:"routes,omitempty"`
+	AuthFailures Limit            `json:"authFailures"`
+}
+
+// For
+//
+// the limit of a request to route with method, and the name of the bucket it draws from.
+func (l Limits) For(route, method string) (string, Limit) {
+	if limit, ok := l.Routes[route]; ok {
+		return route, limit
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return "read", l.Read
+	default:
+		return "write", l.Write
+	}
+}
+
+// Decision
+//
+// the outcome of Limiter.Take. Limit is zero when the request is not rate limited; Reset is
+// the time until the bucket is full again and RetryAfter, for denied requests, until the
+// next one is allowed.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter
+//
+// token buckets and in-flight counters by key; safe for concurrent use.
+type Limiter struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	inFlight map[string]int
+	now      func() time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets:  make(map[string]*bucket),
+		inFlight: make(map[string]int),
+		now:      time.Now,
+	}
+}
+
+// Take
+//
+// consumes a token of the bucket of key, refilled according to limit. A changed limit
+// applies to the existing bucket, so that reloads do not reset the clients.
+func (l *Limiter) Take(key string, limit Limit) Decision {
+	return l.take(key, limit, true)
+}
+
+// Peek
+//
+// like Take, without consuming the token: whether a request would be allowed.
+func (l *Limiter) Peek(key string, limit Limit) Decision {
+	return l.take(key, limit, false)
+}
+
+func (l *Limiter) take(key string, limit Limit, consume bool) Decision {
+	if limit.Rate <= 0 {
+		return Decision{Allowed: true}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b := l.refill(key, limit, now)
+	decision := Decision{Limit: int(limit.burst())}
+	if b.tokens >= 1 {
+		if consume {
+			b.tokens--
+		}
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = seconds((limit.burst() - b.tokens) / limit.Rate)
+	return decision
+}
+
+// Acquire
+//
+// reserves one of the limit.Concurrency slots of key, returning the function releasing it;
+// ok is false when every slot is taken.
+func (l *Limiter) Acquire(key string, limit Limit) (release func(), ok bool) {
+	if limit.Concurrency <= 0 {
+		return func() {}, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[key] >= limit.Concurrency {
+		return nil, false
+	}
+	l.inFlight[key]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.inFlight[key]--; l.inFlight[key] <= 0 {
+				delete(l.inFlight, key)
+			}
+		})
+	}, true
+}
+
+// Sweep
+//
+// forgets the buckets untouched for idle, which are full again unless their rate is very low,
+// returning how many were removed.
+func (l *Limiter) Sweep(idle time.Duration) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	swept := 0
+	for key, b := range l.buckets {
+		if l.now().Sub(b.last) >= idle {
+			delete(l.buckets, key)
+			swept++
+		}
+	}
+	return swept
+}
+
+// refill
+//
+// callers must hold l.mu.
+func (l *Limiter) refill(key string, limit Limit, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit.burst(), last: now}
+		l.buckets[key] = b
+	}
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = min(limit.burst(), b.tokens+max(0, elapsed)*limit.Rate)
+	b.last = now
+	return b
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterTake(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter()
+	l.now = func() time.Time { return now }
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		d := l.Take("a", limit)
+		require.True(t, d.Allowed)
+		assert.Equal(t, 3, d.Limit)
+		assert.Equal(t, remaining, d.Remaining)
+	}
+	denied := l.Take("a", limit)
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, 500*time.Millisecond, denied.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, denied.Reset)
+	assert.True(t, l.Take("b", limit).Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, l.Take("a", limit).Allowed)
+	assert.False(t, l.Take("a", limit).Allowed)
+
+	// a lower burst applies to the existing bucket
+	now = now.Add(time.Hour)
+	assert.Equal(t, 0, l.Take("a", Limit{Rate: 2, Burst: 1}).Remaining)
+	assert.True(t, l.Take("unlimited", Limit{}).Allowed)
+
+	assert.Equal(t, 1, l.Sweep(time.Minute))
+}
+
+func TestLimiterAcquire(t *testing.T) {
+	l := NewLimiter()
+	limit := Limit{Concurrency: 2}
+	first, ok := l.Acquire("a", limit)
+	require.True(t, ok)
+	_, ok = l.Acquire("a", limit)
+	require.True(t, ok)
+	_, ok = l.Acquire("a", limit)
+	assert.False(t, ok)
+	_, ok = l.Acquire("b", limit)
+	assert.True(t, ok)
+
+	first()
+	first()
+	_, ok = l.Acquire("a", limit)
+	assert.True(t, ok)
+	_, ok = l.Acquire("a", limit)
+	assert.False(t, ok)
+}
+
+func TestLimitsFor(t *testing.T) {
+	limits := Limits{
+		Read:   Limit{Rate: 10},
+		Write:  Limit{Rate: 1},
+		Routes: map[string]Limit{"POST /batch": {Rate: 0.1}},
+	}
+	name, limit := limits.For("GET /objects/{bucketId}/{objectId}", http.MethodGet)
+	assert.Equal(t, "read", name)
+	assert.Equal(t, 10.0, limit.Rate)
+	name, _ = limits.For("PUT /objects/{bucketId}/{objectId}", http.MethodPut)
+	assert.Equal(t, "write", name)
+	name, limit = limits.For("POST /batch", http.MethodPost)
+	assert.Equal(t, "POST /batch", name)
+	assert.Equal(t, 1.0, limit.burst())
+}
@@ -0,0 +1,47 @@
+package tenant
+
+import (
+	"context"
+	"regexp"
+
+	"bucket_organizer/internal/pkg/types"
+	"bucket_organizer/pkg/logger"
+)
+
+// Default
+//
+// the tenant of requests that name none, and of principals without a tenant.
+const Default = "default"
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+type tenantKey struct{}
+
+// WithTenant
+//
+// scopes ctx to the tenant id: repositories key buckets, policies and webhooks by it, and
+// logs carry it.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(context.WithValue(ctx, tenantKey{}, id), logger.Tenant, id)
+}
+
+// FromContext
+//
+// the tenant of ctx, Default when none was set.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(tenantKey{}).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}
+
+// Validate
+//
+// reports id as an invalid param named field unless it is made of lowercase letters,
+// digits, "-" and "_", at most 63 characters long.
+func Validate(field, id string) []types.InvalidParam {
+	if !idPattern.MatchString(id) {
+		return []types.InvalidParam{{Name: field, Reason: "must be a lowercase tenant id of at most 63 letters, digits, - or _"}}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+)
+
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+	// the only traceparent version defined by W3C Trace Context
+	traceparentVersion = "00"
+	flagSampled        = 0x01
+)
+
+type TraceId [16]byte
+
+type SpanId [8]byte
+
+func (id TraceId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id TraceId) IsValid() bool {
+	return id != TraceId{}
+}
+
+func (id SpanId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanId) IsValid() bool {
+	return id != SpanId{}
+}
+
+func newTraceId() TraceId {
+	var id TraceId
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanId() SpanId {
+	var id SpanId
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+
+// SpanContext
+//
+// the part of a span propagated across process boundaries.
+type SpanContext struct {
+	TraceId    TraceId
+	SpanId     SpanId
+	Sampled    bool
+	TraceState string
+	Remote     bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceId.IsValid() && sc.SpanId.IsValid()
+}
+
+// Traceparent
+//
+// the W3C traceparent header value of sc.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return traceparentVersion + "-" + sc.TraceId.String() + "-" + sc.SpanId.String() + "-" + flags
+}
+
+// ParseTraceparent
+//
+// parses a W3C traceparent header value; ok is false when it is malformed or names the
+// invalid all-zero trace or parent.
+func ParseTraceparent(value string) (sc SpanContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == traceparentVersion && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	// fields appended by later versions are ignored
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 || !isLowerHex(strings.Join(parts[:4], "")) {
+		return SpanContext{}, false
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceId[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanId[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&flagSampled != 0
+	sc.Remote = true
+	return sc, sc.IsValid()
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+type remoteKey struct{}
+
+// Extract
+//
+// returns ctx carrying the span context propagated in header, the parent of the next span
+// started from it. Malformed headers are ignored, starting a new trace.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := ParseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+	sc.TraceState = header.Get(TracestateHeader)
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Inject
+//
+// propagates the span of ctx in header, e.g. on outgoing requests.
+func Inject(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	sc := span.SpanContext()
+	header.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	}
+}
@@ -0,0 +1,172 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"bucket_organizer/pkg/logger"
+)
+
+// SpanKind
+//
+// the role of a span, numbered as in OTLP.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// StatusCode
+//
+// the outcome of a span, numbered as in OTLP.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOk    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData
+//
+// a finished span, as handed to the Exporter.
+type SpanData struct {
+	SpanContext   SpanContext
+	Parent        SpanId
+	Name          string
+	Kind          SpanKind
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Span
+//
+// a timed operation of a trace; safe for concurrent use. Spans that are not sampled still
+// carry the trace context, so that logs and downstream services stay correlated.
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+type spanKey struct{}
+
+// Start
+//
+// starts a span of kind KindInternal, child of the span of ctx; see Tracer.Start.
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	return StartKind(ctx, KindInternal, name, attributes...)
+}
+
+// StartKind
+//
+// starts a span with the tracer of the parent span, the default tracer for new traces.
+func StartKind(ctx context.Context, kind SpanKind, name string, attributes ...Attribute) (context.Context, *Span) {
+	tracer := Default()
+	if parent := SpanFromContext(ctx); parent != nil {
+		tracer = parent.tracer
+	}
+	return tracer.Start(ctx, kind, name, attributes...)
+}
+
+// discard
+//
+// the tracer of the spans StartChild does not record.
+var discard = NewTracer(nil, 0)
+
+// StartChild
+//
+// like StartKind, but only within a trace: outside of one, e.g. in a background poll, the
+// returned span is neither sampled nor carried by ctx.
+func StartChild(ctx context.Context, kind SpanKind, name string, attributes ...Attribute) (context.Context, *Span) {
+	if SpanFromContext(ctx) == nil {
+		return ctx, &Span{tracer: discard}
+	}
+	return StartKind(ctx, kind, name, attributes...)
+}
+
+// SpanFromContext
+//
+// the current span of ctx, nil when none was started.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// contextWithSpan
+//
+// also sets the ids of the span for logger, so that log lines carry them.
+func contextWithSpan(ctx context.Context, span *Span) context.Context {
+	ctx = context.WithValue(ctx, spanKey{}, span)
+	ctx = context.WithValue(ctx, logger.TraceId, span.data.SpanContext.TraceId.String())
+	return context.WithValue(ctx, logger.SpanId, span.data.SpanContext.SpanId.String())
+}
+
+func (s *Span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attributes...)
+}
+
+func (s *Span) SetStatus(code StatusCode, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Status, s.data.StatusMessage = code, message
+}
+
+// Fail
+//
+// marks the span as failed with err, when not nil, and returns err, so that it can wrap the
+// errors returned by the traced operation.
+func (s *Span) Fail(err error) error {
+	if err != nil {
+		s.SetStatus(StatusError, err.Error())
+	}
+	return err
+}
+
+// End
+//
+// finishes the span, handing it to the exporter when sampled; later calls are no-ops.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = s.tracer.now()
+	data := s.data
+	s.mu.Unlock()
+	if data.SpanContext.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
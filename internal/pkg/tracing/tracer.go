@@ -0,0 +1,186 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bucket_organizer/pkg/logger"
+)
+
+const (
+	maxQueuedSpans = 2048
+	exportBatch    = 512
+	exportInterval = 5 * time.Second
+)
+
+// Exporter
+//
+// ships finished spans out of the process.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Tracer
+//
+// starts spans and queues the sampled ones for its exporter, in batches. Without an
+// exporter spans are only used to propagate the trace context.
+type Tracer struct {
+	exporter Exporter
+	ratio    float64
+	now      func() time.Time
+	mu       sync.Mutex
+	queue    []SpanData
+	dropped  int
+	full     chan struct{}
+}
+
+// NewTracer
+//
+// ratio is the fraction of new traces sampled; spans continuing a trace follow the sampling
+// decision of their parent, local or remote.
+func NewTracer(exporter Exporter, ratio float64) *Tracer {
+	return &Tracer{
+		exporter: exporter,
+		ratio:    ratio,
+		now:      time.Now,
+		full:     make(chan struct{}, 1),
+	}
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(NewTracer(nil, 1))
+}
+
+// Default
+//
+// the tracer starting new traces, see SetDefault.
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// Start
+//
+// starts a span, child of the span of ctx, or of the remote span propagated to ctx by Extract.
+// The returned context carries the span.
+func (t *Tracer) Start(ctx context.Context, kind SpanKind, name string, attributes ...Attribute) (context.Context, *Span) {
+	sc := SpanContext{SpanId: newSpanId()}
+	var parent SpanContext
+	if span := SpanFromContext(ctx); span != nil {
+		parent = span.SpanContext()
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		parent = remote
+	}
+	if parent.IsValid() {
+		sc.TraceId, sc.Sampled, sc.TraceState = parent.TraceId, parent.Sampled, parent.TraceState
+	} else {
+		sc.TraceId = newTraceId()
+		sc.Sampled = t.sample(sc.TraceId)
+	}
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			SpanContext: sc,
+			Parent:      parent.SpanId,
+			Name:        name,
+			Kind:        kind,
+			Start:       t.now(),
+			Attributes:  attributes,
+		},
+	}
+	return contextWithSpan(ctx, span), span
+}
+
+// sample
+//
+// decides on the trace id alone, so that every service sampling at the same ratio agrees.
+func (t *Tracer) sample(id TraceId) bool {
+	switch {
+	case t.ratio >= 1:
+		return true
+	case t.ratio <= 0:
+		return false
+	default:
+		return binary.BigEndian.Uint64(id[8:])>>1 < uint64(t.ratio*(math.MaxUint64>>1))
+	}
+}
+
+func (t *Tracer) enqueue(span SpanData) {
+	if t.exporter == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) >= maxQueuedSpans {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, span)
+	if len(t.queue) >= exportBatch {
+		select {
+		case t.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// RunExporter
+//
+// exports the queued spans every few seconds, or as soon as a batch is full, until ctx is done.
+func (t *Tracer) RunExporter(ctx context.Context) {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-t.full:
+		}
+		if err := t.Flush(ctx); err != nil {
+			logger.Error(ctx, "error exporting spans", err)
+		}
+	}
+}
+
+// Flush
+//
+// exports the queued spans; they are dropped when the export fails, like those arriving
+// while the queue is full.
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	spans, dropped := t.queue, t.dropped
+	t.queue, t.dropped = nil, 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		logger.Info(ctx, "span queue full, spans dropped", logger.NewLogValue("dropped", dropped))
+	}
+	if len(spans) == 0 || t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Export(ctx, spans)
+}
+
+// Shutdown
+//
+// exports the spans still queued, then releases the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	err := t.Flush(ctx)
+	if shutdownErr := t.exporter.Shutdown(ctx); err == nil {
+		err = shutdownErr
+	}
+	return err
+}
@@ -0,0 +1,192 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bucket_organizer/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceId.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanId.String())
+	assert.True(t, sc.Sampled)
+	assert.True(t, sc.Remote)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+
+	sc, ok = ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.True(t, ok)
+	assert.False(t, sc.Sampled)
+	// later versions may append fields
+	_, ok = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	assert.True(t, ok)
+
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+	} {
+		_, ok := ParseTraceparent(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestTracerParentsAndExports(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(exporter, 1)
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set(TracestateHeader, "vendor=value")
+	ctx := Extract(context.Background(), header)
+
+	ctx, server := tracer.Start(ctx, KindServer, "GET /objects/{bucketId}/{objectId}")
+	assert.Equal(t, server.SpanContext().TraceId.String(), ctx.Value(logger.TraceId))
+	assert.Equal(t, server.SpanContext().SpanId.String(), ctx.Value(logger.SpanId))
+	childCtx, child := StartKind(ctx, KindClient, "bucket.get_object", String("db.operation", "get_object"))
+	assert.Equal(t, child, SpanFromContext(childCtx))
+	assert.Equal(t, errNotFound, child.Fail(errNotFound))
+	child.End()
+	child.End()
+	server.End()
+
+	out := http.Header{}
+	Inject(childCtx, out)
+	assert.Equal(t, child.SpanContext().Traceparent(), out.Get(TraceparentHeader))
+	assert.Equal(t, "vendor=value", out.Get(TracestateHeader))
+
+	require.NoError(t, tracer.Flush(context.Background()))
+	spans := exporter.Spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "bucket.get_object", spans[0].Name)
+	assert.Equal(t, StatusError, spans[0].Status)
+	assert.Equal(t, "not found", spans[0].StatusMessage)
+	assert.Equal(t, server.SpanContext().SpanId, spans[0].Parent)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[1].SpanContext.TraceId.String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[1].Parent.String())
+	assert.Equal(t, KindServer, spans[1].Kind)
+	assert.Equal(t, spans[1].SpanContext.TraceId, spans[0].SpanContext.TraceId)
+}
+
+var errNotFound = errors.New("not found")
+
+func TestTracerSampling(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	never := NewTracer(exporter, 0)
+
+	ctx, root := never.Start(context.Background(), KindServer, "root")
+	_, child := never.Start(ctx, KindInternal, "child")
+	child.End()
+	root.End()
+	assert.False(t, root.SpanContext().Sampled)
+	// unsampled spans still carry the trace
+	assert.Equal(t, root.SpanContext().TraceId, child.SpanContext().TraceId)
+
+	// the decision of the remote parent wins over the ratio
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, remote := never.Start(Extract(context.Background(), header), KindServer, "remote")
+	remote.End()
+	require.NoError(t, never.Flush(context.Background()))
+	require.Len(t, exporter.Spans(), 1)
+	assert.Equal(t, "remote", exporter.Spans()[0].Name)
+
+	half := NewTracer(nil, 0.5)
+	sampled := 0
+	for range 1000 {
+		if half.sample(newTraceId()) {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 500, sampled, 100)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body map[string]any
+	var auth string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		auth = r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(data, &body))
+	}))
+	defer collector.Close()
+
+	_, err := NewOTLPExporter("localhost:4318", "svc", nil, time.Second)
+	assert.Error(t, err)
+
+	exporter, err := NewOTLPExporter(collector.URL+"/", "svc", map[string]string{"Authorization": "Bearer abc", "X-Scope": "ops"}, time.Second)
+	require.NoError(t, err)
+	start := time.Unix(1700000000, 5)
+	sc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, exporter.Export(context.Background(), []SpanData{{
+		SpanContext: sc,
+		Name:        "GET /metrics",
+		Kind:        KindServer,
+		Start:       start,
+		End:         start.Add(time.Millisecond),
+		Attributes:  []Attribute{String("http.route", "GET /metrics"), Int("http.response.status_code", 200), Bool("cached", false)},
+		Status:      StatusError,
+	}}))
+	assert.Equal(t, "Bearer abc", auth)
+
+	resourceSpans := body["resourceSpans"].([]any)[0].(map[string]any)
+	assert.Equal(t, map[string]any{"key": "service.name", "value": map[string]any{"stringValue": "svc"}},
+		resourceSpans["resource"].(map[string]any)["attributes"].([]any)[0])
+	span := resourceSpans["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span["traceId"])
+	assert.Equal(t, "00f067aa0ba902b7", span["spanId"])
+	assert.NotContains(t, span, "parentSpanId")
+	assert.Equal(t, float64(KindServer), span["kind"])
+	assert.Equal(t, "1700000000000000005", span["startTimeUnixNano"])
+	assert.Equal(t, "1700000000001000005", span["endTimeUnixNano"])
+	assert.Equal(t, map[string]any{"code": float64(StatusError)}, span["status"])
+	assert.Equal(t, []any{
+		map[string]any{"key": "http.route", "value": map[string]any{"stringValue": "GET /metrics"}},
+		map[string]any{"key": "http.response.status_code", "value": map[string]any{"intValue": "200"}},
+		map[string]any{"key": "cached", "value": map[string]any{"boolValue": false}},
+	}, span["attributes"])
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	exporter, err = NewOTLPExporter(failing.URL, "svc", nil, time.Second)
+	require.NoError(t, err)
+	assert.Error(t, exporter.Export(context.Background(), []SpanData{{SpanContext: sc}}))
+}
+
+func TestStartChild(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(exporter, 1)
+
+	ctx, orphan := StartChild(context.Background(), KindClient, "changes.list")
+	assert.Nil(t, SpanFromContext(ctx))
+	orphan.End()
+
+	ctx, root := tracer.Start(context.Background(), KindServer, "GET /changes")
+	_, child := StartChild(ctx, KindClient, "changes.list")
+	child.End()
+	root.End()
+	require.NoError(t, tracer.Flush(context.Background()))
+	spans := exporter.Spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "changes.list", spans[0].Name)
+	assert.Equal(t, root.SpanContext().SpanId, spans[0].Parent)
+}
@@ -2,7 +2,47 @@ package types
 
 import (
 	"errors"
+	"strings"
 )
 
 var ErrNoBucketFound = errors.New("no bucket found")
 var ErrNoObjectFound = errors.New("no object found")
+var ErrObjectAlreadyExists = errors.New("object already exists")
+var ErrObjectLocked = errors.New("object is locked")
+var ErrBatchAborted = errors.New("batch aborted by a failed operation")
+var ErrTransactionConflict = errors.New("transaction conflict: a concurrent transaction modified the same objects")
+var ErrIdempotencyKeyReused = errors.New("idempotency key already used for a different request")
+var ErrIdempotencyKeyInProgress = errors.New("a request with the same idempotency key is still in progress")
+var ErrNoWebhookFound = errors.New("no webhook found")
+var ErrNoDeliveryFound = errors.New("no delivery found")
+var ErrNoAPIKeyFound = errors.New("no api key found")
+var ErrNoPolicyFound = errors.New("no policy found")
+var ErrNoRoleFound = errors.New("no role found")
+var ErrUnauthenticated = errors.New("missing or invalid credentials")
+var ErrForbidden = errors.New("permission denied")
+var ErrPublisherClosed = errors.New("publisher closed")
+var ErrWatchOverflow = errors.New("watch dropped: consumer too slow")
+var ErrWatchClosed = errors.New("watch closed: server shutting down")
+var ErrTransactionClosed = errors.New("transaction already committed or rolled back")
+var ErrRateLimited = errors.New("rate limit exceeded")
+var ErrOverloaded = errors.New("server overloaded, retry later")
+var ErrRequestTooLarge = errors.New("request body too large")
+
+// ValidationError
+//
+// reports invalid request parameters; rendered as "invalid-params" in ProblemDetails.
+type ValidationError struct {
+	Params []InvalidParam
+}
+
+func NewValidationError(params ...InvalidParam) *ValidationError {
+	return &ValidationError{Params: params}
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, 0, len(e.Params))
+	for _, p := range e.Params {
+		reasons = append(reasons, p.Name+": "+p.Reason)
+	}
+	return "invalid request: " + strings.Join(reasons, ", ")
+}
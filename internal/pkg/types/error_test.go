@@ -14,6 +14,25 @@ func TestErrorVariables(t *testing.T) {
 	}{
 		{"ErrNoObjectFound", ErrNoObjectFound, "no object found"},
 		{"ErrNoBucketFound", ErrNoBucketFound, "no bucket found"},
+		{"ErrNoWebhookFound", ErrNoWebhookFound, "no webhook found"},
+		{"ErrNoDeliveryFound", ErrNoDeliveryFound, "no delivery found"},
+		{"ErrObjectAlreadyExists", ErrObjectAlreadyExists, "object already exists"},
+		{"ErrObjectLocked", ErrObjectLocked, "object is locked"},
+		{"ErrBatchAborted", ErrBatchAborted, "batch aborted by a failed operation"},
+		{"ErrTransactionConflict", ErrTransactionConflict, "transaction conflict: a concurrent transaction modified the same objects"},
+		{"ErrTransactionClosed", ErrTransactionClosed, "transaction already committed or rolled back"},
+		{"ErrIdempotencyKeyReused", ErrIdempotencyKeyReused, "idempotency key already used for a different request"},
+		{"ErrIdempotencyKeyInProgress", ErrIdempotencyKeyInProgress, "a request with the same idempotency key is still in progress"},
+		{"ErrNoAPIKeyFound", ErrNoAPIKeyFound, "no api key found"},
+		{"ErrNoPolicyFound", ErrNoPolicyFound, "no policy found"},
+		{"ErrNoRoleFound", ErrNoRoleFound, "no role found"},
+		{"ErrUnauthenticated", ErrUnauthenticated, "missing or invalid credentials"},
+		{"ErrForbidden", ErrForbidden, "permission denied"},
+		{"ErrPublisherClosed", ErrPublisherClosed, "publisher closed"},
+		{"ErrWatchOverflow", ErrWatchOverflow, "watch dropped: consumer too slow"},
+		{"ErrWatchClosed", ErrWatchClosed, "watch closed: server shutting down"},
+		{"ErrRequestTooLarge", ErrRequestTooLarge, "request body too large"},
+		{"ValidationError", NewValidationError(InvalidParam{Name: "mode", Reason: "required"}), "invalid request: mode: required"},
 	}
 
 	for _, tt := range tests {
@@ -1,10 +1,26 @@
 package types
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
 )
 
+// BlankProblemType
+//
+// the default problem type for errors without dedicated semantics (RFC 9457).
+var BlankProblemType = &url.URL{Scheme: "about", Opaque: "blank"}
+
+// IdempotencyKeyReusedProblemType
+//
+// returned when an Idempotency-Key is sent again with a different request.
+var IdempotencyKeyReusedProblemType = &url.URL{Path: "/problems/idempotency-key-reused"}
+
+// ObjectLockedProblemType
+//
+// returned when object lock (retention or legal hold) prevents a mutation.
+var ObjectLockedProblemType = &url.URL{Path: "/problems/object-locked"}
+
 type ProblemDetails struct {
 	Type          string         `json:"type"`
 	Title         string         `json:"title"`
@@ -29,3 +45,37 @@ func NewProblemDetails(r *http.Request, resourceUrl *url.URL, title, detail stri
 		InvalidParams: append([]InvalidParam{}, params...),
 	}
 }
+
+// NewProblemDetailsFromError
+//
+// maps known application errors to their status code and problem type.
+func NewProblemDetailsFromError(r *http.Request, err error) ProblemDetails {
+	var validationErr *ValidationError
+	switch {
+	case errors.Is(err, ErrNoBucketFound) || errors.Is(err, ErrNoObjectFound) || errors.Is(err, ErrNoWebhookFound) || errors.Is(err, ErrNoAPIKeyFound) ||
+		errors.Is(err, ErrNoPolicyFound) || errors.Is(err, ErrNoRoleFound):
+		return NewProblemDetails(r, BlankProblemType, http.StatusText(http.StatusBadRequest), err.Error(), http.StatusBadRequest)
+	case errors.As(err, &validationErr):
+		return NewProblemDetails(r, BlankProblemType, http.StatusText(http.StatusBadRequest), err.Error(), http.StatusBadRequest, validationErr.Params...)
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		return NewProblemDetails(r, IdempotencyKeyReusedProblemType, http.StatusText(http.StatusUnprocessableEntity), err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, ErrObjectAlreadyExists) || errors.Is(err, ErrTransactionConflict) || errors.Is(err, ErrIdempotencyKeyInProgress):
+		return NewProblemDetails(r, BlankProblemType, http.StatusText(http.StatusConflict), err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrBatchAborted):
+		return NewProblemDetails(r, BlankProblemType, http.StatusText(http.StatusFailedDependency), err.Error(), http.StatusFailedDependency)
+	case errors.Is(err, ErrObjectLocked):
+		return NewProblemDetails(r, ObjectLockedProblemType, "Object Locked", err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrUnauthenticated):
+		return NewProblemDetails(r, BlankProblemType, http.StatusText(http.StatusUnauthorized), err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, ErrForbidden):
+		return NewProblemDetails(r, BlankProblemType, http.StatusText(http.StatusForbidden), err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrRequestTooLarge):
+		return NewProblemDetails(r, BlankProblemType, http.StatusText(http.StatusRequestEntityTooLarge), err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, ErrRateLimited):
+		return NewProblemDetails(r, BlankProblemType, http.StatusText(http.StatusTooManyRequests), err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, ErrWatchClosed) || errors.Is(err, ErrOverloaded):
+		return NewProblemDetails(r, BlankProblemType, http.StatusText(http.StatusServiceUnavailable), err.Error(), http.StatusServiceUnavailable)
+	default:
+		return NewProblemDetails(r, BlankProblemType, http.StatusText(http.StatusInternalServerError), err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -49,3 +49,33 @@ func TestProblemDetailsFromError(t *testing.T) {
 		})
 	}
 }
+
+func TestProblemDetailsFromKnownErrors(t *testing.T) {
+	tests := []struct {
+		err    error
+		name   string
+		typ    string
+		status int
+		params int
+	}{
+		{name: "Object not found", err: ErrNoObjectFound, typ: "about:blank", status: http.StatusBadRequest},
+		{name: "Object already exists", err: ErrObjectAlreadyExists, typ: "about:blank", status: http.StatusConflict},
+		{name: "Object locked", err: ErrObjectLocked, typ: "/problems/object-locked", status: http.StatusConflict},
+		{name: "Idempotency key reused", err: ErrIdempotencyKeyReused, typ: "/problems/idempotency-key-reused", status: http.StatusUnprocessableEntity},
+		{name: "Validation", err: NewValidationError(InvalidParam{Name: "mode"}), typ: "about:blank", status: http.StatusBadRequest, params: 1},
+		{name: "Unauthenticated", err: ErrUnauthenticated, typ: "about:blank", status: http.StatusUnauthorized},
+		{name: "Forbidden", err: ErrForbidden, typ: "about:blank", status: http.StatusForbidden},
+		{name: "Request too large", err: ErrRequestTooLarge, typ: "about:blank", status: http.StatusRequestEntityTooLarge},
+		{name: "Watch closed", err: ErrWatchClosed, typ: "about:blank", status: http.StatusServiceUnavailable},
+		{name: "Unknown", err: errors.New("boom"), typ: "about:blank", status: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewProblemDetailsFromError(httptest.NewRequest("GET", "/test-url", nil), tt.err)
+			if got.Type != tt.typ || got.Status != tt.status || len(got.InvalidParams) != tt.params {
+				t.Errorf("NewProblemDetailsFromError() = %+v, want type %s status %d", got, tt.typ, tt.status)
+			}
+		})
+	}
+}
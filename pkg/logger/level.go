@@ -0,0 +1,220 @@
+package logger
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levels
+//
+// the global level and its overrides per component, the package logging the entry, e.g.
+// "services" or "bucket_organizer/internal/app/services".
+type levels struct {
+	global     zap.AtomicLevel
+	mu         sync.RWMutex
+	components map[string]zapcore.Level
+	// min is the lowest of the levels, below which entries are dropped at once
+	min atomic.Int32
+}
+
+var level = newLevels(zapcore.InfoLevel)
+
+func newLevels(global zapcore.Level) *levels {
+	l := &levels{global: zap.NewAtomicLevelAt(global), components: make(map[string]zapcore.Level)}
+	l.min.Store(int32(global))
+	return l
+}
+
+// reset
+//
+// sets the global level and drops the overrides.
+func (l *levels) reset(global zapcore.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.global.SetLevel(global)
+	l.components = make(map[string]zapcore.Level)
+	l.updateMin()
+}
+
+// updateMin
+//
+// must be called with mu held.
+func (l *levels) updateMin() {
+	min := l.global.Level()
+	for _, lvl := range l.components {
+		if lvl < min {
+			min = lvl
+		}
+	}
+	l.min.Store(int32(min))
+}
+
+func (l *levels) enabled(lvl zapcore.Level) bool {
+	return lvl >= zapcore.Level(l.min.Load())
+}
+
+// of
+//
+// the level applying to the entries logged from function, as reported by runtime.Frame,
+// e.g. "bucket_organizer/internal/app/services.(*BucketService).PurgeExpiredTrash".
+func (l *levels) of(function string) zapcore.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if len(l.components) > 0 {
+		if function == "" {
+			function = callerFunction()
+		}
+		path := function
+		slash := strings.LastIndexByte(path, '/')
+		if dot := strings.IndexByte(path[slash+1:], '.'); dot >= 0 {
+			path = path[:slash+1+dot]
+		}
+		if lvl, ok := l.components[path]; ok {
+			return lvl
+		}
+		if lvl, ok := l.components[path[slash+1:]]; ok {
+			return lvl
+		}
+	}
+	return l.global.Level()
+}
+
+// callerFunction
+//
+// the function logging the entry being written, for the entries logged without caller.
+func callerFunction() string {
+	pc := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pc[:runtime.Callers(3, pc)])
+	for {
+		frame, more := frames.Next()
+		internal := strings.HasPrefix(frame.Function, "go.uber.org/zap") ||
+			strings.HasPrefix(frame.Function, "bucket_organizer/pkg/logger.") && !strings.HasSuffix(frame.File, "_test.go")
+		if !internal {
+			return frame.Function
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// levelCore
+//
+// filters the entries of the wrapped core by the level of the component logging them,
+// known once the caller is added, so only when writing.
+type levelCore struct {
+	zapcore.Core
+	levels *levels
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return c.levels.enabled(lvl)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.levels.enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *levelCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level < c.levels.of(ent.Caller.Function) {
+		return nil
+	}
+	// the wrapped core, a tee, routes the entry by level
+	if checked := c.Core.Check(ent, nil); checked != nil {
+		checked.Write(fields...)
+	}
+	return nil
+}
+
+// ParseLevel
+//
+// checks a level name: debug, info, warn, error, dpanic, panic or fatal, in any case.
+func ParseLevel(text string) (string, error) {
+	lvl, err := parseLevel(text)
+	if err != nil {
+		return "", err
+	}
+	return lvl.String(), nil
+}
+
+func parseLevel(text string) (zapcore.Level, error) {
+	// zap reads an empty level as info
+	lvl, err := zapcore.ParseLevel(strings.ToLower(text))
+	if err != nil || text == "" {
+		return lvl, fmt.Errorf("unknown log level %q", text)
+	}
+	return lvl, nil
+}
+
+// GetLevel
+//
+// the global level, e.g. "info".
+func GetLevel() string {
+	return level.global.Level().String()
+}
+
+// SetLevel
+//
+// sets the global level, applying to the components without a level of their own.
+func SetLevel(text string) error {
+	lvl, err := parseLevel(text)
+	if err != nil {
+		return err
+	}
+	level.mu.Lock()
+	defer level.mu.Unlock()
+	level.global.SetLevel(lvl)
+	level.updateMin()
+	return nil
+}
+
+// SetComponentLevel
+//
+// overrides the global level for component, a package name ("services") or import path.
+func SetComponentLevel(component, text string) error {
+	lvl, err := parseLevel(text)
+	if err != nil {
+		return err
+	}
+	level.mu.Lock()
+	defer level.mu.Unlock()
+	level.components[component] = lvl
+	level.updateMin()
+	return nil
+}
+
+// ResetComponentLevel
+//
+// drops the level of component, which follows the global level again.
+func ResetComponentLevel(component string) {
+	level.mu.Lock()
+	defer level.mu.Unlock()
+	delete(level.components, component)
+	level.updateMin()
+}
+
+// ComponentLevels
+//
+// the components with a level of their own and their level.
+func ComponentLevels() map[string]string {
+	level.mu.RLock()
+	defer level.mu.RUnlock()
+	levels := make(map[string]string, len(level.components))
+	for component, lvl := range level.components {
+		levels[component] = lvl.String()
+	}
+	return levels
+}
@@ -12,22 +12,64 @@ import (
 
 type TraceIdKey string
 
+// TraceId
+//
+// context key of the id of the trace a request belongs to, appended to logs as "traceId".
 const TraceId TraceIdKey = "traceIdKey"
 
+// SpanId
+//
+// context key of the id of the current span, appended to logs like TraceId.
+const SpanId TraceIdKey = "spanIdKey"
+
+// Principal
+//
+// context key of the authenticated caller, appended to logs like TraceId.
+const Principal TraceIdKey = "principalKey"
+
+// Tenant
+//
+// context key of the tenant a request is scoped to, appended to logs like TraceId.
+const Tenant TraceIdKey = "tenantKey"
+
+// Options
+//
+// Level is the global level, info when empty. Console writes human readable lines instead of
+// JSON, for development, where DPanic entries panic too. StacktraceLevel is the lowest level
+// of the entries carrying a stack trace, error when empty.
+type Options struct {
+	Level           string
+	Console         bool
+	StacktraceLevel string
+}
+
 // InitLogger
 //
-// initializes the logger with the given mode.
-// If devMode is true, the logger will use zapcore.DebugLevel level, otherwise zapcore.InfoLevel level.
-func InitLogger(devMode bool) {
-	loggerLevel := zap.NewAtomicLevelAt(zapcore.InfoLevel)
-	if devMode {
-		loggerLevel = zap.NewAtomicLevelAt(zap.DebugLevel)
+// initializes the logger with the given options; see SetLevel and SetComponentLevel to change
+// the level at runtime.
+func InitLogger(options Options) error {
+	global, stacktrace := zapcore.InfoLevel, zapcore.ErrorLevel
+	var err error
+	if options.Level != "" {
+		if global, err = parseLevel(options.Level); err != nil {
+			return err
+		}
+	}
+	if options.StacktraceLevel != "" {
+		if stacktrace, err = parseLevel(options.StacktraceLevel); err != nil {
+			return err
+		}
 	}
+	level.reset(global)
 
+	encoder := zapcore.NewJSONEncoder
+	if options.Console {
+		encoder = zapcore.NewConsoleEncoder
+	}
 	stdoutCore := func() zapcore.Core {
 		stdoutSyncer := zapcore.Lock(os.Stdout)
 		return zapcore.NewCore(
-			zapcore.NewJSONEncoder(encoderConfig()),
+			encoder(encoderConfig()),
 			stdoutSyncer,
 			zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
 				return lvl < zapcore.ErrorLevel // Just Debug, Info, Warn
@@ -38,7 +80,7 @@ func InitLogger(devMode bool) {
 	stderrCore := func() zapcore.Core {
 		stderrSyncer := zapcore.Lock(os.Stderr)
 		return zapcore.NewCore(
-			zapcore.NewJSONEncoder(encoderConfig()),
+			encoder(encoderConfig()),
 			stderrSyncer,
 			zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
 				return lvl >= zapcore.ErrorLevel // Just Error, DPanic, Panic, Fatal
@@ -46,15 +88,16 @@ func InitLogger(devMode bool) {
 		)
 	}
 
-	logger, err := config(loggerLevel).Build(zap.AddCaller(), zap.AddCallerSkip(1), zap.AddStacktrace(zapcore.ErrorLevel), zap.WrapCore(func(core zapcore.Core) zapcore.Core {
-		return zapcore.NewTee(stdoutCore(), stderrCore())
+	logger, err := config(level.global, options.Console).Build(zap.AddCaller(), zap.AddCallerSkip(1), zap.AddStacktrace(stacktrace), zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: zapcore.NewTee(stdoutCore(), stderrCore()), levels: level}
 	}))
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	zap.ReplaceGlobals(logger)
 	zap.RedirectStdLog(logger)
+	return nil
 }
 
 func encoderConfig() zapcore.EncoderConfig {
@@ -72,10 +115,10 @@ func encoderConfig() zapcore.EncoderConfig {
 	}
 }
 
-func config(level zap.AtomicLevel) *zap.Config {
+func config(level zap.AtomicLevel, development bool) *zap.Config {
 	return &zap.Config{
 		Level:             level,
-		Development:       level.Level() == zap.DebugLevel,
+		Development:       development,
 		DisableCaller:     false,
 		DisableStacktrace: false,
 		Sampling: &zap.SamplingConfig{
@@ -159,7 +202,7 @@ func (lv *LogValue) zapIt() zap.Field {
 //
 // Example:
 //
-//	ctx := context.WithValue(context.Background(), logger.TraceId, "12345")
+//	ctx := context.WithValue(context.Background(), logger.TraceId, "4bf92f3577b34da6a3ce929d0e0e4736")
 //	logger.Info(ctx, "User logged in", logger.NewLogValue("userId", 42))
 //	logger.Info(ctx, "test 1")
 //
@@ -174,10 +217,10 @@ func (lv *LogValue) zapIt() zap.Field {
 //
 // Output:
 //
-//	{"level":"INFO","timestamp":"2025-03-10T10:36:37.958+0100","caller":"plain/main.go:43","message":"User logged in","userId":42,"logId":"23a3f92e-f36d-480f-b169-aa2dbe6465e7"}
-//	{"level":"INFO","timestamp":"2025-03-10T10:36:37.958+0100","caller":"plain/main.go:44","message":"test 1","logId":"23a3f92e-f36d-480f-b169-aa2dbe6465e7"}
-//	{"level":"INFO","timestamp":"2025-03-10T10:36:37.958+0100","caller":"plain/main.go:46","message":"test 2","testStruct":{"value_one":"value 1","value_two":123,"value_three":true},"logId":"23a3f92e-f36d-480f-b169-aa2dbe6465e7"}
-//	{"level":"INFO","timestamp":"2025-03-10T10:36:37.958+0100","caller":"plain/main.go:47","message":"test 3","error":"new error","logId":"23a3f92e-f36d-480f-b169-aa2dbe6465e7"}
+//	{"level":"INFO","timestamp":"2025-03-10T10:36:37.958+0100","caller":"plain/main.go:43","message":"User logged in","userId":42,"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"}
+//	{"level":"INFO","timestamp":"2025-03-10T10:36:37.958+0100","caller":"plain/main.go:44","message":"test 1","traceId":"4bf92f3577b34da6a3ce929d0e0e4736"}
+//	{"level":"INFO","timestamp":"2025-03-10T10:36:37.958+0100","caller":"plain/main.go:46","message":"test 2","testStruct":{"value_one":"value 1","value_two":123,"value_three":true},"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"}
+//	{"level":"INFO","timestamp":"2025-03-10T10:36:37.958+0100","caller":"plain/main.go:47","message":"test 3","error":"new error","traceId":"4bf92f3577b34da6a3ce929d0e0e4736"}
 func Info(ctx context.Context, message string, fields ...interface{}) {
 	zapFields := createZapFields(fields...)
 	zap.L().Info(message, appendRequestId(ctx, zapFields...)...)
@@ -210,7 +253,7 @@ func Infof(msg string, fields ...interface{}) {
 //
 // Output:
 //
-//	{"level":"INFO","timestamp":"2025-03-10T10:58:17.017+0100","message":"hello radical","logId":"1f7f1abe-721a-4d93-a57c-52bca6f10b44"}
+//	{"level":"INFO","timestamp":"2025-03-10T10:58:17.017+0100","message":"hello radical","traceId":"0af7651916cd43dd8448eb211c80319c"}
 func InfoNoCaller(ctx context.Context, msg string, fields ...interface{}) {
 	zapFields := createZapFields(fields...)
 	zap.L().WithOptions(zap.WithCaller(false)).Info(msg, appendRequestId(ctx, zapFields...)...)
@@ -259,7 +302,7 @@ func Sync() error {
 }
 
 // appendRequestId
-// used internally to append "logId" from context if previously set
+// used internally to append "traceId" and "spanId" from context if previously set
 func appendRequestId(ctx context.Context, fields ...zap.Field) []zap.Field {
 	if ctx == nil {
 		return fields
@@ -269,7 +312,16 @@ func appendRequestId(ctx context.Context, fields ...zap.Field) []zap.Field {
 	}
 	traceId := ctx.Value(TraceId)
 	if traceId != nil {
-		fields = append(fields, zap.Any("logId", traceId))
+		fields = append(fields, zap.Any("traceId", traceId))
+	}
+	if spanId := ctx.Value(SpanId); spanId != nil {
+		fields = append(fields, zap.Any("spanId", spanId))
+	}
+	if principal := ctx.Value(Principal); principal != nil {
+		fields = append(fields, zap.Any("principal", principal))
+	}
+	if tenant := ctx.Value(Tenant); tenant != nil {
+		fields = append(fields, zap.Any("tenant", tenant))
 	}
 	return fields
 }
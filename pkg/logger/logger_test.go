@@ -3,32 +3,50 @@ package logger
 import (
 	"context"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestInit(t *testing.T) {
 	tests := []struct {
 		name     string
-		logLevel string
+		options  Options
 		hasError bool
 	}{
-		{"Valid info level", "info", false},
-		{"Valid debug level", "debug", false},
-		{"Valid warn level", "warn", false},
-		{"Valid error level", "error", false},
-		{"Valid fatal level", "fatal", false},
-		{"Invalid log level", "asdasdd--1", false},
+		{"Valid info level", Options{Level: "info"}, false},
+		{"Valid debug level", Options{Level: "debug", Console: true, StacktraceLevel: "warn"}, false},
+		{"Valid warn level", Options{Level: "warn"}, false},
+		{"Valid error level", Options{Level: "error"}, false},
+		{"Valid fatal level", Options{Level: "fatal"}, false},
+		{"Default level", Options{}, false},
+		{"Invalid log level", Options{Level: "asdasdd--1"}, true},
+		{"Invalid stacktrace level", Options{StacktraceLevel: "asdasdd--1"}, true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			InitLogger(true)
+			err := InitLogger(tt.options)
+			if tt.hasError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.options.Level != "" {
+				assert.Equal(t, tt.options.Level, GetLevel())
+			} else {
+				assert.Equal(t, "info", GetLevel())
+			}
 		})
 	}
 }
 
 func TestContextualLogging(t *testing.T) {
 	// Initialize the logger
-	InitLogger(true)
+	require.NoError(t, InitLogger(Options{Level: "debug"}))
 
 	ctx := context.WithValue(context.Background(), TraceId, "test-request-id")
 
@@ -50,6 +68,54 @@ func TestContextualLogging(t *testing.T) {
 }
 
 func TestSync(t *testing.T) {
-	InitLogger(true)
+	require.NoError(t, InitLogger(Options{Level: "debug"}))
 	_ = Sync()
 }
+
+func TestLevels(t *testing.T) {
+	t.Cleanup(func() { level.reset(zapcore.InfoLevel) })
+	level.reset(zapcore.InfoLevel)
+	observed, logs := observer.New(zapcore.DebugLevel)
+	log := zap.New(&levelCore{Core: observed, levels: level}, zap.AddCaller())
+
+	log.Debug("dropped")
+	log.Info("kept")
+	assert.Equal(t, 1, logs.Len())
+	assert.Equal(t, "info", GetLevel())
+
+	// this package is the component of the entries logged here
+	require.NoError(t, SetComponentLevel("logger", "DEBUG"))
+	require.NoError(t, SetComponentLevel("bucket_organizer/internal/app/services", "error"))
+	log.Debug("kept")
+	assert.Equal(t, 2, logs.Len())
+	assert.Equal(t, map[string]string{"logger": "debug", "bucket_organizer/internal/app/services": "error"}, ComponentLevels())
+	assert.Equal(t, zapcore.ErrorLevel, level.of("bucket_organizer/internal/app/services.(*BucketService).PurgeExpiredTrash"))
+	assert.Equal(t, zapcore.InfoLevel, level.of("bucket_organizer/internal/app/server.(*Server).Run"))
+
+	ResetComponentLevel("logger")
+	require.NoError(t, SetLevel("warn"))
+	log.Info("dropped")
+	log.Warn("kept")
+	assert.Equal(t, 3, logs.Len())
+
+	assert.Error(t, SetLevel("verbose"))
+	assert.Error(t, SetComponentLevel("logger", ""))
+	assert.Equal(t, "warn", GetLevel())
+	parsed, err := ParseLevel("ERROR")
+	require.NoError(t, err)
+	assert.Equal(t, "error", parsed)
+}
+
+func TestLevelsWithoutCaller(t *testing.T) {
+	t.Cleanup(func() { level.reset(zapcore.InfoLevel) })
+	level.reset(zapcore.WarnLevel)
+	observed, logs := observer.New(zapcore.DebugLevel)
+	previous := zap.L()
+	zap.ReplaceGlobals(zap.New(&levelCore{Core: observed, levels: level}))
+	t.Cleanup(func() { zap.ReplaceGlobals(previous) })
+
+	InfoNoCaller(context.Background(), "dropped")
+	require.NoError(t, SetComponentLevel("logger", "info"))
+	InfoNoCaller(context.Background(), "kept")
+	assert.Equal(t, 1, logs.Len())
+}